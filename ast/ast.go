@@ -22,6 +22,8 @@ func CreatePrimitive(tok token.Token, res interface{}) (Expression, error) {
 		return CreateBoolean(tok, r), nil
 	case string:
 		return CreateLiteral(tok, r), nil
+	case nil:
+		return CreateNil(tok), nil
 	default:
 		return nil, fmt.Errorf("unexpected primitive type: %T", res)
 	}
@@ -202,6 +204,20 @@ func (i Literal) Ge(other Expression) Expression {
 	return CreateBoolean(i.Token, i.Str >= y.Str)
 }
 
+type Nil struct {
+	token.Token
+}
+
+func CreateNil(tok token.Token) Nil {
+	return Nil{
+		Token: tok,
+	}
+}
+
+func (_ Nil) IsValue() bool {
+	return true
+}
+
 type Boolean struct {
 	token.Token
 	Value bool
@@ -479,12 +495,11 @@ func (d Double) Mul(other Expression) Expression {
 		d.Value *= float64(y.Value)
 		return d
 	case Double:
-		d.Value *= d.Value
+		d.Value *= y.Value
 		return d
 	default:
 		return nil
 	}
-	return d
 }
 
 func (d Double) Div(other Expression) Expression {
@@ -522,7 +537,6 @@ func (d Double) Mod(other Expression) Expression {
 	default:
 		return nil
 	}
-	return d
 }
 
 func (d Double) Pow(other Expression) Expression {
@@ -536,7 +550,6 @@ func (d Double) Pow(other Expression) Expression {
 	default:
 		return nil
 	}
-	return d
 }
 
 func (d Double) Eq(other Expression) Expression {
@@ -624,6 +637,35 @@ func (_ Slice) IsValue() bool {
 	return false
 }
 
+type Template struct {
+	token.Token
+	List []Expression
+}
+
+func (_ Template) IsValue() bool {
+	return false
+}
+
+type Placeholder struct {
+	token.Token
+	Expr Expression
+	Spec string
+}
+
+func (_ Placeholder) IsValue() bool {
+	return false
+}
+
+type Chain struct {
+	token.Token
+	Left Expression
+	Key  Expression
+}
+
+func (_ Chain) IsValue() bool {
+	return false
+}
+
 type Index struct {
 	token.Token
 	Arr  Expression
@@ -714,6 +756,7 @@ func (_ Assign) IsValue() bool {
 type Call struct {
 	token.Token
 	Ident string
+	Expr  Expression
 	Args  []Expression
 }
 
@@ -846,6 +889,34 @@ func (_ While) IsValue() bool {
 	return false
 }
 
+type Try struct {
+	token.Token
+	Body    Expression
+	Ident   string
+	Catch   Expression
+	Finally Expression
+}
+
+func (_ Try) IsValue() bool {
+	return false
+}
+
+type Raise struct {
+	token.Token
+	Right Expression
+}
+
+func CreateRaise(tok token.Token, right Expression) Raise {
+	return Raise{
+		Token: tok,
+		Right: right,
+	}
+}
+
+func (_ Raise) IsValue() bool {
+	return false
+}
+
 type Break struct {
 	token.Token
 }
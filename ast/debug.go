@@ -28,8 +28,11 @@ func printAST(w io.Writer, e Expression, level int) {
 			printAST(w, f, level)
 		}
 	case Call:
-		fmt.Fprintf(w, "%scall(%s)", prefix, e.Ident)
+		fmt.Fprintf(w, "%s[%s] call(%s)", prefix, e.Position, e.Ident)
 		fmt.Fprintln(w)
+		if e.Expr != nil {
+			printAST(w, e.Expr, level+1)
+		}
 		for i := range e.Args {
 			printAST(w, e.Args[i], level+1)
 		}
@@ -114,6 +117,24 @@ func printAST(w io.Writer, e Expression, level int) {
 		if e.Right != nil {
 			printAST(w, e.Right, level+1)
 		}
+	case Try:
+		fmt.Fprintf(w, "%s[%s] try", prefix, e.Position)
+		fmt.Fprintln(w)
+		printAST(w, e.Body, level+1)
+		if e.Catch != nil {
+			fmt.Fprintf(w, "%s  catch(%s)", prefix, e.Ident)
+			fmt.Fprintln(w)
+			printAST(w, e.Catch, level+2)
+		}
+		if e.Finally != nil {
+			fmt.Fprintf(w, "%s  finally", prefix)
+			fmt.Fprintln(w)
+			printAST(w, e.Finally, level+2)
+		}
+	case Raise:
+		fmt.Fprintf(w, "%s[%s] raise", prefix, e.Position)
+		fmt.Fprintln(w)
+		printAST(w, e.Right, level+1)
 	case Break:
 		fmt.Fprintf(w, "%s[%s] break", prefix, e.Position)
 		fmt.Fprintln(w)
@@ -152,6 +173,14 @@ func printAST(w io.Writer, e Expression, level int) {
 		fmt.Fprintf(w, "%s[%s] path(%s)", prefix, e.Position, e.Ident)
 		fmt.Fprintln(w)
 		printAST(w, e.Right, level+1)
+	case Nil:
+		fmt.Fprintf(w, "%s[%s] nil", prefix, e.Position)
+		fmt.Fprintln(w)
+	case Chain:
+		fmt.Fprintf(w, "%s[%s] chain", prefix, e.Position)
+		fmt.Fprintln(w)
+		printAST(w, e.Left, level+1)
+		printAST(w, e.Key, level+1)
 	case Boolean:
 		fmt.Fprintf(w, "%s[%s] boolean(%t)", prefix, e.Position, e.Value)
 		fmt.Fprintln(w)
@@ -164,6 +193,16 @@ func printAST(w io.Writer, e Expression, level int) {
 	case Literal:
 		fmt.Fprintf(w, "%s[%s] literal(%s)", prefix, e.Position, e.Str)
 		fmt.Fprintln(w)
+	case Template:
+		fmt.Fprintf(w, "%s[%s] template", prefix, e.Position)
+		fmt.Fprintln(w)
+		for i := range e.List {
+			printAST(w, e.List[i], level+1)
+		}
+	case Placeholder:
+		fmt.Fprintf(w, "%s[%s] placeholder(%s)", prefix, e.Position, e.Spec)
+		fmt.Fprintln(w)
+		printAST(w, e.Expr, level+1)
 	case Variable:
 		fmt.Fprintf(w, "%s[%s] variable(%s)", prefix, e.Position, e.Ident)
 		fmt.Fprintln(w)
@@ -220,6 +259,8 @@ func binaryOp(op rune) string {
 		return "add"
 	case token.Sub:
 		return "sub"
+	case token.Mul:
+		return "mul"
 	case token.Div:
 		return "div"
 	case token.Mod:
@@ -230,6 +271,8 @@ func binaryOp(op rune) string {
 		return "and"
 	case token.Or:
 		return "or"
+	case token.Nullish:
+		return "nullish"
 	case token.Eq:
 		return "eq"
 	case token.Ne:
@@ -1,10 +1,18 @@
 package builtins
 
 import (
+	"fmt"
+	"math"
+	"sort"
+
 	"github.com/midbel/buddy/types"
 	"github.com/midbel/slices"
 )
 
+type Applier interface {
+	Apply(types.Primitive, []types.Argument) (types.Primitive, error)
+}
+
 var arrmod = Module{
 	Name: "array",
 	Builtins: map[string]Builtin{
@@ -22,10 +30,127 @@ var arrmod = Module{
 			},
 			Run: runLast,
 		},
+		"map": {
+			Name: "map",
+			Params: []types.Argument{
+				types.PosArg("fn", 1),
+				types.PosArg("iter", 2),
+			},
+			Run: runMap,
+		},
+		"filter": {
+			Name: "filter",
+			Params: []types.Argument{
+				types.PosArg("fn", 1),
+				types.PosArg("iter", 2),
+			},
+			Run: runFilter,
+		},
+		"reduce": {
+			Name:     "reduce",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("fn", 1),
+				types.PosArg("iter", 2),
+			},
+			Run: runReduce,
+		},
+		"sort": {
+			Name: "sort",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+				types.NamedArg("key", 2, types.CreateNil()),
+				types.NamedArg("reverse", 3, types.CreateBool(false)),
+			},
+			Run: runSort,
+		},
+		"reverse": {
+			Name: "reverse",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+			},
+			Run: runReverseArray,
+		},
+		"zip": {
+			Name:     "zip",
+			Variadic: true,
+			Run:      runZip,
+		},
+		"enumerate": {
+			Name: "enumerate",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+				types.NamedArg("start", 2, types.CreateInt(0)),
+			},
+			Run: runEnumerate,
+		},
+		"range": {
+			Name:     "range",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("start", 1),
+			},
+			Run: runRange,
+		},
+		"sum": {
+			Name: "sum",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+				types.NamedArg("start", 2, types.CreateInt(0)),
+			},
+			Run: runSum,
+		},
+		"min": {
+			Name: "min",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+				types.NamedArg("key", 2, types.CreateNil()),
+			},
+			Run: runMinArray,
+		},
+		"max": {
+			Name: "max",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+				types.NamedArg("key", 2, types.CreateNil()),
+			},
+			Run: runMaxArray,
+		},
+		"uniq": {
+			Name: "uniq",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+			},
+			Run: runUniq,
+		},
+		"chunk": {
+			Name: "chunk",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+				types.PosArg("size", 2),
+			},
+			Run: runChunk,
+		},
+		"flatten": {
+			Name: "flatten",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+				types.NamedArg("depth", 2, types.CreateInt(1)),
+			},
+			Run: runFlatten,
+		},
+		"contains": {
+			Name: "contains",
+			Params: []types.Argument{
+				types.PosArg("iter", 1),
+				types.PosArg("value", 2),
+			},
+			Run: runContainsArray,
+		},
 	},
 }
 
-func runFirst(args ...types.Primitive) (types.Primitive, error) {
+func runFirst(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	arr, ok := slices.Fst(args).(types.Array)
 	if !ok {
 		return nil, typeError(slices.Fst(args), arr)
@@ -33,7 +158,7 @@ func runFirst(args ...types.Primitive) (types.Primitive, error) {
 	return arr.Get(types.CreateInt(0))
 }
 
-func runLast(args ...types.Primitive) (types.Primitive, error) {
+func runLast(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	arr, ok := slices.Fst(args).(types.Array)
 	if !ok {
 		return nil, typeError(slices.Fst(args), arr)
@@ -41,3 +166,411 @@ func runLast(args ...types.Primitive) (types.Primitive, error) {
 	x := arr.Len() - 1
 	return arr.Get(types.CreateInt(int64(x)))
 }
+
+func runMap(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	var list []types.Primitive
+	err := iterate(args[1], func(p types.Primitive) error {
+		res, err := apply(ctx, args[0], p)
+		if err == nil {
+			list = append(list, res)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocate(ctx, types.CreateArray(list), nil)
+}
+
+func runFilter(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	var list []types.Primitive
+	err := iterate(args[1], func(p types.Primitive) error {
+		res, err := apply(ctx, args[0], p)
+		if err == nil && res.True() {
+			list = append(list, p)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocate(ctx, types.CreateArray(list), nil)
+}
+
+func runReduce(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) > 3 {
+		return nil, fmt.Errorf("too many arguments given")
+	}
+	var acc types.Primitive
+	if len(args) == 3 {
+		acc = args[2]
+	}
+	err := iterate(args[1], func(p types.Primitive) error {
+		if acc == nil {
+			acc = p
+			return nil
+		}
+		res, err := apply(ctx, args[0], acc, p)
+		if err == nil {
+			acc = res
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return nil, fmt.Errorf("empty sequence without initial value")
+	}
+	return acc, nil
+}
+
+func runSort(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := collect(args[0])
+	if err != nil {
+		return nil, err
+	}
+	keys, err := keysOf(ctx, args[1], list)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		index = make([]int, len(list))
+		rev   = args[2].True()
+	)
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var less bool
+		if rev {
+			less, err = lessThan(keys[index[j]], keys[index[i]])
+		} else {
+			less, err = lessThan(keys[index[i]], keys[index[j]])
+		}
+		return less
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]types.Primitive, len(list))
+	for i, x := range index {
+		res[i] = list[x]
+	}
+	return allocate(ctx, types.CreateArray(res), nil)
+}
+
+func runReverseArray(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := collect(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return allocate(ctx, types.CreateArray(list), nil)
+}
+
+func runZip(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	var (
+		lists = make([][]types.Primitive, len(args))
+		size  = -1
+	)
+	for i := range args {
+		list, err := collect(args[i])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 || len(list) < size {
+			size = len(list)
+		}
+		lists[i] = list
+	}
+	res := make([]types.Primitive, 0, size)
+	for i := 0; i < size; i++ {
+		tuple := make([]types.Primitive, len(lists))
+		for j := range lists {
+			tuple[j] = lists[j][i]
+		}
+		res = append(res, types.CreateArray(tuple))
+	}
+	return allocate(ctx, types.CreateArray(res), nil)
+}
+
+func runEnumerate(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	start, err := getOptionalInt(args, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	var list []types.Primitive
+	err = iterate(args[0], func(p types.Primitive) error {
+		pair := []types.Primitive{types.CreateInt(start), p}
+		list = append(list, types.CreateArray(pair))
+		start++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocate(ctx, types.CreateArray(list), nil)
+}
+
+func runRange(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) > 3 {
+		return nil, fmt.Errorf("too many arguments given")
+	}
+	var bounds [3]int64
+	bounds[2] = 1
+	for i := range args {
+		n, ok := args[i].Raw().(int64)
+		if !ok {
+			return nil, typeError(args[i], types.CreateInt(0))
+		}
+		bounds[i] = n
+	}
+	if len(args) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+	beg, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return nil, fmt.Errorf("range step can not be zero")
+	}
+	var size uint64
+	switch {
+	case step > 0 && beg < end:
+		size = (uint64(end)-uint64(beg)-1)/uint64(step) + 1
+	case step < 0 && beg > end:
+		size = (uint64(beg)-uint64(end)-1)/uint64(-step) + 1
+	}
+	if size > math.MaxInt32 {
+		return nil, fmt.Errorf("range too large")
+	}
+	if err := reserve(ctx, int(size)*types.SizeItem); err != nil {
+		return nil, err
+	}
+	c, _ := ctx.(Canceler)
+	var list []types.Primitive
+	for i := uint64(0); i < size; i++ {
+		if c != nil && i%1024 == 0 {
+			if err := c.Interrupted(); err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, types.CreateInt(beg+int64(i)*step))
+	}
+	return types.CreateArray(list), nil
+}
+
+func runSum(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	acc := args[1]
+	err := iterate(args[0], func(p types.Primitive) error {
+		add, ok := acc.(interface {
+			Add(types.Primitive) (types.Primitive, error)
+		})
+		if !ok {
+			return fmt.Errorf("%w: %s can not be added", types.ErrOperation, acc)
+		}
+		res, err := add.Add(p)
+		if err == nil {
+			acc = res
+		}
+		return err
+	})
+	return acc, err
+}
+
+func runMinArray(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return pickBy(ctx, args[0], args[1], func(a, b types.Primitive) (bool, error) {
+		return lessThan(a, b)
+	})
+}
+
+func runMaxArray(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return pickBy(ctx, args[0], args[1], func(a, b types.Primitive) (bool, error) {
+		return lessThan(b, a)
+	})
+}
+
+func runUniq(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	var (
+		list []types.Primitive
+		seen = make(map[string]struct{})
+	)
+	err := iterate(args[0], func(p types.Primitive) error {
+		name, _ := types.Type(p)
+		key := name + ":" + p.String()
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			list = append(list, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocate(ctx, types.CreateArray(list), nil)
+}
+
+func runChunk(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	size, err := getOptionalInt(args, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("chunk size should be greater than zero")
+	}
+	list, err := collect(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var res []types.Primitive
+	for i := 0; i < len(list); i += int(size) {
+		j := i + int(size)
+		if j > len(list) {
+			j = len(list)
+		}
+		part := make([]types.Primitive, j-i)
+		copy(part, list[i:j])
+		res = append(res, types.CreateArray(part))
+	}
+	return allocate(ctx, types.CreateArray(res), nil)
+}
+
+func runFlatten(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	depth, err := getOptionalInt(args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	list, err := flatten(args[0], depth)
+	if err != nil {
+		return nil, err
+	}
+	return allocate(ctx, types.CreateArray(list), nil)
+}
+
+func runContainsArray(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	var found bool
+	err := iterate(args[0], func(p types.Primitive) error {
+		if !found {
+			found = equal(p, args[1])
+		}
+		return nil
+	})
+	return types.CreateBool(found), err
+}
+
+func apply(ctx types.Context, fn types.Primitive, args ...types.Primitive) (types.Primitive, error) {
+	list := make([]types.Argument, len(args))
+	for i := range args {
+		list[i] = types.NamedArg("", i, args[i])
+	}
+	if a, ok := ctx.(Applier); ok {
+		return a.Apply(fn, list)
+	}
+	call, ok := fn.(types.Callable)
+	if !ok {
+		return nil, types.CallableError(fn)
+	}
+	return call.Call(ctx, list)
+}
+
+func iterate(p types.Primitive, do func(types.Primitive) error) error {
+	it, ok := p.(types.Iterable)
+	if !ok {
+		return types.IterationError(p)
+	}
+	return it.Iter(do)
+}
+
+func collect(p types.Primitive) ([]types.Primitive, error) {
+	var list []types.Primitive
+	err := iterate(p, func(p types.Primitive) error {
+		list = append(list, p)
+		return nil
+	})
+	return list, err
+}
+
+func flatten(p types.Primitive, depth int64) ([]types.Primitive, error) {
+	var list []types.Primitive
+	err := iterate(p, func(p types.Primitive) error {
+		if _, ok := p.(types.Array); !ok || depth == 0 {
+			list = append(list, p)
+			return nil
+		}
+		sub, err := flatten(p, depth-1)
+		if err == nil {
+			list = append(list, sub...)
+		}
+		return err
+	})
+	return list, err
+}
+
+func keysOf(ctx types.Context, key types.Primitive, list []types.Primitive) ([]types.Primitive, error) {
+	if types.IsNil(key) {
+		return list, nil
+	}
+	keys := make([]types.Primitive, len(list))
+	for i := range list {
+		k, err := apply(ctx, key, list[i])
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+func pickBy(ctx types.Context, iter, key types.Primitive, better func(types.Primitive, types.Primitive) (bool, error)) (types.Primitive, error) {
+	list, err := collect(iter)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("empty sequence")
+	}
+	keys, err := keysOf(ctx, key, list)
+	if err != nil {
+		return nil, err
+	}
+	var x int
+	for i := 1; i < len(list); i++ {
+		ok, err := better(keys[i], keys[x])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			x = i
+		}
+	}
+	return list[x], nil
+}
+
+func lessThan(left, right types.Primitive) (bool, error) {
+	cmp, ok := left.(interface {
+		Lt(types.Primitive) (types.Primitive, error)
+	})
+	if !ok {
+		return false, fmt.Errorf("%w: %s can not be compared", types.ErrOperation, left)
+	}
+	res, err := cmp.Lt(right)
+	if err != nil {
+		return false, err
+	}
+	return res.True(), nil
+}
+
+func equal(left, right types.Primitive) bool {
+	cmp, ok := left.(interface {
+		Eq(types.Primitive) (types.Primitive, error)
+	})
+	if !ok {
+		return false
+	}
+	res, err := cmp.Eq(right)
+	return err == nil && res.True()
+}
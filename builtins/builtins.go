@@ -10,11 +10,80 @@ import (
 	"github.com/midbel/slices"
 )
 
-var ErrType = errors.New("type error")
+var (
+	ErrType     = errors.New("type error")
+	ErrTooLarge = errors.New("result too large")
+)
+
+// MaxStringSize is the largest string, in bytes, that builtins build whether
+// or not the interpreter limits its memory.
+var MaxStringSize = 1 << 28
+
+type Allocator interface {
+	Reserve(int) error
+}
+
+func allocate(ctx types.Context, res types.Primitive, err error) (types.Primitive, error) {
+	if err != nil {
+		return nil, err
+	}
+	return res, reserve(ctx, types.SizeOf(res))
+}
+
+func reserve(ctx types.Context, size int) error {
+	a, ok := ctx.(Allocator)
+	if !ok {
+		return nil
+	}
+	return a.Reserve(size)
+}
+
+func reserveString(ctx types.Context, size int) error {
+	if size < 0 || size > MaxStringSize {
+		return sizeError()
+	}
+	return reserve(ctx, size*types.SizeChar)
+}
+
+func allocateStrings(ctx types.Context, list []string) (types.Primitive, error) {
+	size := len(list) * types.SizeItem
+	for i := range list {
+		size += len(list[i]) * types.SizeChar
+	}
+	if err := reserve(ctx, size); err != nil {
+		return nil, err
+	}
+	return stringArray(list), nil
+}
+
+type entry struct {
+	Key   string
+	Value types.Primitive
+}
+
+func createDict(list []entry) (types.Primitive, error) {
+	var (
+		dict = types.CreateDict()
+		err  error
+	)
+	for _, e := range list {
+		dict, err = dict.(types.Container).Set(types.CreateString(e.Key), e.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dict, nil
+}
+
+func sizeError() error {
+	return fmt.Errorf("%w: string longer than %d bytes", ErrTooLarge, MaxStringSize)
+}
 
 type Module struct {
 	Name     string
 	Builtins map[string]Builtin
+	Values   map[string]types.Primitive
+	Attrs    map[string]BuiltinFunc
 }
 
 func (m Module) Id() string {
@@ -36,6 +105,8 @@ func (m Module) Filter(names map[string]string) (Module, error) {
 	mod := Module{
 		Name:     m.Name,
 		Builtins: bs,
+		Values:   m.Values,
+		Attrs:    m.Attrs,
 	}
 	return mod, nil
 }
@@ -51,7 +122,32 @@ func (m Module) Lookup(mod, name string) (types.Callable, error) {
 	return b, nil
 }
 
-type BuiltinFunc func(...types.Primitive) (types.Primitive, error)
+func (m Module) Value(ctx types.Context, name string) (types.Primitive, error) {
+	if v, ok := m.Values[name]; ok {
+		return v, nil
+	}
+	if fn, ok := m.Attrs[name]; ok {
+		return fn(ctx)
+	}
+	return nil, fmt.Errorf("%s: value not defined", name)
+}
+
+func (m Module) Names() []string {
+	var list []string
+	for n := range m.Builtins {
+		list = append(list, n)
+	}
+	for n := range m.Values {
+		list = append(list, n)
+	}
+	for n := range m.Attrs {
+		list = append(list, n)
+	}
+	sort.Strings(list)
+	return list
+}
+
+type BuiltinFunc func(types.Context, ...types.Primitive) (types.Primitive, error)
 
 type Builtin struct {
 	Name     string
@@ -64,32 +160,77 @@ func (b Builtin) Arity() int {
 	return len(b.Params)
 }
 
-func (b Builtin) Call(_ types.Context, args []types.Argument) (types.Primitive, error) {
-	if b.Call == nil {
+func (b Builtin) Call(ctx types.Context, args []types.Argument) (types.Primitive, error) {
+	if b.Run == nil {
 		return nil, fmt.Errorf("%s can not be called", b.Name)
 	}
-	if len(args) != len(b.Params) {
-		if b.Variadic && len(args) < len(b.Params) {
-			return nil, fmt.Errorf("%s: not enough argument given", b.Name)
-		}
-	}
-	var list []types.Primitive
-	for i := range args {
-		list = append(list, args[i].Value)
+	list, err := b.bind(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name, err)
 	}
-	res, err := b.Run(list...)
+	res, err := b.Run(ctx, list...)
 	if err != nil {
 		err = fmt.Errorf("%s: %w", b.Name, err)
 	}
+	if res == nil && err == nil {
+		res = types.CreateNil()
+	}
 	return res, err
 }
 
+func (b Builtin) bind(args []types.Argument) ([]types.Primitive, error) {
+	var (
+		list = make([]types.Primitive, len(b.Params))
+		rest []types.Primitive
+		ptr  int
+	)
+	for ; ptr < len(args) && args[ptr].Name == ""; ptr++ {
+		if ptr < len(list) {
+			list[ptr] = args[ptr].Value
+			continue
+		}
+		if !b.Variadic {
+			return nil, fmt.Errorf("too many arguments given")
+		}
+		rest = append(rest, args[ptr].Value)
+	}
+	for ; ptr < len(args); ptr++ {
+		name := args[ptr].Name
+		x := slices.Index(b.Params, func(a types.Argument) bool {
+			return a.Name == name
+		})
+		if x < 0 {
+			return nil, fmt.Errorf("%s: parameter not found", name)
+		}
+		if list[x] != nil {
+			return nil, fmt.Errorf("%s: argument already given", name)
+		}
+		list[x] = args[ptr].Value
+	}
+	for i := range list {
+		if list[i] != nil {
+			continue
+		}
+		if b.Params[i].Value == nil {
+			return nil, fmt.Errorf("not enough argument given")
+		}
+		list[i] = b.Params[i].Value
+	}
+	return append(list, rest...), nil
+}
+
 var Modules = []Module{
 	iomod,
 	strmod,
 	defmod,
 	arrmod,
+	mathmod,
+	jsonmod,
+	regexmod,
 	timemod,
+	fsmod,
+	pathmod,
+	osmod,
 }
 
 var defmod = Module{
@@ -133,7 +274,7 @@ var defmod = Module{
 		"exit": {
 			Name: "exit",
 			Params: []types.Argument{
-				types.PosArg("code", 1),
+				types.NamedArg("code", 1, types.CreateInt(0)),
 			},
 			Run: runExit,
 		},
@@ -179,7 +320,7 @@ func LookupBuiltin(ident string) (types.Callable, error) {
 	return defmod.Lookup("", ident)
 }
 
-func runTypeof(args ...types.Primitive) (types.Primitive, error) {
+func runTypeof(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	name, err := types.Type(slices.Fst(args))
 	if err != nil {
 		return nil, err
@@ -187,7 +328,7 @@ func runTypeof(args ...types.Primitive) (types.Primitive, error) {
 	return types.CreateString(name), nil
 }
 
-func runAll(args ...types.Primitive) (types.Primitive, error) {
+func runAll(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	var ok bool
 	for _, a := range args {
 		ok = a.True()
@@ -198,7 +339,7 @@ func runAll(args ...types.Primitive) (types.Primitive, error) {
 	return types.CreateBool(ok), nil
 }
 
-func runAny(args ...types.Primitive) (types.Primitive, error) {
+func runAny(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	var ok bool
 	for _, a := range args {
 		ok = a.True()
@@ -209,7 +350,7 @@ func runAny(args ...types.Primitive) (types.Primitive, error) {
 	return types.CreateBool(ok), nil
 }
 
-func runInt(args ...types.Primitive) (types.Primitive, error) {
+func runInt(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) != 1 {
 		return nil, fmt.Errorf("invalid number of arguments")
 	}
@@ -239,7 +380,7 @@ func runInt(args ...types.Primitive) (types.Primitive, error) {
 	return types.CreateInt(val), nil
 }
 
-func runFloat(args ...types.Primitive) (types.Primitive, error) {
+func runFloat(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) != 1 {
 		return nil, fmt.Errorf("invalid number of arguments")
 	}
@@ -269,22 +410,22 @@ func runFloat(args ...types.Primitive) (types.Primitive, error) {
 	return types.CreateFloat(val), nil
 }
 
-func runString(args ...types.Primitive) (types.Primitive, error) {
+func runString(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) != 1 {
 		return nil, fmt.Errorf("invalid number of arguments")
 	}
 	str := slices.Fst(args).String()
-	return types.CreateString(str), nil
+	return allocate(ctx, types.CreateString(str), nil)
 }
 
-func runBool(args ...types.Primitive) (types.Primitive, error) {
+func runBool(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) != 1 {
 		return nil, fmt.Errorf("invalid number of arguments")
 	}
 	return types.CreateBool(slices.Fst(args).True()), nil
 }
 
-func runLen(args ...types.Primitive) (types.Primitive, error) {
+func runLen(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) != 1 {
 		return nil, fmt.Errorf("len: no enough argument given")
 	}
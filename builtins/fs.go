@@ -0,0 +1,290 @@
+package builtins
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/midbel/buddy/types"
+	"github.com/midbel/slices"
+)
+
+type Sandbox interface {
+	ResolveFile(string) (string, error)
+}
+
+var fsmod = Module{
+	Name: "fs",
+	Builtins: map[string]Builtin{
+		"read": {
+			Name: "read",
+			Params: []types.Argument{
+				types.PosArg("file", 1),
+			},
+			Run: runReadFile,
+		},
+		"write": {
+			Name: "write",
+			Params: []types.Argument{
+				types.PosArg("file", 1),
+				types.PosArg("data", 2),
+			},
+			Run: runWriteFile,
+		},
+		"append": {
+			Name: "append",
+			Params: []types.Argument{
+				types.PosArg("file", 1),
+				types.PosArg("data", 2),
+			},
+			Run: runAppendFile,
+		},
+		"exists": {
+			Name: "exists",
+			Params: []types.Argument{
+				types.PosArg("file", 1),
+			},
+			Run: runExists,
+		},
+		"list": {
+			Name: "list",
+			Params: []types.Argument{
+				types.NamedArg("dir", 1, types.CreateString(".")),
+			},
+			Run: runList,
+		},
+		"glob": {
+			Name: "glob",
+			Params: []types.Argument{
+				types.PosArg("pattern", 1),
+			},
+			Run: runGlob,
+		},
+		"stat": {
+			Name: "stat",
+			Params: []types.Argument{
+				types.PosArg("file", 1),
+			},
+			Run: runStat,
+		},
+		"mkdir": {
+			Name: "mkdir",
+			Params: []types.Argument{
+				types.PosArg("dir", 1),
+				types.NamedArg("parents", 2, types.CreateBool(false)),
+			},
+			Run: runMkdir,
+		},
+		"remove": {
+			Name: "remove",
+			Params: []types.Argument{
+				types.PosArg("file", 1),
+				types.NamedArg("all", 2, types.CreateBool(false)),
+			},
+			Run: runRemove,
+		},
+		"rename": {
+			Name: "rename",
+			Params: []types.Argument{
+				types.PosArg("old", 1),
+				types.PosArg("new", 2),
+			},
+			Run: runRename,
+		},
+		"walk": {
+			Name: "walk",
+			Params: []types.Argument{
+				types.NamedArg("dir", 1, types.CreateString(".")),
+			},
+			Run: runWalk,
+		},
+	},
+}
+
+func runReadFile(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	file, err := getFile(ctx, slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil && fi.Size() > int64(MaxStringSize) {
+		return nil, sizeError()
+	}
+	return readString(ctx, f)
+}
+
+func runWriteFile(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return nil, writeFile(ctx, args, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+}
+
+func runAppendFile(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return nil, writeFile(ctx, args, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+}
+
+func runExists(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	file, err := getFile(ctx, slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	_, err = os.Stat(file)
+	return types.CreateBool(err == nil), nil
+}
+
+func runList(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	dir, err := getFile(ctx, slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	es, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]string, len(es))
+	for i := range es {
+		list[i] = es[i].Name()
+	}
+	return allocateStrings(ctx, list)
+}
+
+func runGlob(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	pattern, err := getString(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for _, m := range matches {
+		if _, err := resolveFile(ctx, m); err != nil {
+			continue
+		}
+		list = append(list, m)
+	}
+	return allocateStrings(ctx, list)
+}
+
+func runStat(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	file, err := getFile(ctx, slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo(fi)
+}
+
+func runMkdir(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	dir, err := getFile(ctx, slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	if slices.Snd(args).True() {
+		return nil, os.MkdirAll(dir, 0o755)
+	}
+	return nil, os.Mkdir(dir, 0o755)
+}
+
+func runRemove(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	file, err := getFile(ctx, slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	if slices.Snd(args).True() {
+		return nil, os.RemoveAll(file)
+	}
+	return nil, os.Remove(file)
+}
+
+func runRename(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	old, err := getFile(ctx, slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	file, err := getFile(ctx, slices.Snd(args))
+	if err != nil {
+		return nil, err
+	}
+	return nil, os.Rename(old, file)
+}
+
+func runWalk(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	dir, err := getString(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := resolveFile(ctx, dir); err != nil {
+		return nil, err
+	}
+	var list []string
+	err = filepath.WalkDir(dir, func(file string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.Type()&fs.ModeSymlink != 0 {
+			if _, err := resolveFile(ctx, file); err != nil {
+				return nil
+			}
+		}
+		list = append(list, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(list)
+	return allocateStrings(ctx, list)
+}
+
+func writeFile(ctx types.Context, args []types.Primitive, flag int) error {
+	file, err := getFile(ctx, slices.Fst(args))
+	if err != nil {
+		return err
+	}
+	data, err := getString(slices.Snd(args))
+	if err != nil {
+		return err
+	}
+	w, err := os.OpenFile(file, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = w.WriteString(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func fileInfo(fi fs.FileInfo) (types.Primitive, error) {
+	return createDict([]entry{
+		{Key: "name", Value: types.CreateString(fi.Name())},
+		{Key: "size", Value: types.CreateInt(fi.Size())},
+		{Key: "mode", Value: types.CreateInt(int64(fi.Mode().Perm()))},
+		{Key: "dir", Value: types.CreateBool(fi.IsDir())},
+		{Key: "modtime", Value: types.CreateTime(fi.ModTime())},
+	})
+}
+
+func getFile(ctx types.Context, p types.Primitive) (string, error) {
+	file, err := getString(p)
+	if err != nil {
+		return "", err
+	}
+	return resolveFile(ctx, file)
+}
+
+func resolveFile(ctx types.Context, file string) (string, error) {
+	if s, ok := ctx.(Sandbox); ok {
+		return s.ResolveFile(file)
+	}
+	return file, nil
+}
@@ -1,13 +1,46 @@
 package builtins
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/midbel/buddy/types"
 	"github.com/midbel/slices"
 )
 
+type Streams interface {
+	Output() io.Writer
+	ErrOutput() io.Writer
+	Input() *bufio.Reader
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func stdout(ctx types.Context) io.Writer {
+	if s, ok := ctx.(Streams); ok {
+		return s.Output()
+	}
+	return os.Stdout
+}
+
+func stderr(ctx types.Context) io.Writer {
+	if s, ok := ctx.(Streams); ok {
+		return s.ErrOutput()
+	}
+	return os.Stderr
+}
+
+func input(ctx types.Context) *bufio.Reader {
+	if s, ok := ctx.(Streams); ok {
+		return s.Input()
+	}
+	return stdin
+}
+
 var iomod = Module{
 	Name: "io",
 	Builtins: map[string]Builtin{
@@ -16,6 +49,11 @@ var iomod = Module{
 			Variadic: true,
 			Run:      runPrint,
 		},
+		"eprint": {
+			Name:     "eprint",
+			Variadic: true,
+			Run:      runEprint,
+		},
 		"printf": {
 			Name:     "printf",
 			Variadic: true,
@@ -24,10 +62,22 @@ var iomod = Module{
 			},
 			Run: runPrintf,
 		},
+		"readline": {
+			Name: "readline",
+			Run:  runReadline,
+		},
+		"readall": {
+			Name: "readall",
+			Run:  runReadall,
+		},
+		"lines": {
+			Name: "lines",
+			Run:  runLines,
+		},
 	},
 }
 
-func runPrintf(args ...types.Primitive) (types.Primitive, error) {
+func runPrintf(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("no enough argument given")
 	}
@@ -39,15 +89,76 @@ func runPrintf(args ...types.Primitive) (types.Primitive, error) {
 	for _, a := range slices.Rest(args) {
 		list = append(list, a.Raw())
 	}
-	fmt.Fprintf(os.Stdout, pattern, list...)
-	return nil, nil
+	_, err := fmt.Fprintf(stdout(ctx), pattern, list...)
+	return types.CreateNil(), err
 }
 
-func runPrint(args ...types.Primitive) (types.Primitive, error) {
-	var list []any
+func runPrint(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return types.CreateNil(), printValues(stdout(ctx), args)
+}
+
+func runEprint(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return types.CreateNil(), printValues(stderr(ctx), args)
+}
+
+func printValues(w io.Writer, args []types.Primitive) error {
+	list := make([]string, len(args))
 	for i := range args {
-		list = append(list, args[i].Raw())
+		list[i] = args[i].String()
+	}
+	_, err := fmt.Fprintln(w, strings.Join(list, " "))
+	return err
+}
+
+func runReadline(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	line, ok, err := readLine(input(ctx))
+	if err != nil || !ok {
+		return types.CreateNil(), err
+	}
+	return allocate(ctx, types.CreateString(line), nil)
+}
+
+func runReadall(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return readString(ctx, input(ctx))
+}
+
+func readString(ctx types.Context, r io.Reader) (types.Primitive, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, int64(MaxStringSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := reserveString(ctx, len(buf)); err != nil {
+		return nil, err
+	}
+	return types.CreateString(string(buf)), nil
+}
+
+func runLines(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	r := input(ctx)
+	next := func() (types.Primitive, bool, error) {
+		line, ok, err := readLine(r)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		if err := reserveString(ctx, len(line)); err != nil {
+			return nil, false, err
+		}
+		return types.CreateString(line), true, nil
+	}
+	return types.CreateIterator(next), nil
+}
+
+func readLine(r *bufio.Reader) (string, bool, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			return "", false, err
+		}
+		if line == "" {
+			return "", false, nil
+		}
 	}
-	fmt.Fprintln(os.Stdout, list...)
-	return nil, nil
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, true, nil
 }
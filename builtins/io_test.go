@@ -0,0 +1,27 @@
+package builtins_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/midbel/buddy/eval"
+)
+
+func TestPrint(t *testing.T) {
+	var (
+		out bytes.Buffer
+		bud = eval.Default()
+	)
+	bud.Stdout = &out
+	res, err := bud.EvalString("import io\nio.print(nil, 1, \"a\", [1, nil])\nio.printf(\"%d-%s\\n\", 3, \"x\")")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := res.String(); got != "nil" {
+		t.Errorf("printf: want nil, got %s", got)
+	}
+	want := "nil 1 a [1 nil]\n3-x\n"
+	if got := out.String(); got != want {
+		t.Errorf("print: want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,375 @@
+package builtins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/midbel/buddy/types"
+	"github.com/midbel/slices"
+)
+
+var ErrDecode = errors.New("decode error")
+
+var jsonmod = Module{
+	Name: "json",
+	Builtins: map[string]Builtin{
+		"parse": {
+			Name: "parse",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+			},
+			Run: runParseJSON,
+		},
+		"stringify": {
+			Name: "stringify",
+			Params: []types.Argument{
+				types.PosArg("value", 1),
+				types.NamedArg("indent", 2, types.CreateNil()),
+				types.NamedArg("sort", 3, types.CreateBool(true)),
+			},
+			Run: runStringifyJSON,
+		},
+		"lines": {
+			Name:     "lines",
+			Variadic: true,
+			Run:      runLinesJSON,
+		},
+	},
+}
+
+func runParseJSON(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	str, ok := slices.Fst(args).Raw().(string)
+	if !ok {
+		return nil, fmt.Errorf("incompatible type: string expected")
+	}
+	dec := createDecoder(strings.NewReader(str))
+	res, err := decodeJSON(dec)
+	if err == nil {
+		offset := int(dec.InputOffset())
+		if _, err = dec.Token(); errors.Is(err, io.EOF) {
+			err = nil
+		} else {
+			rest := str[offset:]
+			offset += len(rest) - len(strings.TrimLeft(rest, " \t\r\n"))
+			err = decodeError{
+				err:    fmt.Errorf("unexpected data after top-level value"),
+				offset: int64(offset),
+			}
+		}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = decodeError{
+			err:    fmt.Errorf("unexpected end of input"),
+			offset: int64(len(str)),
+		}
+	}
+	if err != nil {
+		return nil, withPosition(err, str)
+	}
+	return res, reserve(ctx, len(str))
+}
+
+func runLinesJSON(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	var r io.Reader
+	switch len(args) {
+	case 0:
+		r = input(ctx)
+	case 1:
+		str, ok := slices.Fst(args).Raw().(string)
+		if !ok {
+			return nil, fmt.Errorf("incompatible type: string expected")
+		}
+		r = strings.NewReader(str)
+	default:
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	var (
+		dec    = createDecoder(r)
+		offset int64
+	)
+	next := func() (types.Primitive, bool, error) {
+		res, err := decodeJSON(dec)
+		if errors.Is(err, io.EOF) {
+			return nil, false, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = decodeError{
+				err:    fmt.Errorf("unexpected end of input"),
+				offset: dec.InputOffset(),
+			}
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		size := dec.InputOffset() - offset
+		offset += size
+		if err := reserve(ctx, int(size)); err != nil {
+			return nil, false, err
+		}
+		return res, true, nil
+	}
+	return types.CreateIterator(next), nil
+}
+
+func runStringifyJSON(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	enc := jsonEncoder{
+		sorted: args[2].True(),
+	}
+	switch x := args[1].Raw().(type) {
+	case int64:
+		enc.indent = strings.Repeat(" ", int(x))
+	case string:
+		enc.indent = x
+	case nil:
+	default:
+		return nil, fmt.Errorf("incompatible type: indent should be a string or an integer")
+	}
+	if err := enc.encode(slices.Fst(args), 0); err != nil {
+		return nil, err
+	}
+	if err := reserveString(ctx, enc.Len()); err != nil {
+		return nil, err
+	}
+	return types.CreateString(enc.String()), nil
+}
+
+func createDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
+
+func decodeJSON(dec *json.Decoder) (types.Primitive, error) {
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		var (
+			serr *json.SyntaxError
+			terr *json.UnmarshalTypeError
+		)
+		switch {
+		case errors.As(err, &serr):
+			offset := serr.Offset
+			if offset > 0 {
+				offset--
+			}
+			return nil, decodeError{err: serr, offset: offset}
+		case errors.As(err, &terr):
+			return nil, decodeError{err: terr, offset: terr.Offset}
+		default:
+			return nil, err
+		}
+	}
+	return jsonValue(value)
+}
+
+func jsonValue(value any) (types.Primitive, error) {
+	switch v := value.(type) {
+	case nil:
+		return types.CreateNil(), nil
+	case bool:
+		return types.CreateBool(v), nil
+	case string:
+		return types.CreateString(v), nil
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			if n, err := v.Int64(); err == nil {
+				return types.CreateInt(n), nil
+			}
+		}
+		n, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: invalid number", ErrDecode, v)
+		}
+		return types.CreateFloat(n), nil
+	case []any:
+		list := make([]types.Primitive, 0, len(v))
+		for i := range v {
+			p, err := jsonValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, p)
+		}
+		return types.CreateArray(list), nil
+	case map[string]any:
+		dict := types.CreateDict()
+		for k := range v {
+			p, err := jsonValue(v[k])
+			if err != nil {
+				return nil, err
+			}
+			if dict, err = dict.(types.Container).Set(types.CreateString(k), p); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported value %v", ErrDecode, value)
+	}
+}
+
+type decodeError struct {
+	err    error
+	offset int64
+	line   int
+	column int
+}
+
+func (e decodeError) Error() string {
+	if e.line > 0 {
+		return fmt.Sprintf("%s: %s at offset %d (line %d, column %d)", ErrDecode, e.err, e.offset, e.line, e.column)
+	}
+	return fmt.Sprintf("%s: %s at offset %d", ErrDecode, e.err, e.offset)
+}
+
+func (e decodeError) Is(err error) bool {
+	return err == ErrDecode
+}
+
+func (e decodeError) Unwrap() error {
+	return e.err
+}
+
+func withPosition(err error, str string) error {
+	var derr decodeError
+	if !errors.As(err, &derr) {
+		return err
+	}
+	offset := int(derr.offset)
+	if offset > len(str) {
+		offset = len(str)
+	}
+	prefix := str[:offset]
+	derr.line = strings.Count(prefix, "\n") + 1
+	derr.column = offset - strings.LastIndex(prefix, "\n")
+	return derr
+}
+
+type jsonEncoder struct {
+	bytes.Buffer
+	indent string
+	sorted bool
+}
+
+func (e *jsonEncoder) encode(value types.Primitive, level int) error {
+	switch v := value.(type) {
+	case types.Nil:
+		e.WriteString("null")
+	case types.Bool:
+		e.WriteString(strconv.FormatBool(v.True()))
+	case types.Int:
+		e.WriteString(strconv.FormatInt(v.Raw().(int64), 10))
+	case types.Float:
+		f := v.Raw().(float64)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%w: %s can not be encoded in json", types.ErrIncompatible, v)
+		}
+		e.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	case types.String, types.Time, types.Duration:
+		e.writeString(v.String())
+	case types.Array:
+		return e.encodeArray(v, level)
+	case types.Dict:
+		return e.encodeDict(v, level)
+	default:
+		return fmt.Errorf("%w: %s can not be encoded in json", types.ErrIncompatible, value)
+	}
+	return nil
+}
+
+func (e *jsonEncoder) encodeArray(arr types.Array, level int) error {
+	if arr.Len() == 0 {
+		e.WriteString("[]")
+		return nil
+	}
+	var ix int
+	e.WriteByte('[')
+	err := arr.Iter(func(p types.Primitive) error {
+		if ix > 0 {
+			e.WriteByte(',')
+		}
+		ix++
+		e.newline(level + 1)
+		return e.encode(p, level+1)
+	})
+	if err != nil {
+		return err
+	}
+	e.newline(level)
+	e.WriteByte(']')
+	return nil
+}
+
+func (e *jsonEncoder) encodeDict(dict types.Dict, level int) error {
+	if dict.Len() == 0 {
+		e.WriteString("{}")
+		return nil
+	}
+	var (
+		values = dict.Raw().(map[types.Primitive]types.Primitive)
+		keys   = make([]string, 0, len(values))
+		index  = make(map[string]types.Primitive)
+	)
+	for k, v := range values {
+		switch k.(type) {
+		case types.String, types.Int, types.Float, types.Bool:
+		default:
+			return fmt.Errorf("%w: %s can not be used as json key", types.ErrIncompatible, k)
+		}
+		key := k.String()
+		if _, ok := index[key]; ok {
+			return fmt.Errorf("%w: %s: duplicate json key", types.ErrIncompatible, key)
+		}
+		keys = append(keys, key)
+		index[key] = v
+	}
+	if e.sorted {
+		sort.Strings(keys)
+	}
+	e.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		e.newline(level + 1)
+		e.writeString(k)
+		e.WriteByte(':')
+		if e.indent != "" {
+			e.WriteByte(' ')
+		}
+		if err := e.encode(index[k], level+1); err != nil {
+			return err
+		}
+	}
+	e.newline(level)
+	e.WriteByte('}')
+	return nil
+}
+
+func (e *jsonEncoder) writeString(str string) {
+	enc := json.NewEncoder(&e.Buffer)
+	enc.SetEscapeHTML(false)
+	enc.Encode(str)
+	e.Truncate(e.Len() - 1)
+}
+
+func (e *jsonEncoder) newline(level int) {
+	if e.indent == "" {
+		return
+	}
+	e.WriteByte('\n')
+	e.WriteString(strings.Repeat(e.indent, level))
+}
@@ -0,0 +1,72 @@
+package builtins_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/midbel/buddy/builtins"
+	"github.com/midbel/buddy/eval"
+)
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  string
+		Err   bool
+	}{
+		{Input: `1`, Want: "1"},
+		{Input: ` [1, 2] ` + "\n", Want: "[1 2]"},
+		{Input: `"<x>"`, Want: "<x>"},
+		{Input: `1 ]`, Err: true},
+		{Input: `{} }`, Err: true},
+		{Input: `[1] [2]`, Err: true},
+		{Input: `[1, 2`, Err: true},
+		{Input: ``, Err: true},
+	}
+	for _, tt := range tests {
+		src := "import json\njson.parse(" + quote(tt.Input) + ")"
+		res, err := eval.Default().EvalString(src)
+		if tt.Err {
+			if err == nil || !strings.Contains(err.Error(), builtins.ErrDecode.Error()) {
+				t.Errorf("%q: expected decode error, got %v (%v)", tt.Input, res, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.Input, err)
+			continue
+		}
+		if got := res.String(); got != tt.Want {
+			t.Errorf("%q: want %s, got %s", tt.Input, tt.Want, got)
+		}
+	}
+}
+
+func TestStringifyJSON(t *testing.T) {
+	tests := []struct {
+		Expr string
+		Want string
+	}{
+		{Expr: `json.stringify({"b": "<x>&", "a": [1, nil]})`, Want: `{"a":[1,null],"b":"<x>&"}`},
+		{Expr: `json.stringify({"b": 1, "a": 2}, sort=true)`, Want: `{"a":2,"b":1}`},
+		{Expr: `json.stringify({"a": 1}, sort=false)`, Want: `{"a":1}`},
+		{Expr: `json.stringify([1, "é\n"], indent=1)`, Want: "[\n 1,\n \"é\\n\"\n]"},
+	}
+	for _, tt := range tests {
+		res, err := eval.Default().EvalString("import json\n" + tt.Expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.Expr, err)
+			continue
+		}
+		if got := res.String(); got != tt.Want {
+			t.Errorf("%s: want %s, got %s", tt.Expr, tt.Want, got)
+		}
+	}
+}
+
+func quote(str string) string {
+	str = strings.ReplaceAll(str, `\`, `\\`)
+	str = strings.ReplaceAll(str, `"`, `\"`)
+	str = strings.ReplaceAll(str, "\n", `\n`)
+	return `"` + str + `"`
+}
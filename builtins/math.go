@@ -0,0 +1,654 @@
+package builtins
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/midbel/buddy/types"
+	"github.com/midbel/slices"
+)
+
+var mathmod = Module{
+	Name: "math",
+	Builtins: map[string]Builtin{
+		"sqrt":    floatBuiltin("sqrt", math.Sqrt),
+		"cbrt":    floatBuiltin("cbrt", math.Cbrt),
+		"exp":     floatBuiltin("exp", math.Exp),
+		"log2":    floatBuiltin("log2", math.Log2),
+		"log10":   floatBuiltin("log10", math.Log10),
+		"sin":     floatBuiltin("sin", math.Sin),
+		"cos":     floatBuiltin("cos", math.Cos),
+		"tan":     floatBuiltin("tan", math.Tan),
+		"asin":    floatBuiltin("asin", math.Asin),
+		"acos":    floatBuiltin("acos", math.Acos),
+		"atan":    floatBuiltin("atan", math.Atan),
+		"sinh":    floatBuiltin("sinh", math.Sinh),
+		"cosh":    floatBuiltin("cosh", math.Cosh),
+		"tanh":    floatBuiltin("tanh", math.Tanh),
+		"degrees": floatBuiltin("degrees", toDegrees),
+		"radians": floatBuiltin("radians", toRadians),
+		"atan2": {
+			Name: "atan2",
+			Params: []types.Argument{
+				types.PosArg("y", 1),
+				types.PosArg("x", 2),
+			},
+			Run: runAtan2,
+		},
+		"hypot": {
+			Name: "hypot",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+				types.PosArg("y", 2),
+			},
+			Run: runHypot,
+		},
+		"pow": {
+			Name: "pow",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+				types.PosArg("y", 2),
+			},
+			Run: runPow,
+		},
+		"log": {
+			Name:     "log",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runLog,
+		},
+		"abs": {
+			Name: "abs",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runAbs,
+		},
+		"sign": {
+			Name: "sign",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runSign,
+		},
+		"min": {
+			Name:     "min",
+			Variadic: true,
+			Run:      runMin,
+		},
+		"max": {
+			Name:     "max",
+			Variadic: true,
+			Run:      runMax,
+		},
+		"clamp": {
+			Name: "clamp",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+				types.PosArg("low", 2),
+				types.PosArg("high", 3),
+			},
+			Run: runClamp,
+		},
+		"floor": {
+			Name: "floor",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runFloor,
+		},
+		"ceil": {
+			Name: "ceil",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runCeil,
+		},
+		"trunc": {
+			Name: "trunc",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runTrunc,
+		},
+		"round": {
+			Name:     "round",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runRound,
+		},
+		"gcd": {
+			Name:     "gcd",
+			Variadic: true,
+			Run:      runGcd,
+		},
+		"lcm": {
+			Name:     "lcm",
+			Variadic: true,
+			Run:      runLcm,
+		},
+		"isnan": {
+			Name: "isnan",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runIsNaN,
+		},
+		"isinf": {
+			Name: "isinf",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runIsInf,
+		},
+		"isfinite": {
+			Name: "isfinite",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runIsFinite,
+		},
+		"toint": {
+			Name: "toint",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runToInt,
+		},
+		"tofloat": {
+			Name: "tofloat",
+			Params: []types.Argument{
+				types.PosArg("x", 1),
+			},
+			Run: runToFloat,
+		},
+	},
+	Values: map[string]types.Primitive{
+		"pi":      types.CreateFloat(math.Pi),
+		"e":       types.CreateFloat(math.E),
+		"tau":     types.CreateFloat(2 * math.Pi),
+		"inf":     types.CreateFloat(math.Inf(1)),
+		"nan":     types.CreateFloat(math.NaN()),
+		"epsilon": types.CreateFloat(math.Nextafter(1, 2) - 1),
+		"maxint":  types.CreateInt(math.MaxInt64),
+		"minint":  types.CreateInt(math.MinInt64),
+	},
+}
+
+const (
+	roundHalfEven = "half_even"
+	roundHalfUp   = "half_up"
+	roundHalfDown = "half_down"
+	roundUp       = "up"
+	roundDown     = "down"
+	roundCeil     = "ceil"
+	roundFloor    = "floor"
+)
+
+func floatBuiltin(name string, fn func(float64) float64) Builtin {
+	run := func(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("invalid number of arguments")
+		}
+		x, err := getFloat(slices.Fst(args))
+		if err != nil {
+			return nil, err
+		}
+		return types.CreateFloat(fn(x)), nil
+	}
+	return Builtin{
+		Name: name,
+		Params: []types.Argument{
+			types.PosArg("x", 1),
+		},
+		Run: run,
+	}
+}
+
+func runAtan2(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return runFloat2(args, math.Atan2)
+}
+
+func runHypot(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return runFloat2(args, math.Hypot)
+}
+
+func runPow(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	x, ok1 := args[0].(types.Int)
+	y, ok2 := args[1].(types.Int)
+	if ok1 && ok2 && y.Raw().(int64) >= 0 {
+		return powInt(x.Raw().(int64), y.Raw().(int64))
+	}
+	return runFloat2(args, math.Pow)
+}
+
+func runLog(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	x, err := getFloat(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return types.CreateFloat(math.Log(x)), nil
+	}
+	base, err := getFloat(slices.Lst(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateFloat(math.Log(x) / math.Log(base)), nil
+}
+
+func runAbs(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	switch x := slices.Fst(args).Raw().(type) {
+	case int64:
+		if x == math.MinInt64 {
+			return nil, overflowError()
+		}
+		if x < 0 {
+			x = -x
+		}
+		return types.CreateInt(x), nil
+	case float64:
+		return types.CreateFloat(math.Abs(x)), nil
+	default:
+		return nil, numberError(slices.Fst(args))
+	}
+}
+
+func runSign(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	switch x := slices.Fst(args).Raw().(type) {
+	case int64:
+		switch {
+		case x < 0:
+			return types.CreateInt(-1), nil
+		case x > 0:
+			return types.CreateInt(1), nil
+		default:
+			return types.CreateInt(0), nil
+		}
+	case float64:
+		switch {
+		case math.IsNaN(x):
+			return types.CreateFloat(x), nil
+		case x < 0:
+			return types.CreateFloat(-1), nil
+		case x > 0:
+			return types.CreateFloat(1), nil
+		default:
+			return types.CreateFloat(0), nil
+		}
+	default:
+		return nil, numberError(slices.Fst(args))
+	}
+}
+
+func runMin(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return pickNumber(args, func(a, b float64) bool { return a < b })
+}
+
+func runMax(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return pickNumber(args, func(a, b float64) bool { return a > b })
+}
+
+func runClamp(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	var list [3]float64
+	for i := range args {
+		x, err := getFloat(args[i])
+		if err != nil {
+			return nil, err
+		}
+		list[i] = x
+	}
+	if list[1] > list[2] {
+		return nil, fmt.Errorf("%w: lower bound greater than upper bound", types.ErrIncompatible)
+	}
+	switch {
+	case list[0] < list[1]:
+		return args[1], nil
+	case list[0] > list[2]:
+		return args[2], nil
+	default:
+		return args[0], nil
+	}
+}
+
+func runFloor(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return roundWith(args, math.Floor)
+}
+
+func runCeil(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return roundWith(args, math.Ceil)
+}
+
+func runTrunc(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return roundWith(args, math.Trunc)
+}
+
+func runRound(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) == 0 || len(args) > 3 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	var (
+		digits int64
+		mode   = roundHalfEven
+	)
+	for _, a := range slices.Rest(args) {
+		switch v := a.Raw().(type) {
+		case int64:
+			digits = v
+		case string:
+			mode = strings.ToLower(v)
+		default:
+			return nil, fmt.Errorf("%w: digits or rounding mode expected, got %s", types.ErrIncompatible, a)
+		}
+	}
+	fn, err := roundMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	if digits == 0 {
+		return roundWith(args[:1], fn)
+	}
+	x, err := getFloat(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	pow := math.Pow(10, float64(digits))
+	return types.CreateFloat(fn(x*pow) / pow), nil
+}
+
+func runGcd(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return reduceInt(args, func(a, b int64) (int64, bool) {
+		return gcd(a, b), true
+	})
+}
+
+func runLcm(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return reduceInt(args, func(a, b int64) (int64, bool) {
+		if a == 0 || b == 0 {
+			return 0, true
+		}
+		return mulInt(a/gcd(a, b), b)
+	})
+}
+
+func runIsNaN(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return testFloat(args, func(x float64) bool { return math.IsNaN(x) })
+}
+
+func runIsInf(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return testFloat(args, func(x float64) bool { return math.IsInf(x, 0) })
+}
+
+func runIsFinite(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return testFloat(args, func(x float64) bool { return !math.IsInf(x, 0) && !math.IsNaN(x) })
+}
+
+func runToInt(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	switch x := slices.Fst(args).Raw().(type) {
+	case int64:
+		return slices.Fst(args), nil
+	case float64:
+		if x != math.Trunc(x) {
+			return nil, fmt.Errorf("%w: %v can not be converted to integer without loss", types.ErrIncompatible, x)
+		}
+		return floatToInt(x)
+	default:
+		return nil, numberError(slices.Fst(args))
+	}
+}
+
+func runToFloat(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	switch x := slices.Fst(args).Raw().(type) {
+	case int64:
+		f := float64(x)
+		if f >= math.MaxInt64 || int64(f) != x {
+			return nil, fmt.Errorf("%w: %d can not be converted to float without loss", types.ErrIncompatible, x)
+		}
+		return types.CreateFloat(f), nil
+	case float64:
+		return slices.Fst(args), nil
+	default:
+		return nil, numberError(slices.Fst(args))
+	}
+}
+
+func runFloat2(args []types.Primitive, fn func(float64, float64) float64) (types.Primitive, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	x, err := getFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	y, err := getFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateFloat(fn(x, y)), nil
+}
+
+func roundWith(args []types.Primitive, fn func(float64) float64) (types.Primitive, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	switch x := slices.Fst(args).Raw().(type) {
+	case int64:
+		return slices.Fst(args), nil
+	case float64:
+		return floatToInt(fn(x))
+	default:
+		return nil, numberError(slices.Fst(args))
+	}
+}
+
+func roundMode(mode string) (func(float64) float64, error) {
+	switch mode {
+	case roundHalfEven:
+		return math.RoundToEven, nil
+	case roundHalfUp:
+		return math.Round, nil
+	case roundHalfDown:
+		return func(x float64) float64 {
+			if x < 0 {
+				return -math.Ceil(-x - 0.5)
+			}
+			return math.Ceil(x - 0.5)
+		}, nil
+	case roundUp:
+		return func(x float64) float64 {
+			if x < 0 {
+				return math.Floor(x)
+			}
+			return math.Ceil(x)
+		}, nil
+	case roundDown:
+		return math.Trunc, nil
+	case roundCeil:
+		return math.Ceil, nil
+	case roundFloor:
+		return math.Floor, nil
+	default:
+		return nil, fmt.Errorf("%s: unknown rounding mode", mode)
+	}
+}
+
+func pickNumber(args []types.Primitive, better func(float64, float64) bool) (types.Primitive, error) {
+	if len(args) == 1 {
+		if _, ok := slices.Fst(args).(types.Iterable); ok {
+			list, err := collect(slices.Fst(args))
+			if err != nil {
+				return nil, err
+			}
+			args = list
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no enough argument given")
+	}
+	var (
+		res  types.Primitive
+		curr float64
+	)
+	for i := range args {
+		x, err := getFloat(args[i])
+		if err != nil {
+			return nil, err
+		}
+		if res == nil || better(x, curr) || math.IsNaN(x) {
+			res, curr = args[i], x
+		}
+	}
+	return res, nil
+}
+
+func reduceInt(args []types.Primitive, fn func(int64, int64) (int64, bool)) (types.Primitive, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no enough argument given")
+	}
+	var res int64
+	for i := range args {
+		x, ok := args[i].Raw().(int64)
+		if !ok {
+			return nil, fmt.Errorf("%w: integer expected, got %s", types.ErrIncompatible, args[i])
+		}
+		if x == math.MinInt64 {
+			return nil, overflowError()
+		}
+		if x < 0 {
+			x = -x
+		}
+		if i == 0 {
+			res = x
+			continue
+		}
+		if res, ok = fn(res, x); !ok {
+			return nil, overflowError()
+		}
+	}
+	return types.CreateInt(res), nil
+}
+
+func testFloat(args []types.Primitive, fn func(float64) bool) (types.Primitive, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	x, err := getFloat(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateBool(fn(x)), nil
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func powInt(x, y int64) (types.Primitive, error) {
+	switch {
+	case x == 1 || y == 0:
+		return types.CreateInt(1), nil
+	case x == 0:
+		return types.CreateInt(0), nil
+	case x == -1:
+		if y%2 == 0 {
+			return types.CreateInt(1), nil
+		}
+		return types.CreateInt(-1), nil
+	}
+	var (
+		res = int64(1)
+		ok  bool
+	)
+	for {
+		if y&1 == 1 {
+			if res, ok = mulInt(res, x); !ok {
+				return nil, overflowError()
+			}
+		}
+		if y >>= 1; y == 0 {
+			break
+		}
+		if x, ok = mulInt(x, x); !ok {
+			return nil, overflowError()
+		}
+	}
+	return types.CreateInt(res), nil
+}
+
+func overflowError() error {
+	return fmt.Errorf("%w: integer overflow", types.ErrIncompatible)
+}
+
+func mulInt(x, y int64) (int64, bool) {
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+	if (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+		return 0, false
+	}
+	res := x * y
+	return res, res/y == x
+}
+
+func floatToInt(x float64) (types.Primitive, error) {
+	if math.IsNaN(x) || math.IsInf(x, 0) || x >= math.MaxInt64 || x < math.MinInt64 {
+		return nil, fmt.Errorf("%w: %v can not be converted to integer", types.ErrIncompatible, x)
+	}
+	return types.CreateInt(int64(x)), nil
+}
+
+func getFloat(p types.Primitive) (float64, error) {
+	switch x := p.Raw().(type) {
+	case int64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	default:
+		return 0, numberError(p)
+	}
+}
+
+func numberError(p types.Primitive) error {
+	name, err := types.Type(p)
+	if err != nil {
+		name = "unknown"
+	}
+	return fmt.Errorf("%w: number expected, got %s", types.ErrIncompatible, name)
+}
+
+func toDegrees(x float64) float64 {
+	return x * 180 / math.Pi
+}
+
+func toRadians(x float64) float64 {
+	return x * math.Pi / 180
+}
@@ -0,0 +1,37 @@
+package builtins_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/midbel/buddy/eval"
+)
+
+func TestMathOverflow(t *testing.T) {
+	tests := []struct {
+		Expr string
+		Want string
+	}{
+		{Expr: `math.gcd(12, -18)`, Want: "6"},
+		{Expr: `math.lcm(4, 6, 10)`, Want: "60"},
+		{Expr: `math.lcm(9223372036854775807, 2)`, Want: "integer overflow"},
+		{Expr: `math.gcd(-9223372036854775807 - 1, 2)`, Want: "integer overflow"},
+		{Expr: `math.lcm(-9223372036854775807 - 1, 1)`, Want: "integer overflow"},
+		{Expr: `math.max([3, 9, 2])`, Want: "9"},
+		{Expr: `math.max(json.lines("1 2 x"))`, Want: "decode error"},
+	}
+	for _, tt := range tests {
+		src := "import math\nimport json\n" +
+			"let res = nil\n" +
+			"try {\n\tres = " + tt.Expr + "\n} catch(e) {\n\tres = e[\"message\"]\n}\n" +
+			"res"
+		res, err := eval.Default().EvalString(src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.Expr, err)
+			continue
+		}
+		if got := res.String(); !strings.Contains(got, tt.Want) {
+			t.Errorf("%s: want %q, got %q", tt.Expr, tt.Want, got)
+		}
+	}
+}
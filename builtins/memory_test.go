@@ -0,0 +1,67 @@
+package builtins_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/midbel/buddy/builtins"
+	"github.com/midbel/buddy/eval"
+)
+
+func TestBuiltinMemory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(file, []byte(strings.Repeat("x", 1<<16)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []string{
+		`fs.read(file)`,
+		`os.exec(["cat", file])`,
+		`json.parse("[` + strings.Repeat("1,", 1<<12) + `1]")`,
+	}
+	for _, expr := range tests {
+		src := "import fs\nimport os\nimport json\n" +
+			"let file = \"" + file + "\"\n" +
+			"try {\n\t" + expr + "\n} catch(e) {\n\tnil\n}\n"
+
+		bud := eval.Default()
+		bud.MaxMemory = 1 << 12
+		_, err := bud.EvalString(src)
+		if !errors.Is(err, eval.ErrMemoryLimit) {
+			t.Errorf("%s: expected %q error, got %v", expr, eval.ErrMemoryLimit, err)
+		}
+	}
+}
+
+func TestReadTooLarge(t *testing.T) {
+	defer func(size int) {
+		builtins.MaxStringSize = size
+	}(builtins.MaxStringSize)
+	builtins.MaxStringSize = 1 << 10
+
+	file := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(file, []byte(strings.Repeat("x", 1<<12)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []string{
+		`fs.read(file)`,
+		`os.exec(["cat", file])`,
+	}
+	for _, expr := range tests {
+		src := "import fs\nimport os\n" +
+			"let file = \"" + file + "\"\n" +
+			"let res = nil\n" +
+			"try {\n\tres = " + expr + "\n} catch(e) {\n\tres = e[\"message\"]\n}\n" +
+			"res"
+		res, err := eval.Default().EvalString(src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", expr, err)
+			continue
+		}
+		if got := res.String(); !strings.Contains(got, builtins.ErrTooLarge.Error()) {
+			t.Errorf("%s: want %q error, got %q", expr, builtins.ErrTooLarge, got)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package builtins
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/midbel/buddy/types"
+	"github.com/midbel/slices"
+)
+
+var (
+	ErrExecDisabled    = errors.New("process execution disabled")
+	ErrEnvironDisabled = errors.New("process environment access disabled")
+)
+
+type Process interface {
+	Arguments() []string
+	ExecAllowed() bool
+	EnvironAllowed() bool
+	AtExit(types.Primitive) error
+}
+
+var osmod = Module{
+	Name: "os",
+	Builtins: map[string]Builtin{
+		"getenv": {
+			Name: "getenv",
+			Params: []types.Argument{
+				types.PosArg("name", 1),
+				types.NamedArg("default", 2, types.CreateNil()),
+			},
+			Run: runGetenv,
+		},
+		"setenv": {
+			Name: "setenv",
+			Params: []types.Argument{
+				types.PosArg("name", 1),
+				types.PosArg("value", 2),
+			},
+			Run: runSetenv,
+		},
+		"unsetenv": {
+			Name: "unsetenv",
+			Params: []types.Argument{
+				types.PosArg("name", 1),
+			},
+			Run: runUnsetenv,
+		},
+		"chdir": {
+			Name: "chdir",
+			Params: []types.Argument{
+				types.PosArg("dir", 1),
+			},
+			Run: runChdir,
+		},
+		"atexit": {
+			Name: "atexit",
+			Params: []types.Argument{
+				types.PosArg("fn", 1),
+			},
+			Run: runAtExit,
+		},
+		"exec": {
+			Name: "exec",
+			Params: []types.Argument{
+				types.PosArg("command", 1),
+				types.NamedArg("input", 2, types.CreateNil()),
+				types.NamedArg("dir", 3, types.CreateNil()),
+				types.NamedArg("env", 4, types.CreateNil()),
+			},
+			Run: runExec,
+		},
+	},
+	Attrs: map[string]BuiltinFunc{
+		"args": runArgs,
+		"env":  runEnv,
+		"cwd":  runCwd,
+	},
+}
+
+func runArgs(ctx types.Context, _ ...types.Primitive) (types.Primitive, error) {
+	var args []string
+	if p, ok := ctx.(Process); ok {
+		args = p.Arguments()
+	} else {
+		args = os.Args
+	}
+	return allocateStrings(ctx, args)
+}
+
+func runEnv(ctx types.Context, _ ...types.Primitive) (types.Primitive, error) {
+	if err := checkEnviron(ctx); err != nil {
+		return nil, err
+	}
+	var (
+		dict = types.CreateDict()
+		err  error
+	)
+	env := os.Environ()
+	if err := reserve(ctx, len(env)*types.SizeEntry); err != nil {
+		return nil, err
+	}
+	for _, e := range env {
+		if err := reserveString(ctx, len(e)); err != nil {
+			return nil, err
+		}
+		k, v, _ := strings.Cut(e, "=")
+		dict, err = dict.(types.Container).Set(types.CreateString(k), types.CreateString(v))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dict, nil
+}
+
+func runCwd(ctx types.Context, _ ...types.Primitive) (types.Primitive, error) {
+	if err := checkEnviron(ctx); err != nil {
+		return nil, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateString(dir), nil
+}
+
+func runGetenv(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if err := checkEnviron(ctx); err != nil {
+		return nil, err
+	}
+	name, err := getString(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return slices.Snd(args), nil
+	}
+	return types.CreateString(value), nil
+}
+
+func runSetenv(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if err := checkEnviron(ctx); err != nil {
+		return nil, err
+	}
+	name, err := getString(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return nil, os.Setenv(name, slices.Snd(args).String())
+}
+
+func runUnsetenv(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if err := checkEnviron(ctx); err != nil {
+		return nil, err
+	}
+	name, err := getString(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return nil, os.Unsetenv(name)
+}
+
+func runChdir(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if err := checkEnviron(ctx); err != nil {
+		return nil, err
+	}
+	dir, err := getFile(ctx, slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return nil, os.Chdir(dir)
+}
+
+func runAtExit(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	p, ok := ctx.(Process)
+	if !ok {
+		return nil, fmt.Errorf("exit hooks not supported")
+	}
+	return nil, p.AtExit(slices.Fst(args))
+}
+
+func checkEnviron(ctx types.Context) error {
+	if p, ok := ctx.(Process); ok && !p.EnvironAllowed() {
+		return ErrEnvironDisabled
+	}
+	return nil
+}
+
+func runExec(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if p, ok := ctx.(Process); ok && !p.ExecAllowed() {
+		return nil, ErrExecDisabled
+	}
+	command, err := getCommand(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	sub, cancel := processContext(ctx)
+	defer cancel()
+
+	var (
+		stdout outputBuffer
+		stderr outputBuffer
+		cmd    = exec.CommandContext(sub, slices.Fst(command), slices.Rest(command)...)
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if in := args[1]; !types.IsNil(in) {
+		cmd.Stdin = strings.NewReader(in.String())
+	}
+	if dir := args[2]; !types.IsNil(dir) {
+		if cmd.Dir, err = getFile(ctx, dir); err != nil {
+			return nil, err
+		}
+	}
+	if env := args[3]; !types.IsNil(env) {
+		if cmd.Env, err = getEnviron(env); err != nil {
+			return nil, err
+		}
+	}
+	code := 0
+	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if !errors.As(err, &exit) {
+			return nil, err
+		}
+		if c, ok := ctx.(Canceler); ok {
+			if err := c.Interrupted(); err != nil {
+				return nil, err
+			}
+		}
+		code = exit.ExitCode()
+	}
+	if stdout.full || stderr.full {
+		return nil, sizeError()
+	}
+	if err := reserveString(ctx, stdout.Len()+stderr.Len()); err != nil {
+		return nil, err
+	}
+	return createDict([]entry{
+		{Key: "stdout", Value: types.CreateString(stdout.String())},
+		{Key: "stderr", Value: types.CreateString(stderr.String())},
+		{Key: "code", Value: types.CreateInt(int64(code))},
+	})
+}
+
+type outputBuffer struct {
+	bytes.Buffer
+	full bool
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > MaxStringSize {
+		b.full = true
+		return 0, sizeError()
+	}
+	return b.Buffer.Write(p)
+}
+
+func processContext(ctx types.Context) (context.Context, context.CancelFunc) {
+	sub, cancel := context.WithCancel(context.Background())
+	c, ok := ctx.(Canceler)
+	if !ok || c.Done() == nil {
+		return sub, cancel
+	}
+	go func() {
+		select {
+		case <-c.Done():
+			cancel()
+		case <-sub.Done():
+		}
+	}()
+	return sub, cancel
+}
+
+func getCommand(p types.Primitive) ([]string, error) {
+	var list []string
+	switch x := p.(type) {
+	case types.String:
+		list = strings.Fields(x.String())
+	case types.Array:
+		values, err := collect(x)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range values {
+			str, err := getString(a)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, str)
+		}
+	default:
+		return nil, typeError(p, types.CreateArray(nil))
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return list, nil
+}
+
+func getEnviron(p types.Primitive) ([]string, error) {
+	d, ok := p.Raw().(map[types.Primitive]types.Primitive)
+	if !ok {
+		return nil, typeError(p, types.CreateDict())
+	}
+	var env []string
+	for k, v := range d {
+		env = append(env, fmt.Sprintf("%s=%s", k.String(), v.String()))
+	}
+	return env, nil
+}
@@ -0,0 +1,149 @@
+package builtins
+
+import (
+	"path/filepath"
+
+	"github.com/midbel/buddy/types"
+	"github.com/midbel/slices"
+)
+
+var pathmod = Module{
+	Name: "path",
+	Builtins: map[string]Builtin{
+		"join": {
+			Name:     "join",
+			Variadic: true,
+			Run:      runJoinPath,
+		},
+		"base": {
+			Name: "base",
+			Params: []types.Argument{
+				types.PosArg("path", 1),
+			},
+			Run: runBase,
+		},
+		"dir": {
+			Name: "dir",
+			Params: []types.Argument{
+				types.PosArg("path", 1),
+			},
+			Run: runDir,
+		},
+		"ext": {
+			Name: "ext",
+			Params: []types.Argument{
+				types.PosArg("path", 1),
+			},
+			Run: runExt,
+		},
+		"clean": {
+			Name: "clean",
+			Params: []types.Argument{
+				types.PosArg("path", 1),
+			},
+			Run: runClean,
+		},
+		"abs": {
+			Name: "abs",
+			Params: []types.Argument{
+				types.PosArg("path", 1),
+			},
+			Run: runAbsPath,
+		},
+		"is_abs": {
+			Name: "is_abs",
+			Params: []types.Argument{
+				types.PosArg("path", 1),
+			},
+			Run: runIsAbs,
+		},
+		"rel": {
+			Name: "rel",
+			Params: []types.Argument{
+				types.PosArg("base", 1),
+				types.PosArg("path", 2),
+			},
+			Run: runRel,
+		},
+		"split": {
+			Name: "split",
+			Params: []types.Argument{
+				types.PosArg("path", 1),
+			},
+			Run: runSplitPath,
+		},
+	},
+}
+
+func runJoinPath(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, len(args))
+	if err != nil {
+		return nil, err
+	}
+	return allocate(ctx, types.CreateString(filepath.Join(list...)), nil)
+}
+
+func runBase(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return withPath(slices.Fst(args), filepath.Base)
+}
+
+func runDir(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return withPath(slices.Fst(args), filepath.Dir)
+}
+
+func runExt(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return withPath(slices.Fst(args), filepath.Ext)
+}
+
+func runClean(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return withPath(slices.Fst(args), filepath.Clean)
+}
+
+func runAbsPath(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	file, err := getString(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateString(abs), nil
+}
+
+func runIsAbs(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	file, err := getString(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateBool(filepath.IsAbs(file)), nil
+}
+
+func runRel(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(list[0], list[1])
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateString(rel), nil
+}
+
+func runSplitPath(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	file, err := getString(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	dir, base := filepath.Split(file)
+	return allocateStrings(ctx, []string{dir, base})
+}
+
+func withPath(p types.Primitive, fn func(string) string) (types.Primitive, error) {
+	file, err := getString(p)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateString(fn(file)), nil
+}
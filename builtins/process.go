@@ -10,13 +10,33 @@ import (
 
 var ErrExit = errors.New("exit")
 
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("%s: status %d", ErrExit, e.code)
+}
+
+func (e exitError) Is(err error) bool {
+	return err == ErrExit
+}
+
 func IsExit(err error) bool {
 	return errors.Is(err, ErrExit)
 }
 
-func runExit(args ...types.Primitive) (types.Primitive, error) {
+func ExitCode(err error) (int, bool) {
+	var e exitError
+	if !errors.As(err, &e) {
+		return 0, false
+	}
+	return e.code, true
+}
+
+func runExit(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) == 0 {
-		return types.CreateInt(0), ErrExit
+		return types.CreateInt(0), exitError{}
 	}
 	if len(args) != 1 {
 		return nil, fmt.Errorf("exit: not enough argument give")
@@ -30,5 +50,5 @@ func runExit(args ...types.Primitive) (types.Primitive, error) {
 	default:
 		return nil, fmt.Errorf("number expected, got %T", slices.Fst(args))
 	}
-	return types.CreateInt(code), ErrExit
+	return types.CreateInt(code), exitError{code: int(code)}
 }
@@ -0,0 +1,240 @@
+package builtins
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+
+	"github.com/midbel/buddy/types"
+)
+
+const maxPatterns = 256
+
+var patterns = struct {
+	sync.Mutex
+	cache map[string]*regexp.Regexp
+}{
+	cache: make(map[string]*regexp.Regexp),
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patterns.Lock()
+	defer patterns.Unlock()
+	if re, ok := patterns.cache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", types.ErrIncompatible, err)
+	}
+	if len(patterns.cache) >= maxPatterns {
+		for k := range patterns.cache {
+			delete(patterns.cache, k)
+			break
+		}
+	}
+	patterns.cache[pattern] = re
+	return re, nil
+}
+
+var regexmod = Module{
+	Name: "regex",
+	Builtins: map[string]Builtin{
+		"match": {
+			Name: "match",
+			Params: []types.Argument{
+				types.PosArg("pattern", 1),
+				types.PosArg("str", 2),
+			},
+			Run: runMatch,
+		},
+		"find": {
+			Name: "find",
+			Params: []types.Argument{
+				types.PosArg("pattern", 1),
+				types.PosArg("str", 2),
+			},
+			Run: runFind,
+		},
+		"find_all": {
+			Name:     "find_all",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("pattern", 1),
+				types.PosArg("str", 2),
+			},
+			Run: runFindAll,
+		},
+		"groups": {
+			Name: "groups",
+			Params: []types.Argument{
+				types.PosArg("pattern", 1),
+				types.PosArg("str", 2),
+			},
+			Run: runGroups,
+		},
+		"replace": {
+			Name: "replace",
+			Params: []types.Argument{
+				types.PosArg("pattern", 1),
+				types.PosArg("str", 2),
+				types.PosArg("repl", 3),
+			},
+			Run: runReplaceRegex,
+		},
+		"split": {
+			Name:     "split",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("pattern", 1),
+				types.PosArg("str", 2),
+			},
+			Run: runSplitRegex,
+		},
+		"escape": {
+			Name: "escape",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+			},
+			Run: runEscape,
+		},
+	},
+}
+
+func runMatch(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	re, str, err := getPattern(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateBool(re.MatchString(str)), nil
+}
+
+func runFind(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	re, str, err := getPattern(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	loc := re.FindStringIndex(str)
+	if loc == nil {
+		return types.CreateNil(), nil
+	}
+	return types.CreateString(str[loc[0]:loc[1]]), nil
+}
+
+func runFindAll(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	re, str, err := getPattern(args, 3)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := getLimit(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return allocateStrings(ctx, re.FindAllString(str, limit))
+}
+
+func runGroups(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	re, str, err := getPattern(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	match := re.FindStringSubmatchIndex(str)
+	if match == nil {
+		return types.CreateNil(), nil
+	}
+	dict := types.CreateDict()
+	for i, name := range re.SubexpNames() {
+		var (
+			key types.Primitive = types.CreateInt(int64(i))
+			val types.Primitive = types.CreateNil()
+		)
+		if name != "" {
+			key = types.CreateString(name)
+		}
+		if beg, end := match[2*i], match[2*i+1]; beg >= 0 {
+			val = types.CreateString(str[beg:end])
+		}
+		if dict, err = dict.(types.Container).Set(key, val); err != nil {
+			return nil, err
+		}
+	}
+	return dict, nil
+}
+
+func runReplaceRegex(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	re, str, err := getPattern(args, 3)
+	if err != nil {
+		return nil, err
+	}
+	repl, err := getString(args[2])
+	if err != nil {
+		return nil, err
+	}
+	var (
+		buf  []byte
+		last int
+	)
+	for _, m := range re.FindAllStringSubmatchIndex(str, -1) {
+		buf = append(buf, str[last:m[0]]...)
+		buf = re.ExpandString(buf, repl, str, m)
+		last = m[1]
+		if len(buf) > MaxStringSize {
+			break
+		}
+	}
+	buf = append(buf, str[last:]...)
+	if err := reserveString(ctx, len(buf)); err != nil {
+		return nil, err
+	}
+	return types.CreateString(string(buf)), nil
+}
+
+func runSplitRegex(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	re, str, err := getPattern(args, 3)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := getLimit(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return allocateStrings(ctx, re.Split(str, limit))
+}
+
+func runEscape(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return allocate(ctx, types.CreateString(regexp.QuoteMeta(list[0])), nil)
+}
+
+func getLimit(args []types.Primitive, ix int) (int, error) {
+	n, err := getOptionalInt(args, ix, -1)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 || n < -1 || n > math.MaxInt32 {
+		return 0, fmt.Errorf("%d: limit should be greater than zero or -1", n)
+	}
+	return int(n), nil
+}
+
+func getPattern(args []types.Primitive, n int) (*regexp.Regexp, string, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(args) > n {
+		return nil, "", fmt.Errorf("invalid number of arguments")
+	}
+	re, err := compilePattern(list[0])
+	if err != nil {
+		return nil, "", err
+	}
+	return re, list[1], nil
+}
@@ -3,6 +3,7 @@ package builtins
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/midbel/buddy/types"
 	"github.com/midbel/slices"
@@ -33,10 +34,172 @@ var strmod = Module{
 			},
 			Run: runFormat,
 		},
+		"split": {
+			Name:     "split",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("sep", 2),
+			},
+			Run: runSplit,
+		},
+		"join": {
+			Name: "join",
+			Params: []types.Argument{
+				types.PosArg("list", 1),
+				types.PosArg("sep", 2),
+			},
+			Run: runJoin,
+		},
+		"trim": {
+			Name:     "trim",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+			},
+			Run: runTrim,
+		},
+		"trim_left": {
+			Name:     "trim_left",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+			},
+			Run: runTrimLeft,
+		},
+		"trim_right": {
+			Name:     "trim_right",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+			},
+			Run: runTrimRight,
+		},
+		"trim_prefix": {
+			Name: "trim_prefix",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("prefix", 2),
+			},
+			Run: runTrimPrefix,
+		},
+		"trim_suffix": {
+			Name: "trim_suffix",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("suffix", 2),
+			},
+			Run: runTrimSuffix,
+		},
+		"has_prefix": {
+			Name: "has_prefix",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("prefix", 2),
+			},
+			Run: runHasPrefix,
+		},
+		"has_suffix": {
+			Name: "has_suffix",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("suffix", 2),
+			},
+			Run: runHasSuffix,
+		},
+		"contains": {
+			Name: "contains",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("sub", 2),
+			},
+			Run: runContains,
+		},
+		"count": {
+			Name: "count",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("sub", 2),
+			},
+			Run: runCount,
+		},
+		"index": {
+			Name: "index",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("sub", 2),
+			},
+			Run: runIndex,
+		},
+		"last_index": {
+			Name: "last_index",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("sub", 2),
+			},
+			Run: runLastIndex,
+		},
+		"replace": {
+			Name:     "replace",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("old", 2),
+				types.PosArg("new", 3),
+			},
+			Run: runReplace,
+		},
+		"repeat": {
+			Name: "repeat",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("count", 2),
+			},
+			Run: runRepeat,
+		},
+		"pad_left": {
+			Name:     "pad_left",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("width", 2),
+			},
+			Run: runPadLeft,
+		},
+		"pad_right": {
+			Name:     "pad_right",
+			Variadic: true,
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.PosArg("width", 2),
+			},
+			Run: runPadRight,
+		},
+		"len": {
+			Name: "len",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+			},
+			Run: runRuneLen,
+		},
+		"chars": {
+			Name: "chars",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+			},
+			Run: runChars,
+		},
+		"reverse": {
+			Name: "reverse",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+			},
+			Run: runReverse,
+		},
 	},
 }
 
-func runFormat(args ...types.Primitive) (types.Primitive, error) {
+func runFormat(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("no enough argument given")
 	}
@@ -49,10 +212,10 @@ func runFormat(args ...types.Primitive) (types.Primitive, error) {
 		list = append(list, a.Raw())
 	}
 	str = fmt.Sprintf(str, list...)
-	return types.CreateString(str), nil
+	return allocate(ctx, types.CreateString(str), nil)
 }
 
-func runLower(args ...types.Primitive) (types.Primitive, error) {
+func runLower(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("no enough argument given")
 	}
@@ -61,10 +224,10 @@ func runLower(args ...types.Primitive) (types.Primitive, error) {
 		return nil, fmt.Errorf("incompatible type: string expected")
 	}
 	str = strings.ToLower(str)
-	return types.CreateString(str), nil
+	return allocate(ctx, types.CreateString(str), nil)
 }
 
-func runUpper(args ...types.Primitive) (types.Primitive, error) {
+func runUpper(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("no enough argument given")
 	}
@@ -73,5 +236,296 @@ func runUpper(args ...types.Primitive) (types.Primitive, error) {
 		return nil, fmt.Errorf("incompatible type: string expected")
 	}
 	str = strings.ToUpper(str)
-	return types.CreateString(str), nil
+	return allocate(ctx, types.CreateString(str), nil)
+}
+
+func runSplit(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := getOptionalInt(args, 2, -1)
+	if err != nil {
+		return nil, err
+	}
+	return allocateStrings(ctx, strings.SplitN(list[0], list[1], int(limit)))
+}
+
+func runJoin(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	it, ok := slices.Fst(args).(types.Iterable)
+	if !ok {
+		return nil, types.IterationError(slices.Fst(args))
+	}
+	sep, err := getString(slices.Lst(args))
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	it.Iter(func(p types.Primitive) error {
+		list = append(list, p.String())
+		return nil
+	})
+	return allocate(ctx, types.CreateString(strings.Join(list, sep)), nil)
+}
+
+func runTrim(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return trimWith(args, strings.TrimSpace, strings.Trim)
+}
+
+func runTrimLeft(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	trim := func(str string) string {
+		return strings.TrimLeft(str, " \t\r\n\v\f")
+	}
+	return trimWith(args, trim, strings.TrimLeft)
+}
+
+func runTrimRight(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	trim := func(str string) string {
+		return strings.TrimRight(str, " \t\r\n\v\f")
+	}
+	return trimWith(args, trim, strings.TrimRight)
+}
+
+func runTrimPrefix(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateString(strings.TrimPrefix(list[0], list[1])), nil
+}
+
+func runTrimSuffix(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateString(strings.TrimSuffix(list[0], list[1])), nil
+}
+
+func runHasPrefix(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateBool(strings.HasPrefix(list[0], list[1])), nil
+}
+
+func runHasSuffix(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateBool(strings.HasSuffix(list[0], list[1])), nil
+}
+
+func runContains(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateBool(strings.Contains(list[0], list[1])), nil
+}
+
+func runCount(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateInt(int64(strings.Count(list[0], list[1]))), nil
+}
+
+func runIndex(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return runeIndex(list[0], strings.Index(list[0], list[1])), nil
+}
+
+func runLastIndex(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return runeIndex(list[0], strings.LastIndex(list[0], list[1])), nil
+}
+
+func runReplace(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 3)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := getOptionalInt(args, 3, -1)
+	if err != nil {
+		return nil, err
+	}
+	return allocate(ctx, types.CreateString(strings.Replace(list[0], list[1], list[2], int(limit))), nil)
+}
+
+func runRepeat(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	list, err := getStrings(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	count, err := getOptionalInt(args, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("negative repeat count")
+	}
+	if n := len(list[0]); n > 0 && count > int64(MaxStringSize/n) {
+		return nil, sizeError()
+	}
+	if err := reserveString(ctx, len(list[0])*int(count)); err != nil {
+		return nil, err
+	}
+	return types.CreateString(strings.Repeat(list[0], int(count))), nil
+}
+
+func runPadLeft(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return padWith(ctx, args, func(str, fill string) string {
+		return fill + str
+	})
+}
+
+func runPadRight(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	return padWith(ctx, args, func(str, fill string) string {
+		return str + fill
+	})
+}
+
+func runRuneLen(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateInt(int64(utf8.RuneCountInString(list[0]))), nil
+}
+
+func runChars(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var chars []string
+	for _, r := range list[0] {
+		chars = append(chars, string(r))
+	}
+	return allocateStrings(ctx, chars)
+}
+
+func runReverse(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	rs := []rune(list[0])
+	for i, j := 0, len(rs)-1; i < j; i, j = i+1, j-1 {
+		rs[i], rs[j] = rs[j], rs[i]
+	}
+	return allocate(ctx, types.CreateString(string(rs)), nil)
+}
+
+func trimWith(args []types.Primitive, space func(string) string, cut func(string, string) string) (types.Primitive, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	list, err := getStrings(args, len(args))
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 1 {
+		return types.CreateString(space(list[0])), nil
+	}
+	return types.CreateString(cut(list[0], list[1])), nil
+}
+
+func padWith(ctx types.Context, args []types.Primitive, pad func(string, string) string) (types.Primitive, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("invalid number of arguments")
+	}
+	str, err := getString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	width, err := getOptionalInt(args, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	fill := " "
+	if len(args) == 3 {
+		if fill, err = getString(args[2]); err != nil {
+			return nil, err
+		}
+		if utf8.RuneCountInString(fill) != 1 {
+			return nil, fmt.Errorf("fill should be a single character")
+		}
+	}
+	if width > int64(MaxStringSize) {
+		return nil, sizeError()
+	}
+	diff := int(width) - utf8.RuneCountInString(str)
+	if diff <= 0 {
+		return types.CreateString(str), nil
+	}
+	if err := reserveString(ctx, len(str)+diff*len(fill)); err != nil {
+		return nil, err
+	}
+	return types.CreateString(pad(str, strings.Repeat(fill, diff))), nil
+}
+
+func runeIndex(str string, ix int) types.Primitive {
+	if ix < 0 {
+		return types.CreateInt(-1)
+	}
+	return types.CreateInt(int64(utf8.RuneCountInString(str[:ix])))
+}
+
+func stringArray(list []string) types.Primitive {
+	arr := make([]types.Primitive, len(list))
+	for i := range list {
+		arr[i] = types.CreateString(list[i])
+	}
+	return types.CreateArray(arr)
+}
+
+func getStrings(args []types.Primitive, n int) ([]string, error) {
+	if len(args) < n {
+		return nil, fmt.Errorf("no enough argument given")
+	}
+	list := make([]string, n)
+	for i := 0; i < n; i++ {
+		str, err := getString(args[i])
+		if err != nil {
+			return nil, err
+		}
+		list[i] = str
+	}
+	return list, nil
+}
+
+func getString(p types.Primitive) (string, error) {
+	str, ok := p.Raw().(string)
+	if !ok {
+		return "", typeError(p, types.CreateString(""))
+	}
+	return str, nil
+}
+
+func getOptionalInt(args []types.Primitive, ix int, def int64) (int64, error) {
+	if ix >= len(args) {
+		return def, nil
+	}
+	n, ok := args[ix].Raw().(int64)
+	if !ok {
+		return 0, typeError(args[ix], types.CreateInt(0))
+	}
+	return n, nil
 }
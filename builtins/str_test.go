@@ -0,0 +1,62 @@
+package builtins_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/midbel/buddy/builtins"
+	"github.com/midbel/buddy/eval"
+)
+
+func TestStringTooLarge(t *testing.T) {
+	defer func(size int) {
+		builtins.MaxStringSize = size
+	}(builtins.MaxStringSize)
+	builtins.MaxStringSize = 1 << 10
+
+	tests := []string{
+		`strings.repeat("ab", 9999999999)`,
+		`strings.repeat("ab", 600)`,
+		`strings.pad_left("ab", 9999999999)`,
+		`strings.pad_right("ab", 2000, "é")`,
+		`regex.replace(".", strings.repeat("a", 100), "$0$0$0$0$0$0$0$0$0$0$0")`,
+	}
+	for _, expr := range tests {
+		src := "import strings\nimport regex\n" +
+			"let res = nil\n" +
+			"try {\n\tres = " + expr + "\n} catch(e) {\n\tres = e[\"message\"]\n}\n" +
+			"res"
+		res, err := eval.Default().EvalString(src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", expr, err)
+			continue
+		}
+		if got := res.String(); !strings.Contains(got, builtins.ErrTooLarge.Error()) {
+			t.Errorf("%s: want %q error, got %q", expr, builtins.ErrTooLarge, got)
+		}
+	}
+}
+
+func TestStringWithinLimit(t *testing.T) {
+	tests := []struct {
+		Expr string
+		Want string
+	}{
+		{Expr: `strings.repeat("ab", 3)`, Want: "ababab"},
+		{Expr: `strings.pad_left("ab", 4, "*")`, Want: "**ab"},
+		{Expr: `strings.pad_right("ab", 4)`, Want: "ab  "},
+		{Expr: `regex.replace("(\\w)(\\d)", "a1-b2", "$2$1")`, Want: "1a-2b"},
+		{Expr: `regex.replace("x*", "abc", "-")`, Want: "-a-b-c-"},
+	}
+	for _, tt := range tests {
+		src := "import strings\nimport regex\n" + tt.Expr
+		res, err := eval.Default().EvalString(src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.Expr, err)
+			continue
+		}
+		if got := res.String(); got != tt.Want {
+			t.Errorf("%s: want %q, got %q", tt.Expr, tt.Want, got)
+		}
+	}
+}
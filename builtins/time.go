@@ -1,37 +1,475 @@
 package builtins
 
 import (
+	"fmt"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/midbel/buddy/types"
+	"github.com/midbel/slices"
 )
 
+type Canceler interface {
+	Done() <-chan struct{}
+	Interrupted() error
+}
+
+var layouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"rfc850":      time.RFC850,
+	"ansic":       time.ANSIC,
+	"unixdate":    time.UnixDate,
+	"kitchen":     time.Kitchen,
+	"stamp":       time.Stamp,
+	"date":        "2006-01-02",
+	"time":        "15:04:05",
+	"datetime":    "2006-01-02 15:04:05",
+}
+
 var timemod = Module{
 	Name: "time",
 	Builtins: map[string]Builtin{
 		"now": {
 			Name: "now",
-			Run:  runNow,
+			Params: []types.Argument{
+				types.NamedArg("tz", 1, types.CreateNil()),
+			},
+			Run: runNow,
 		},
 		"unix": {
 			Name: "unix",
-			Run:  runUnix,
+			Params: []types.Argument{
+				types.NamedArg("time", 1, types.CreateNil()),
+			},
+			Run: runUnix,
+		},
+		"from_unix": {
+			Name: "from_unix",
+			Params: []types.Argument{
+				types.PosArg("seconds", 1),
+				types.NamedArg("nanos", 2, types.CreateInt(0)),
+			},
+			Run: runFromUnix,
+		},
+		"date": {
+			Name: "date",
+			Params: []types.Argument{
+				types.PosArg("year", 1),
+				types.PosArg("month", 2),
+				types.PosArg("day", 3),
+				types.NamedArg("hour", 4, types.CreateInt(0)),
+				types.NamedArg("minute", 5, types.CreateInt(0)),
+				types.NamedArg("second", 6, types.CreateInt(0)),
+				types.NamedArg("tz", 7, types.CreateString("UTC")),
+			},
+			Run: runDate,
+		},
+		"parse": {
+			Name: "parse",
+			Params: []types.Argument{
+				types.PosArg("str", 1),
+				types.NamedArg("layout", 2, types.CreateString("rfc3339")),
+				types.NamedArg("tz", 3, types.CreateString("UTC")),
+			},
+			Run: runParse,
+		},
+		"format": {
+			Name: "format",
+			Params: []types.Argument{
+				types.PosArg("time", 1),
+				types.NamedArg("layout", 2, types.CreateString("rfc3339")),
+			},
+			Run: runFormatTime,
+		},
+		"in_zone": {
+			Name: "in_zone",
+			Params: []types.Argument{
+				types.PosArg("time", 1),
+				types.PosArg("tz", 2),
+			},
+			Run: runInZone,
+		},
+		"zone": {
+			Name: "zone",
+			Params: []types.Argument{
+				types.PosArg("time", 1),
+			},
+			Run: runZone,
+		},
+		"parts": {
+			Name: "parts",
+			Params: []types.Argument{
+				types.PosArg("time", 1),
+			},
+			Run: runParts,
+		},
+		"add_date": {
+			Name: "add_date",
+			Params: []types.Argument{
+				types.PosArg("time", 1),
+				types.NamedArg("years", 2, types.CreateInt(0)),
+				types.NamedArg("months", 3, types.CreateInt(0)),
+				types.NamedArg("days", 4, types.CreateInt(0)),
+			},
+			Run: runAddDate,
+		},
+		"truncate": {
+			Name: "truncate",
+			Params: []types.Argument{
+				types.PosArg("value", 1),
+				types.PosArg("unit", 2),
+			},
+			Run: runTruncateTime,
+		},
+		"round": {
+			Name: "round",
+			Params: []types.Argument{
+				types.PosArg("value", 1),
+				types.PosArg("unit", 2),
+			},
+			Run: runRoundTime,
+		},
+		"duration": {
+			Name: "duration",
+			Params: []types.Argument{
+				types.PosArg("value", 1),
+			},
+			Run: runDuration,
+		},
+		"since": {
+			Name: "since",
+			Params: []types.Argument{
+				types.PosArg("time", 1),
+			},
+			Run: runSince,
+		},
+		"until": {
+			Name: "until",
+			Params: []types.Argument{
+				types.PosArg("time", 1),
+			},
+			Run: runUntil,
+		},
+		"seconds": {
+			Name: "seconds",
+			Params: []types.Argument{
+				types.PosArg("duration", 1),
+			},
+			Run: runSeconds,
+		},
+		"sleep": {
+			Name: "sleep",
+			Params: []types.Argument{
+				types.PosArg("duration", 1),
+			},
+			Run: runSleep,
 		},
 	},
+	Values: map[string]types.Primitive{
+		"nanosecond":  types.CreateDuration(time.Nanosecond),
+		"microsecond": types.CreateDuration(time.Microsecond),
+		"millisecond": types.CreateDuration(time.Millisecond),
+		"second":      types.CreateDuration(time.Second),
+		"minute":      types.CreateDuration(time.Minute),
+		"hour":        types.CreateDuration(time.Hour),
+	},
+}
+
+func runNow(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	now := time.Now()
+	if z := slices.Fst(args); !types.IsNil(z) {
+		loc, err := getLocation(z)
+		if err != nil {
+			return nil, err
+		}
+		now = now.In(loc)
+	}
+	return types.CreateTime(now), nil
+}
+
+func runUnix(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	now := time.Now()
+	if t := slices.Fst(args); !types.IsNil(t) {
+		w, err := getTime(t)
+		if err != nil {
+			return nil, err
+		}
+		now = w
+	}
+	return types.CreateInt(now.Unix()), nil
+}
+
+func runFromUnix(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	var nanos int64
+	switch x := slices.Fst(args).Raw().(type) {
+	case int64:
+		nanos = x * int64(time.Second)
+	case float64:
+		nanos = int64(x * float64(time.Second))
+	default:
+		return nil, numberError(slices.Fst(args))
+	}
+	n, err := getOptionalInt(args, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(0, nanos+n).UTC()
+	return types.CreateTime(t), nil
+}
+
+func runDate(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	var parts [6]int
+	for i := range parts {
+		n, ok := args[i].Raw().(int64)
+		if !ok {
+			return nil, typeError(args[i], types.CreateInt(0))
+		}
+		parts[i] = int(n)
+	}
+	loc, err := getLocation(args[6])
+	if err != nil {
+		return nil, err
+	}
+	t := time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], parts[4], parts[5], 0, loc)
+	return types.CreateTime(t), nil
+}
+
+func runParse(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	list, err := getStrings(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := getLocation(args[2])
+	if err != nil {
+		return nil, err
+	}
+	t, err := time.ParseInLocation(getLayout(list[1]), list[0], loc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", types.ErrIncompatible, err)
+	}
+	return types.CreateTime(t), nil
+}
+
+func runFormatTime(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	t, err := getTime(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	layout, err := getString(slices.Snd(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateString(t.Format(getLayout(layout))), nil
+}
+
+func runInZone(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	t, err := getTime(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	loc, err := getLocation(slices.Snd(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateTime(t.In(loc)), nil
+}
+
+func runZone(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	t, err := getTime(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateString(t.Location().String()), nil
+}
+
+func runParts(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	t, err := getTime(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	name, offset := t.Zone()
+	return createDict([]entry{
+		{Key: "year", Value: types.CreateInt(int64(t.Year()))},
+		{Key: "month", Value: types.CreateInt(int64(t.Month()))},
+		{Key: "day", Value: types.CreateInt(int64(t.Day()))},
+		{Key: "hour", Value: types.CreateInt(int64(t.Hour()))},
+		{Key: "minute", Value: types.CreateInt(int64(t.Minute()))},
+		{Key: "second", Value: types.CreateInt(int64(t.Second()))},
+		{Key: "nanosecond", Value: types.CreateInt(int64(t.Nanosecond()))},
+		{Key: "weekday", Value: types.CreateString(t.Weekday().String())},
+		{Key: "yearday", Value: types.CreateInt(int64(t.YearDay()))},
+		{Key: "zone", Value: types.CreateString(name)},
+		{Key: "offset", Value: types.CreateInt(int64(offset))},
+	})
+}
+
+func runAddDate(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	t, err := getTime(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	var parts [3]int
+	for i := range parts {
+		n, err := getOptionalInt(args, i+1, 0)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = int(n)
+	}
+	return types.CreateTime(t.AddDate(parts[0], parts[1], parts[2])), nil
+}
+
+func runTruncateTime(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	unit, err := getDuration(slices.Snd(args))
+	if err != nil {
+		return nil, err
+	}
+	switch x := slices.Fst(args).(type) {
+	case types.Time:
+		return types.CreateTime(x.Time().Truncate(unit)), nil
+	case types.Duration:
+		return types.CreateDuration(x.Duration().Truncate(unit)), nil
+	default:
+		return nil, timeError(x)
+	}
+}
+
+func runRoundTime(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	unit, err := getDuration(slices.Snd(args))
+	if err != nil {
+		return nil, err
+	}
+	switch x := slices.Fst(args).(type) {
+	case types.Time:
+		return types.CreateTime(x.Time().Round(unit)), nil
+	case types.Duration:
+		return types.CreateDuration(x.Duration().Round(unit)), nil
+	default:
+		return nil, timeError(x)
+	}
+}
+
+func runDuration(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	d, err := getDuration(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateDuration(d), nil
+}
+
+func runSince(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	t, err := getTime(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateDuration(time.Since(t)), nil
+}
+
+func runUntil(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	t, err := getTime(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateDuration(time.Until(t)), nil
+}
+
+func runSeconds(_ types.Context, args ...types.Primitive) (types.Primitive, error) {
+	d, err := getDuration(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	return types.CreateFloat(d.Seconds()), nil
+}
+
+func runSleep(ctx types.Context, args ...types.Primitive) (types.Primitive, error) {
+	d, err := getDuration(slices.Fst(args))
+	if err != nil {
+		return nil, err
+	}
+	if d <= 0 {
+		return nil, nil
+	}
+	c, ok := ctx.(Canceler)
+	if !ok || c.Done() == nil {
+		time.Sleep(d)
+		return nil, nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil, nil
+	case <-c.Done():
+		return nil, c.Interrupted()
+	}
+}
+
+func getLayout(layout string) string {
+	if str, ok := layouts[strings.ToLower(layout)]; ok {
+		return str
+	}
+	return layout
+}
+
+func getLocation(p types.Primitive) (*time.Location, error) {
+	name, err := getString(p)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: unknown timezone", types.ErrIncompatible, name)
+	}
+	return loc, nil
+}
+
+func getTime(p types.Primitive) (time.Time, error) {
+	switch x := p.(type) {
+	case types.Time:
+		return x.Time(), nil
+	case types.String:
+		t, err := time.Parse(time.RFC3339Nano, x.String())
+		if err != nil {
+			return t, fmt.Errorf("%w: %s", types.ErrIncompatible, err)
+		}
+		return t, nil
+	default:
+		return time.Time{}, timeError(p)
+	}
 }
 
-func runUnix(args ...types.Primitive) (types.Primitive, error) {
-	var (
-		now  = time.Now()
-		unix = now.Unix()
-	)
-	return types.CreateInt(unix), nil
+func getDuration(p types.Primitive) (time.Duration, error) {
+	switch x := p.(type) {
+	case types.Duration:
+		return x.Duration(), nil
+	case types.String:
+		d, err := time.ParseDuration(x.String())
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s", types.ErrIncompatible, err)
+		}
+		return d, nil
+	case types.Int:
+		return time.Duration(x.Raw().(int64)) * time.Second, nil
+	case types.Float:
+		return time.Duration(x.Raw().(float64) * float64(time.Second)), nil
+	default:
+		return 0, timeError(p)
+	}
 }
 
-func runNow(args ...types.Primitive) (types.Primitive, error) {
-	var (
-		now = time.Now()
-		str = now.Format(time.RFC3339)
-	)
-	return types.CreateString(str), nil
+func timeError(p types.Primitive) error {
+	name, err := types.Type(p)
+	if err != nil {
+		name = "unknown"
+	}
+	return fmt.Errorf("%w: time or duration expected, got %s", types.ErrIncompatible, name)
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/midbel/buddy/dap"
+)
+
+func main() {
+	listen := flag.String("listen", "", "serve on TCP address (localhost only) instead of stdio")
+	flag.Parse()
+
+	if *listen == "" {
+		if err := dap.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	addr, err := localAddr(*listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer ln.Close()
+	fmt.Fprintf(os.Stderr, "listening on %s", ln.Addr())
+	fmt.Fprintln(os.Stderr)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := dap.Serve(conn, conn); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		conn.Close()
+	}
+}
+
+func localAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	switch host {
+	case "":
+		host = "127.0.0.1"
+	case "localhost":
+	default:
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return "", fmt.Errorf("%s: only loopback addresses are allowed", host)
+		}
+	}
+	return net.JoinHostPort(host, port), nil
+}
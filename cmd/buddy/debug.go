@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/midbel/buddy/eval"
+	"github.com/midbel/buddy/types"
+)
+
+const debugHelp = `c, continue          resume execution until next breakpoint
+s, step             execute next statement, entering function calls
+n, next             execute next statement, stepping over function calls
+o, out              resume until the current function returns
+b, break [file:]line [if cond]
+                    set a breakpoint, optionally conditional
+d, delete id        remove a breakpoint
+bl, breaks          list breakpoints
+bt, stack           print the call stack
+f, frame n          select frame n for inspection
+up, down            select the caller or callee frame
+l, list             print source around the selected frame
+v, vars             print variables visible in the selected frame
+p, print expr       evaluate expression in the selected frame
+q, quit             abort the script
+h, help             show this message`
+
+const debugContext = 5
+
+var errQuit = errors.New("debugger: quit")
+
+type debugger struct {
+	*eval.Debugger
+	editor  *editor
+	file    string
+	level   int
+	last    string
+	sources map[string][]string
+}
+
+func runDebug(args []string) int {
+	var (
+		set    = flag.NewFlagSet("debug", flag.ExitOnError)
+		noexec = set.Bool("no-exec", false, "disable execution of external commands")
+		breaks []string
+	)
+	set.Func("b", "set breakpoint at [file:]line (can be repeated)", func(str string) error {
+		breaks = append(breaks, str)
+		return nil
+	})
+	set.Parse(args)
+	if set.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "debug: no script given")
+		return 2
+	}
+	r, err := os.Open(set.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer r.Close()
+
+	d := debugger{
+		Debugger: &eval.Debugger{},
+		editor:   newEditor(os.Stdin, os.Stdout, ""),
+		file:     set.Arg(0),
+		sources:  make(map[string][]string),
+	}
+	d.OnStop = d.stop
+	d.OnReturn = d.returned
+	for _, b := range breaks {
+		if err := d.addBreak(b); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	bud := eval.Default()
+	bud.Args = set.Args()
+	bud.DisableExec = *noexec
+	bud.Stdin = d.editor.reader
+	bud.Hook = d.Debugger
+	d.Resume(bud, eval.StepIn)
+
+	res, err := bud.Eval(r)
+	if errors.Is(err, errQuit) {
+		return 0
+	}
+	if err == nil && !types.IsNil(res) {
+		fmt.Fprintf(os.Stdout, "%+v", res)
+		fmt.Fprintln(os.Stdout)
+	}
+	bud.Hook = nil
+	return finish(bud, err)
+}
+
+func (d *debugger) stop(i *eval.Interpreter, stop eval.Stop) error {
+	if stop.Reason == eval.StopBreakpoint {
+		fmt.Fprintf(os.Stdout, "breakpoint %d hit", stop.Breakpoint.Id)
+		fmt.Fprintln(os.Stdout)
+	}
+	if stop.Err != nil {
+		fmt.Fprintf(os.Stderr, "breakpoint %d: %s", stop.Breakpoint.Id, stop.Err)
+		fmt.Fprintln(os.Stderr)
+	}
+	d.level = 0
+	d.show(stop.Frame)
+	return d.prompt(i)
+}
+
+func (d *debugger) returned(i *eval.Interpreter, frame eval.Frame, res types.Primitive) {
+	fmt.Fprintf(os.Stdout, "%s returned %v", frameName(frame), res)
+	fmt.Fprintln(os.Stdout)
+}
+
+func (d *debugger) prompt(i *eval.Interpreter) error {
+	for {
+		line, err := d.editor.ReadLine("(bud) ")
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err != nil {
+			return errQuit
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			line = d.last
+		}
+		if line == "" {
+			continue
+		}
+		d.editor.AddHistory(line)
+		d.last = line
+
+		var (
+			frames      = i.Frames()
+			cmd, arg, _ = strings.Cut(line, " ")
+		)
+		arg = strings.TrimSpace(arg)
+		switch cmd {
+		case "c", "continue":
+			d.Resume(i, eval.StepNone)
+			return nil
+		case "s", "step":
+			d.Resume(i, eval.StepIn)
+			return nil
+		case "n", "next":
+			d.Resume(i, eval.StepOver)
+			return nil
+		case "o", "out":
+			d.Resume(i, eval.StepOut)
+			return nil
+		case "b", "break":
+			if err := d.addBreak(arg); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		case "d", "delete":
+			d.deleteBreak(arg)
+		case "bl", "breaks":
+			d.listBreaks()
+		case "bt", "stack":
+			d.printStack(frames)
+		case "f", "frame":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n >= len(frames) {
+				fmt.Fprintf(os.Stderr, "%s: invalid frame", arg)
+				fmt.Fprintln(os.Stderr)
+				break
+			}
+			d.level = n
+			d.show(d.frame(frames))
+		case "up":
+			if d.level < len(frames)-1 {
+				d.level++
+			}
+			d.show(d.frame(frames))
+		case "down":
+			if d.level > 0 {
+				d.level--
+			}
+			d.show(d.frame(frames))
+		case "l", "list":
+			d.list(d.frame(frames))
+		case "v", "vars":
+			d.printVars(d.frame(frames))
+		case "p", "print":
+			res, err := i.Evaluate(len(frames)-1-d.level, arg)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				break
+			}
+			fmt.Fprintf(os.Stdout, "%v", res)
+			fmt.Fprintln(os.Stdout)
+		case "q", "quit":
+			return errQuit
+		case "h", "help":
+			fmt.Fprintln(os.Stdout, debugHelp)
+		default:
+			fmt.Fprintf(os.Stderr, "%s: unknown command (see help)", cmd)
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
+
+func (d *debugger) frame(frames []eval.Frame) eval.Frame {
+	return frames[len(frames)-1-d.level]
+}
+
+func (d *debugger) addBreak(spec string) error {
+	loc, cond, _ := strings.Cut(spec, " if ")
+	var (
+		file = d.file
+		line = strings.TrimSpace(loc)
+	)
+	if x := strings.LastIndexByte(line, ':'); x >= 0 {
+		file, line = line[:x], line[x+1:]
+	}
+	n, err := strconv.Atoi(line)
+	if err != nil {
+		return fmt.Errorf("%s: invalid breakpoint location", loc)
+	}
+	b, err := d.SetBreakpoint(file, n, strings.TrimSpace(cond))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "breakpoint %d at %s", b.Id, describe(b))
+	fmt.Fprintln(os.Stdout)
+	return nil
+}
+
+func (d *debugger) deleteBreak(arg string) {
+	id, _ := strconv.Atoi(arg)
+	if !d.ClearBreakpoint(id) {
+		fmt.Fprintf(os.Stderr, "%s: breakpoint not found", arg)
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func (d *debugger) listBreaks() {
+	for _, b := range d.Breakpoints() {
+		fmt.Fprintf(os.Stdout, "%d: %s", b.Id, describe(b))
+		fmt.Fprintln(os.Stdout)
+	}
+}
+
+func describe(b eval.Breakpoint) string {
+	str := fmt.Sprintf("%s:%d", b.File, b.Line)
+	if b.Cond != "" {
+		str += " if " + b.Cond
+	}
+	return str
+}
+
+func (d *debugger) printStack(frames []eval.Frame) {
+	for j := len(frames) - 1; j >= 0; j-- {
+		var (
+			f      = frames[j]
+			n      = len(frames) - 1 - j
+			marker = " "
+		)
+		if n == d.level {
+			marker = ">"
+		}
+		fmt.Fprintf(os.Stdout, "%s #%d %s at %s:%d", marker, n, frameName(f), f.File, f.Position.Line)
+		fmt.Fprintln(os.Stdout)
+	}
+}
+
+func (d *debugger) printVars(frame eval.Frame) {
+	if frame.Env == nil {
+		return
+	}
+	for _, n := range frame.Env.Names() {
+		v, _ := frame.Env.Value(n)
+		fmt.Fprintf(os.Stdout, "%s = %v", n, v)
+		fmt.Fprintln(os.Stdout)
+	}
+}
+
+func (d *debugger) show(frame eval.Frame) {
+	fmt.Fprintf(os.Stdout, "> %s:%d (%s)", frame.File, frame.Position.Line, frameName(frame))
+	fmt.Fprintln(os.Stdout)
+	lines := d.source(frame.File)
+	if n := frame.Position.Line; n > 0 && n <= len(lines) {
+		fmt.Fprintf(os.Stdout, "%4d  %s", n, lines[n-1])
+		fmt.Fprintln(os.Stdout)
+	}
+}
+
+func (d *debugger) list(frame eval.Frame) {
+	var (
+		lines = d.source(frame.File)
+		curr  = frame.Position.Line
+		start = curr - debugContext
+		end   = curr + debugContext
+	)
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	for n := start; n <= end; n++ {
+		marker := " "
+		if n == curr {
+			marker = ">"
+		}
+		fmt.Fprintf(os.Stdout, "%s%4d  %s", marker, n, lines[n-1])
+		fmt.Fprintln(os.Stdout)
+	}
+}
+
+func (d *debugger) source(file string) []string {
+	if lines, ok := d.sources[file]; ok {
+		return lines
+	}
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(buf), "\r\n", "\n"), "\n"), "\n")
+	d.sources[file] = lines
+	return lines
+}
+
+func frameName(f eval.Frame) string {
+	if f.Func == "" {
+		return f.Module
+	}
+	return f.Func
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/midbel/buddy/faults"
+	"github.com/midbel/buddy/format"
+)
+
+func runFormat(args []string) int {
+	var (
+		set   = flag.NewFlagSet("fmt", flag.ExitOnError)
+		write = set.Bool("w", false, "write result to source file instead of stdout")
+		diff  = set.Bool("d", false, "display diff instead of rewriting files")
+	)
+	set.Parse(args)
+	if set.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "fmt: can not use -w with standard input")
+			return 2
+		}
+		if err := formatFile(os.Stdin, "<stdin>", false, *diff); err != nil {
+			faults.PrintError(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	var code int
+	for _, file := range set.Args() {
+		r, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+			continue
+		}
+		err = formatFile(r, file, *write, *diff)
+		r.Close()
+		if err != nil {
+			faults.PrintError(os.Stderr, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+func formatFile(r io.Reader, file string, write, diff bool) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	res, err := format.Source(file, src)
+	if err != nil {
+		return err
+	}
+	if diff {
+		printDiff(os.Stdout, file, src, res)
+	}
+	if write {
+		if bytes.Equal(src, res) {
+			return nil
+		}
+		return os.WriteFile(file, res, 0o644)
+	}
+	if !diff {
+		_, err = os.Stdout.Write(res)
+	}
+	return err
+}
+
+const diffContext = 3
+
+type edit struct {
+	op   byte
+	old  int
+	new  int
+	line string
+}
+
+func printDiff(w io.Writer, file string, old, new []byte) {
+	var (
+		edits  = compareLines(splitLines(old), splitLines(new))
+		header bool
+	)
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "--- %s\n+++ %s\n", file, file)
+			header = true
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		last := i
+		for j := i + 1; j < len(edits) && j-last <= 2*diffContext+1; j++ {
+			if edits[j].op != ' ' {
+				last = j
+			}
+		}
+		end := last + diffContext + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		printHunk(w, edits[start:end])
+		i = end
+	}
+}
+
+func printHunk(w io.Writer, edits []edit) {
+	var (
+		first    = edits[0]
+		old, new int
+	)
+	for _, e := range edits {
+		switch e.op {
+		case ' ':
+			old++
+			new++
+		case '-':
+			old++
+		case '+':
+			new++
+		}
+	}
+	if old > 0 {
+		first.old++
+	}
+	if new > 0 {
+		first.new++
+	}
+	fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", first.old, old, first.new, new)
+	for _, e := range edits {
+		fmt.Fprintf(w, "%c%s\n", e.op, e.line)
+	}
+}
+
+func compareLines(old, new []string) []edit {
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var (
+		list []edit
+		i, j int
+	)
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && old[i] == new[j]:
+			list = append(list, edit{op: ' ', old: i, new: j, line: old[i]})
+			i++
+			j++
+		case j < len(new) && (i == len(old) || lcs[i][j+1] > lcs[i+1][j]):
+			list = append(list, edit{op: '+', old: i, new: j, line: new[j]})
+			j++
+		default:
+			list = append(list, edit{op: '-', old: i, new: j, line: old[i]})
+			i++
+		}
+	}
+	return list
+}
+
+func splitLines(str []byte) []string {
+	if len(str) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(str), "\n"), "\n")
+}
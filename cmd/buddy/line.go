@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxHistory = 1000
+
+var errInterrupted = errors.New("interrupted")
+
+type completer func(string) (int, []string)
+
+type editor struct {
+	in       *os.File
+	reader   *bufio.Reader
+	out      io.Writer
+	tty      bool
+	file     string
+	history  []string
+	complete completer
+}
+
+func newEditor(in *os.File, out io.Writer, file string) *editor {
+	e := editor{
+		in:     in,
+		reader: bufio.NewReader(in),
+		out:    out,
+		tty:    isTerminal(int(in.Fd())),
+		file:   file,
+	}
+	e.loadHistory()
+	return &e
+}
+
+func historyFile() string {
+	if file := os.Getenv("BUDDY_HISTORY"); file != "" {
+		return file
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".buddy_history")
+}
+
+func (e *editor) loadHistory() {
+	if e.file == "" {
+		return
+	}
+	buf, err := os.ReadFile(e.file)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+		os.WriteFile(e.file, []byte(strings.Join(e.history, "\n")+"\n"), 0o600)
+	}
+}
+
+func (e *editor) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+	if e.file == "" {
+		return
+	}
+	f, err := os.OpenFile(e.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+func (e *editor) ReadLine(prompt string) (string, error) {
+	io.WriteString(e.out, prompt)
+	if e.tty {
+		restore, err := makeRaw(int(e.in.Fd()))
+		if err == nil {
+			defer restore()
+			return e.edit(prompt)
+		}
+	}
+	line, err := e.reader.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(e.out)
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (e *editor) edit(prompt string) (string, error) {
+	var (
+		buf   []rune
+		pos   int
+		hist  = len(e.history)
+		saved []rune
+	)
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if n := len(buf) - pos; n > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", n)
+		}
+	}
+	recall := func(x int) {
+		if x < 0 || x > len(e.history) || x == hist {
+			return
+		}
+		if hist == len(e.history) {
+			saved = buf
+		}
+		hist = x
+		if hist == len(e.history) {
+			buf = saved
+		} else {
+			buf = []rune(e.history[hist])
+		}
+		pos = len(buf)
+	}
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			io.WriteString(e.out, "\r\n")
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			io.WriteString(e.out, "\r\n")
+			return string(buf), nil
+		case 3:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4:
+			if len(buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8:
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1:
+			pos = 0
+		case 5:
+			pos = len(buf)
+		case 2:
+			if pos > 0 {
+				pos--
+			}
+		case 6:
+			if pos < len(buf) {
+				pos++
+			}
+		case 11:
+			buf = buf[:pos]
+		case 21:
+			buf = append([]rune{}, buf[pos:]...)
+			pos = 0
+		case 23:
+			x := pos
+			for x > 0 && buf[x-1] == ' ' {
+				x--
+			}
+			for x > 0 && buf[x-1] != ' ' {
+				x--
+			}
+			buf = append(buf[:x], buf[pos:]...)
+			pos = x
+		case 12:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case 16:
+			recall(hist - 1)
+		case 14:
+			recall(hist + 1)
+		case '\t':
+			buf, pos = e.completeLine(buf, pos)
+		case 27:
+			switch e.escape() {
+			case 'A':
+				recall(hist - 1)
+			case 'B':
+				recall(hist + 1)
+			case 'C':
+				if pos < len(buf) {
+					pos++
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+				}
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(buf)
+			case 'X':
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if r < ' ' {
+				break
+			}
+			buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+			pos++
+		}
+		refresh()
+	}
+}
+
+func (e *editor) escape() rune {
+	r, _, err := e.reader.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+	r, _, err = e.reader.ReadRune()
+	if err != nil {
+		return 0
+	}
+	if r < '0' || r > '9' {
+		return r
+	}
+	code := r
+	for r != '~' {
+		if r, _, err = e.reader.ReadRune(); err != nil {
+			return 0
+		}
+		if r >= 'A' && r <= 'Z' {
+			return 0
+		}
+	}
+	switch code {
+	case '1', '7':
+		return 'H'
+	case '4', '8':
+		return 'F'
+	case '3':
+		return 'X'
+	default:
+		return 0
+	}
+}
+
+func (e *editor) completeLine(buf []rune, pos int) ([]rune, int) {
+	if e.complete == nil {
+		return buf, pos
+	}
+	start, list := e.complete(string(buf[:pos]))
+	if len(list) == 0 {
+		io.WriteString(e.out, "\a")
+		return buf, pos
+	}
+	word := string(buf[start:pos])
+	repl := commonPrefix(list)
+	if len(list) > 1 && repl == word {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(list, "  "))
+		return buf, pos
+	}
+	rest := append([]rune(repl), buf[pos:]...)
+	buf = append(buf[:start:start], rest...)
+	return buf, start + len([]rune(repl))
+}
+
+func commonPrefix(list []string) string {
+	prefix := list[0]
+	for _, str := range list[1:] {
+		for !strings.HasPrefix(str, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -1,87 +1,102 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"strings"
 
 	"github.com/midbel/buddy/builtins"
 	"github.com/midbel/buddy/eval"
 	"github.com/midbel/buddy/faults"
+	"github.com/midbel/buddy/types"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			return runFormat(os.Args[2:])
+		case "debug":
+			return runDebug(os.Args[2:])
+		case "test":
+			return runTest(os.Args[2:])
+		}
+	}
+	var (
+		vm      = flag.Bool("vm", false, "run with the bytecode compiler")
+		steps   = flag.Int("steps", 0, "maximum number of evaluation steps")
+		memory  = flag.Int("memory", 0, "approximate limit in bytes on the total size of values built during the run")
+		timeout = flag.Duration("timeout", 0, "maximum execution time")
+		noexec  = flag.Bool("no-exec", false, "disable execution of external commands")
+		noenv   = flag.Bool("no-env", false, "disable access to environment variables and working directory")
+		roots   []string
+	)
+	flag.Func("root", "restrict file access to directory (can be repeated)", func(dir string) error {
+		roots = append(roots, dir)
+		return nil
+	})
 	flag.Parse()
+
+	var cancel context.CancelFunc
+	create := func() *eval.Interpreter {
+		bud := eval.Default()
+		if *vm {
+			bud.Engine = eval.Bytecode
+		}
+		bud.MaxSteps = *steps
+		bud.MaxMemory = *memory
+		bud.Roots = roots
+		bud.Args = flag.Args()
+		bud.DisableExec = *noexec
+		bud.DisableEnviron = *noenv
+		if *timeout > 0 {
+			if cancel != nil {
+				cancel()
+			}
+			bud.Context, cancel = context.WithTimeout(context.Background(), *timeout)
+		}
+		return bud
+	}
+	defer func() {
+		if cancel != nil {
+			cancel()
+		}
+	}()
 	r, err := os.Open(flag.Arg(0))
 	if err != nil {
-		interactive(os.Stdin)
-		return
+		return interactive(create)
 	}
 	defer r.Close()
-	if err := execute(r); err != nil {
-		os.Exit(1)
-	}
+	return execute(create(), r)
 }
 
-func execute(r io.Reader) error {
-	res, err := eval.Eval(r)
-	if err != nil {
-		faults.PrintError(os.Stderr, err)
-		return err
-	}
-	if res != nil {
+func execute(bud *eval.Interpreter, r io.Reader) int {
+	res, err := bud.Eval(r)
+	if err == nil && !types.IsNil(res) {
 		fmt.Printf("%+v", res)
 		fmt.Println()
 	}
-	return nil
+	return finish(bud, err)
 }
 
-const (
-	in  = "\x1b[1;97min [%3d]:\x1b[0m "
-	ok  = "\x1b[1;92mout[%3d]:\x1b[0m %v"
-	nok = "\x1b[1;91mout[%3d]:\x1b[0m %s"
-)
-
-func interactive(r io.Reader) {
-	var (
-		cmd  int
-		scan = bufio.NewScanner(r)
-		env  = eval.Default()
-	)
-	cmd++
-	io.WriteString(os.Stdout, fmt.Sprintf(in, cmd))
-	for scan.Scan() {
-		line := scan.Text()
-		if strings.TrimSpace(line) == "" {
-			cmd++
-			io.WriteString(os.Stdout, fmt.Sprintf(in, cmd))
-			continue
+func finish(bud *eval.Interpreter, err error) int {
+	if err == nil || builtins.IsExit(err) {
+		if e := bud.Exit(); e != nil {
+			err = e
 		}
-		res, err := env.EvalString(line)
-		if err != nil {
-			if builtins.IsExit(err) {
-				var code int
-				switch c := res.Raw().(type) {
-				case int64:
-					code = int(c)
-				case float64:
-					code = int(c)
-				default:
-				}
-				os.Exit(code)
-			}
-			fmt.Fprintf(os.Stderr, fmt.Sprintf(nok, cmd, err))
-			fmt.Fprintln(os.Stderr)
-		} else {
-			fmt.Fprintf(os.Stdout, ok, cmd, res)
-			fmt.Fprintln(os.Stdout)
-			env.Define("_", res)
-		}
-		cmd++
-		io.WriteString(os.Stdout, fmt.Sprintf(in, cmd))
 	}
-	fmt.Fprintln(os.Stdout)
+	if code, ok := builtins.ExitCode(err); ok {
+		return code
+	}
+	if err != nil {
+		faults.PrintError(os.Stderr, err)
+		return 1
+	}
+	return 0
 }
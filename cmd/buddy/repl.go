@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/builtins"
+	"github.com/midbel/buddy/eval"
+	"github.com/midbel/buddy/faults"
+	"github.com/midbel/buddy/parse"
+	"github.com/midbel/buddy/scan"
+	"github.com/midbel/buddy/token"
+	"github.com/midbel/buddy/types"
+)
+
+type prompts struct {
+	in   string
+	more string
+	ok   string
+}
+
+var (
+	plain = prompts{
+		in:   "in [%3d]: ",
+		more: "     ...: ",
+		ok:   "out[%3d]: %v",
+	}
+	colored = prompts{
+		in:   "\x1b[1;97min [%3d]:\x1b[0m ",
+		more: "\x1b[1;97m     ...:\x1b[0m ",
+		ok:   "\x1b[1;92mout[%3d]:\x1b[0m %v",
+	}
+)
+
+const help = `:help         show this message
+:env          list variables, functions and imported modules
+:ast <code>   print the syntax tree of code without evaluating it
+:load <file>  evaluate file in the current session
+:reset        discard all definitions and start a new session
+:time         toggle display of evaluation time`
+
+var commands = []string{
+	":help",
+	":env",
+	":ast",
+	":load",
+	":reset",
+	":time",
+}
+
+var keywords = []string{
+	token.KwIf,
+	token.KwElse,
+	token.KwWhile,
+	token.KwReturn,
+	token.KwDef,
+	token.KwTrue,
+	token.KwFalse,
+	token.KwBreak,
+	token.KwContinue,
+	token.KwImport,
+	token.KwFrom,
+	token.KwFor,
+	token.KwAs,
+	token.KwIn,
+	token.KwAssert,
+	token.KwLet,
+	token.KwTry,
+	token.KwCatch,
+	token.KwFinally,
+	token.KwRaise,
+	token.KwNil,
+}
+
+type repl struct {
+	bud    *eval.Interpreter
+	create func() *eval.Interpreter
+	editor *editor
+	prompts
+	cmd    int
+	timing bool
+}
+
+func interactive(create func() *eval.Interpreter) int {
+	r := repl{
+		create:  create,
+		editor:  newEditor(os.Stdin, os.Stdout, historyFile()),
+		prompts: plain,
+	}
+	if isTerminal(int(os.Stdout.Fd())) {
+		r.prompts = colored
+	}
+	r.editor.complete = r.complete
+	r.reset()
+	return r.run()
+}
+
+func (r *repl) reset() {
+	r.bud = r.create()
+	r.bud.Stdin = r.editor.reader
+}
+
+func (r *repl) run() int {
+	var lines []string
+	r.cmd++
+	for {
+		prompt := fmt.Sprintf(r.in, r.cmd)
+		if len(lines) > 0 {
+			prompt = r.more
+		}
+		line, err := r.editor.ReadLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			lines = lines[:0]
+			continue
+		}
+		if err != nil {
+			return finish(r.bud, nil)
+		}
+		r.editor.AddHistory(line)
+		if len(lines) == 0 {
+			str := strings.TrimSpace(line)
+			if str == "" {
+				r.cmd++
+				continue
+			}
+			if strings.HasPrefix(str, ":") {
+				r.command(str)
+				r.cmd++
+				continue
+			}
+		}
+		lines = append(lines, line)
+		src := strings.Join(lines, "\n")
+		if incomplete(src) {
+			continue
+		}
+		lines = lines[:0]
+		if code, exit := r.eval(src); exit {
+			return code
+		}
+		r.cmd++
+	}
+}
+
+func (r *repl) eval(src string) (int, bool) {
+	now := time.Now()
+	res, err := r.bud.EvalString(src)
+	elapsed := time.Since(now)
+	if err != nil {
+		if builtins.IsExit(err) {
+			return finish(r.bud, err), true
+		}
+		faults.PrintError(os.Stderr, err)
+	} else {
+		fmt.Fprintf(os.Stdout, r.ok, r.cmd, res)
+		fmt.Fprintln(os.Stdout)
+		if err := r.bud.Assign("_", res); err != nil {
+			r.bud.Define("_", res)
+		}
+	}
+	if r.timing {
+		fmt.Fprintf(os.Stdout, "time: %s", elapsed)
+		fmt.Fprintln(os.Stdout)
+	}
+	return 0, false
+}
+
+func (r *repl) command(line string) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":help":
+		fmt.Fprintln(os.Stdout, help)
+	case ":env":
+		r.env()
+	case ":ast":
+		expr, err := parse.New(strings.NewReader(arg)).Parse()
+		if err != nil {
+			faults.PrintError(os.Stderr, err)
+			break
+		}
+		ast.Debug(os.Stdout, expr)
+	case ":load":
+		r.load(arg)
+	case ":reset":
+		r.reset()
+		fmt.Fprintln(os.Stdout, "session reset")
+	case ":time":
+		r.timing = !r.timing
+		if r.timing {
+			fmt.Fprintln(os.Stdout, "timing on")
+		} else {
+			fmt.Fprintln(os.Stdout, "timing off")
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "%s: unknown command (see :help)", name)
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func (r *repl) env() {
+	for _, n := range r.bud.Names() {
+		v, _ := r.bud.Value(n)
+		fmt.Fprintf(os.Stdout, "%s = %v", n, v)
+		fmt.Fprintln(os.Stdout)
+	}
+	for _, n := range r.bud.Functions() {
+		fmt.Fprintf(os.Stdout, "def %s", n)
+		fmt.Fprintln(os.Stdout)
+	}
+	for _, n := range r.bud.Imports() {
+		fmt.Fprintf(os.Stdout, "import %s", n)
+		fmt.Fprintln(os.Stdout)
+	}
+}
+
+func (r *repl) load(file string) {
+	if file == "" {
+		fmt.Fprintln(os.Stderr, ":load: missing file")
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer f.Close()
+	res, err := r.bud.Eval(f)
+	if err != nil {
+		faults.PrintError(os.Stderr, err)
+		return
+	}
+	if !types.IsNil(res) {
+		fmt.Fprintf(os.Stdout, r.ok, r.cmd, res)
+		fmt.Fprintln(os.Stdout)
+	}
+}
+
+func (r *repl) complete(line string) (int, []string) {
+	if strings.HasPrefix(line, ":load ") {
+		return len([]rune(":load ")), completeFile(strings.TrimPrefix(line, ":load "))
+	}
+	if strings.HasPrefix(line, ":") && !strings.Contains(line, " ") {
+		return 0, filterPrefix(commands, line)
+	}
+	var (
+		str   = []rune(line)
+		start = len(str)
+	)
+	for start > 0 && isWord(str[start-1]) {
+		start--
+	}
+	word := string(str[start:])
+	if x := strings.LastIndexByte(word, '.'); x >= 0 {
+		start += len([]rune(word[:x+1]))
+		return start, filterPrefix(r.bud.Members(word[:x]), word[x+1:])
+	}
+	var list []string
+	if fs := strings.Fields(line); len(fs) > 0 && (fs[0] == token.KwImport || fs[0] == token.KwFrom) {
+		for _, m := range builtins.Modules {
+			list = append(list, m.Name)
+		}
+	} else {
+		list = append(list, keywords...)
+		list = append(list, r.bud.Names()...)
+		list = append(list, r.bud.Functions()...)
+		list = append(list, r.bud.Imports()...)
+		if mod, err := builtins.LookupModule("builtin"); err == nil {
+			list = append(list, mod.Names()...)
+		}
+	}
+	return start, filterPrefix(list, word)
+}
+
+func completeFile(prefix string) []string {
+	list, _ := filepath.Glob(prefix + "*")
+	for i := range list {
+		if s, err := os.Stat(list[i]); err == nil && s.IsDir() {
+			list[i] += string(filepath.Separator)
+		}
+	}
+	return list
+}
+
+func filterPrefix(list []string, prefix string) []string {
+	var (
+		res  []string
+		seen = make(map[string]struct{})
+	)
+	for _, str := range list {
+		if !strings.HasPrefix(str, prefix) {
+			continue
+		}
+		if _, ok := seen[str]; ok {
+			continue
+		}
+		seen[str] = struct{}{}
+		res = append(res, str)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func isWord(r rune) bool {
+	return r == '_' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func incomplete(src string) bool {
+	_, err := parse.New(strings.NewReader(src)).Parse()
+	if err == nil {
+		return false
+	}
+	var list *faults.ErrorList
+	if !errors.As(err, &list) {
+		list = &faults.ErrorList{err}
+	}
+	for _, e := range *list {
+		var perr faults.ParseError
+		if errors.As(e, &perr) && perr.Type == token.EOF {
+			return unbalanced(src)
+		}
+	}
+	return false
+}
+
+func unbalanced(src string) bool {
+	var (
+		s     = scan.Scan(strings.NewReader(src))
+		depth int
+	)
+	for tok := s.Scan(); tok.Type != token.EOF; tok = s.Scan() {
+		switch tok.Type {
+		case token.Lparen, token.Lcurly, token.Lsquare:
+			depth++
+		case token.Rparen, token.Rcurly, token.Rsquare:
+			depth--
+		case token.Invalid:
+			return false
+		}
+	}
+	return depth > 0
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package main
+
+import (
+	"fmt"
+)
+
+// Terminals are not detected on other platforms (Windows among them): the
+// REPL reads whole lines from stdin without line editing, history
+// navigation or completion, and prints its prompts without colors.
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, fmt.Errorf("raw mode not supported")
+}
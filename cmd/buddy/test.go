@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/eval"
+	"github.com/midbel/buddy/faults"
+	"github.com/midbel/buddy/parse"
+	"github.com/midbel/buddy/types"
+)
+
+const (
+	testSuffix   = "_test.bud"
+	testPrefix   = "test_"
+	testSetup    = "setup"
+	testTeardown = "teardown"
+	testFixture  = "_fixture"
+)
+
+type testResult struct {
+	File    string
+	Name    string
+	Err     error
+	Output  string
+	Elapsed time.Duration
+}
+
+func (r testResult) Failed() bool {
+	return r.Err != nil
+}
+
+func (r testResult) Kind() string {
+	if errors.Is(r.Err, types.ErrAssert) {
+		return "failure"
+	}
+	return "error"
+}
+
+type testSuite struct {
+	File    string
+	Tests   []testResult
+	Elapsed time.Duration
+}
+
+func (s testSuite) Failures() int {
+	var n int
+	for _, r := range s.Tests {
+		if r.Failed() {
+			n++
+		}
+	}
+	return n
+}
+
+type testRunner struct {
+	filter  *regexp.Regexp
+	noexec  bool
+	verbose bool
+	steps   int
+	timeout time.Duration
+}
+
+func runTest(args []string) int {
+	var (
+		set     = flag.NewFlagSet("test", flag.ExitOnError)
+		run     = set.String("run", "", "run only tests matching regular expression")
+		format  = set.String("format", "text", "output format: text, tap or junit")
+		verbose = set.Bool("v", false, "print all tests and their output")
+		noexec  = set.Bool("no-exec", false, "disable execution of external commands")
+		steps   = set.Int("steps", 0, "maximum number of evaluation steps of each test, setup and teardown call")
+		timeout = set.Duration("timeout", 0, "maximum execution time of each test")
+	)
+	set.Parse(args)
+
+	runner := testRunner{
+		noexec:  *noexec,
+		verbose: *verbose,
+		steps:   *steps,
+		timeout: *timeout,
+	}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		runner.filter = re
+	}
+	var report func(io.Writer, []testSuite) error
+	switch *format {
+	case "text":
+		report = runner.reportText
+	case "tap":
+		report = reportTap
+	case "junit":
+		report = reportJunit
+	default:
+		fmt.Fprintf(os.Stderr, "%s: unknown format", *format)
+		fmt.Fprintln(os.Stderr)
+		return 2
+	}
+	files, err := findTests(set.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var (
+		suites []testSuite
+		code   int
+	)
+	for _, file := range files {
+		s := runner.runFile(file)
+		if s.Failures() > 0 {
+			code = 1
+		}
+		suites = append(suites, s)
+	}
+	if err := report(os.Stdout, suites); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return code
+}
+
+func findTests(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = append(args, ".")
+	}
+	var files []string
+	for _, a := range args {
+		i, err := os.Stat(a)
+		if err != nil {
+			return nil, err
+		}
+		if !i.IsDir() {
+			files = append(files, a)
+			continue
+		}
+		err = filepath.WalkDir(a, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), testSuffix) {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (r testRunner) runFile(file string) testSuite {
+	var (
+		now   = time.Now()
+		suite = testSuite{
+			File: file,
+		}
+	)
+	defer func() {
+		suite.Elapsed = time.Since(now)
+	}()
+	script, err := parseTest(file)
+	if err != nil {
+		suite.Tests = append(suite.Tests, testResult{File: file, Err: err})
+		return suite
+	}
+	for _, name := range testNames(script) {
+		if r.filter != nil && !r.filter.MatchString(name) {
+			continue
+		}
+		suite.Tests = append(suite.Tests, r.runOne(file, name, script))
+	}
+	return suite
+}
+
+// runOne runs a test in a fresh interpreter. Since functions can not see
+// globals, the value returned by setup is given to the test and to
+// teardown when they declare a parameter.
+func (r testRunner) runOne(file, name string, script ast.Script) testResult {
+	var (
+		now = time.Now()
+		out bytes.Buffer
+		bud = eval.Default()
+		res = testResult{
+			File: file,
+			Name: name,
+		}
+	)
+	bud.Stdout = &out
+	bud.Stderr = &out
+	bud.Stdin = strings.NewReader("")
+	bud.Args = []string{file}
+	bud.DisableExec = r.noexec
+	bud.ImportPats = []string{filepath.Dir(file)}
+	bud.MaxSteps = r.steps
+	if r.timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+		defer cancel()
+		bud.Context = ctx
+	}
+
+	fixture := types.CreateNil()
+	res.Err = loadTest(bud, file)
+	if _, ok := script.Symbols[testSetup]; ok && res.Err == nil {
+		fixture, res.Err = callTest(bud, testSetup, false)
+	}
+	if err := bud.Define(testFixture, fixture); err != nil && res.Err == nil {
+		res.Err = err
+	}
+	if res.Err == nil {
+		_, res.Err = callTest(bud, name, takesFixture(script, name))
+	}
+	if _, ok := script.Symbols[testTeardown]; ok {
+		_, err := callTest(bud, testTeardown, takesFixture(script, testTeardown))
+		if err != nil && res.Err == nil {
+			res.Err = err
+		}
+	}
+	res.Output = out.String()
+	res.Elapsed = time.Since(now)
+	return res
+}
+
+func parseTest(file string) (ast.Script, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return ast.Script{}, err
+	}
+	defer r.Close()
+	expr, err := parse.New(r).Parse()
+	if err != nil {
+		return ast.Script{}, err
+	}
+	script, ok := expr.(ast.Script)
+	if !ok {
+		return script, fmt.Errorf("%s: no test functions found", file)
+	}
+	return script, nil
+}
+
+func testNames(script ast.Script) []string {
+	var list []ast.Function
+	for name, e := range script.Symbols {
+		fn, ok := e.(ast.Function)
+		if !ok || !strings.HasPrefix(name, testPrefix) {
+			continue
+		}
+		list = append(list, fn)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		pi, pj := list[i].Position, list[j].Position
+		if pi.Line == pj.Line {
+			return pi.Column < pj.Column
+		}
+		return pi.Line < pj.Line
+	})
+	var names []string
+	for _, fn := range list {
+		names = append(names, fn.Ident)
+	}
+	return names
+}
+
+func loadTest(bud *eval.Interpreter, file string) error {
+	r, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = bud.Eval(r)
+	return err
+}
+
+func takesFixture(script ast.Script, name string) bool {
+	fn, ok := script.Symbols[name].(ast.Function)
+	return ok && len(fn.Params) > 0
+}
+
+func callTest(bud *eval.Interpreter, name string, fixture bool) (types.Primitive, error) {
+	call := name + "()"
+	if fixture {
+		call = name + "(" + testFixture + ")"
+	}
+	expr, err := parse.New(strings.NewReader(call)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	return bud.Exec(expr)
+}
+
+func testDetails(err error) []string {
+	var (
+		rerr *faults.RuntimeError
+		aerr eval.AssertError
+		list []string
+	)
+	if !errors.As(err, &rerr) {
+		return strings.Split(strings.TrimRight(err.Error(), "\n"), "\n")
+	}
+	list = append(list, fmt.Sprintf("%s:%d:%d: %s", rerr.File, rerr.Position.Line, rerr.Position.Column, rerr.Message))
+	if line := strings.TrimSpace(rerr.Line); line != "" {
+		list = append(list, "    "+line)
+	}
+	if errors.As(err, &aerr) {
+		if aerr.Left != nil && aerr.Right != nil {
+			list = append(list, "left:  "+operand(aerr.Left))
+			list = append(list, "right: "+operand(aerr.Right))
+		} else if aerr.Value != nil {
+			list = append(list, "value: "+operand(aerr.Value))
+		}
+	}
+	return list
+}
+
+func operand(value types.Primitive) string {
+	if s, ok := value.(types.String); ok {
+		return strconv.Quote(s.String())
+	}
+	return value.String()
+}
+
+func testName(r testResult) string {
+	if r.Name == "" {
+		return r.File
+	}
+	return r.Name
+}
+
+func (t testRunner) reportText(w io.Writer, suites []testSuite) error {
+	for _, s := range suites {
+		for _, r := range s.Tests {
+			if !r.Failed() && !t.verbose {
+				continue
+			}
+			status := "PASS"
+			if r.Failed() {
+				status = "FAIL"
+			}
+			fmt.Fprintf(w, "--- %s: %s (%.2fs)", status, testName(r), r.Elapsed.Seconds())
+			fmt.Fprintln(w)
+			if r.Failed() {
+				for _, line := range testDetails(r.Err) {
+					fmt.Fprintf(w, "    %s", line)
+					fmt.Fprintln(w)
+				}
+			}
+			if r.Output != "" && (r.Failed() || t.verbose) {
+				for _, line := range strings.Split(strings.TrimRight(r.Output, "\n"), "\n") {
+					fmt.Fprintf(w, "    | %s", line)
+					fmt.Fprintln(w)
+				}
+			}
+		}
+		if len(s.Tests) == 0 {
+			fmt.Fprintf(w, "ok  \t%s\t[no tests to run]", s.File)
+			fmt.Fprintln(w)
+			continue
+		}
+		status := "ok  "
+		if s.Failures() > 0 {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.3fs\t%d/%d passed", status, s.File, s.Elapsed.Seconds(), len(s.Tests)-s.Failures(), len(s.Tests))
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func reportTap(w io.Writer, suites []testSuite) error {
+	var total int
+	for _, s := range suites {
+		total += len(s.Tests)
+	}
+	fmt.Fprintln(w, "TAP version 13")
+	fmt.Fprintf(w, "1..%d", total)
+	fmt.Fprintln(w)
+
+	var n int
+	for _, s := range suites {
+		for _, r := range s.Tests {
+			n++
+			status := "ok"
+			if r.Failed() {
+				status = "not ok"
+			}
+			fmt.Fprintf(w, "%s %d - %s: %s", status, n, r.File, testName(r))
+			fmt.Fprintln(w)
+			if !r.Failed() {
+				continue
+			}
+			fmt.Fprintln(w, "  ---")
+			fmt.Fprintf(w, "  severity: %s", r.Kind())
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "  message: |")
+			for _, line := range testDetails(r.Err) {
+				fmt.Fprintf(w, "    %s", line)
+				fmt.Fprintln(w)
+			}
+			if r.Output != "" {
+				fmt.Fprintln(w, "  output: |")
+				for _, line := range strings.Split(strings.TrimRight(r.Output, "\n"), "\n") {
+					fmt.Fprintf(w, "    %s", line)
+					fmt.Fprintln(w)
+				}
+			}
+			fmt.Fprintln(w, "  ...")
+		}
+	}
+	return nil
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Output    string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func reportJunit(w io.Writer, suites []testSuite) error {
+	var (
+		root    junitSuites
+		elapsed time.Duration
+	)
+	for _, s := range suites {
+		js := junitSuite{
+			Name: s.File,
+			Time: seconds(s.Elapsed),
+		}
+		for _, r := range s.Tests {
+			jc := junitCase{
+				Name:      testName(r),
+				Classname: s.File,
+				Time:      seconds(r.Elapsed),
+				Output:    r.Output,
+			}
+			if r.Failed() {
+				details := testDetails(r.Err)
+				f := junitFailure{
+					Message: details[0],
+					Type:    r.Kind(),
+					Text:    strings.Join(details, "\n"),
+				}
+				if r.Kind() == "failure" {
+					jc.Failure = &f
+					js.Failures++
+				} else {
+					jc.Error = &f
+					js.Errors++
+				}
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+		js.Tests = len(s.Tests)
+		root.Tests += js.Tests
+		root.Failures += js.Failures
+		root.Errors += js.Errors
+		root.Suites = append(root.Suites, js)
+		elapsed += s.Elapsed
+	}
+	root.Time = seconds(elapsed)
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 
 	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/eval"
 	"github.com/midbel/buddy/faults"
 	"github.com/midbel/buddy/parse"
 	"github.com/midbel/buddy/visitors"
@@ -14,6 +16,7 @@ import (
 
 func main() {
 	lint := flag.Bool("l", false, "lint code")
+	code := flag.Bool("c", false, "print bytecode")
 	flag.Parse()
 	r, err := os.Open(flag.Arg(0))
 	if err != nil {
@@ -22,7 +25,11 @@ func main() {
 	}
 	defer r.Close()
 
-	err = Debug(os.Stdout, r, *lint)
+	if *code {
+		err = Disassemble(os.Stdout, r)
+	} else {
+		err = Debug(os.Stdout, r, *lint)
+	}
 	if err != nil {
 		faults.PrintError(os.Stderr, err)
 		os.Exit(1)
@@ -48,3 +55,29 @@ func Debug(w io.Writer, r io.Reader, lint bool) error {
 	}
 	return err
 }
+
+func Disassemble(w io.Writer, r io.Reader) error {
+	expr, err := parse.New(r).Parse()
+	if err != nil {
+		return err
+	}
+	list := []ast.Expression{expr}
+	if s, ok := expr.(ast.Script); ok {
+		var names []string
+		for n := range s.Symbols {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			list = append(list, s.Symbols[n])
+		}
+	}
+	for _, e := range list {
+		code, err := eval.Compile(e)
+		if err != nil {
+			return err
+		}
+		code.Disassemble(w)
+	}
+	return nil
+}
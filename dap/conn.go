@@ -0,0 +1,109 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/textproto"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const maxMessageSize = 16 << 20
+
+type Conn struct {
+	in  *textproto.Reader
+	out io.Writer
+	log *log.Logger
+
+	mu  sync.Mutex
+	seq int
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{
+		in:  textproto.NewReader(bufio.NewReader(r)),
+		out: w,
+		log: log.New(os.Stderr, "dap: ", log.LstdFlags),
+	}
+}
+
+func (c *Conn) Read() (Request, error) {
+	for {
+		req, err := c.read()
+		if err != nil || req.Type == "request" {
+			return req, err
+		}
+		c.log.Printf("%s: unexpected message type (seq %d), skipped", req.Type, req.Seq)
+	}
+}
+
+func (c *Conn) read() (Request, error) {
+	var req Request
+	hdr, err := c.in.ReadMIMEHeader()
+	if err != nil {
+		return req, err
+	}
+	size, err := strconv.Atoi(hdr.Get("Content-Length"))
+	if err != nil || size < 0 {
+		return req, fmt.Errorf("invalid Content-Length header")
+	}
+	if size > maxMessageSize {
+		return req, fmt.Errorf("%d: message larger than %d bytes", size, maxMessageSize)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.in.R, body); err != nil {
+		return req, err
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+func (c *Conn) Reply(req Request, body interface{}, err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	msg := Response{
+		Seq:        c.seq,
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Success:    err == nil,
+		Command:    req.Command,
+		Body:       body,
+	}
+	if err != nil {
+		msg.Message = err.Error()
+		msg.Body = nil
+	}
+	return c.write(msg)
+}
+
+func (c *Conn) Emit(event string, body interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	msg := Event{
+		Seq:   c.seq,
+		Type:  "event",
+		Event: event,
+		Body:  body,
+	}
+	return c.write(msg)
+}
+
+func (c *Conn) write(msg interface{}) error {
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n", len(buf)); err != nil {
+		return err
+	}
+	_, err = c.out.Write(buf)
+	return err
+}
@@ -0,0 +1,547 @@
+package dap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/midbel/buddy/builtins"
+	"github.com/midbel/buddy/eval"
+	"github.com/midbel/buddy/types"
+)
+
+const threadId = 1
+
+var (
+	errNotPaused   = errors.New("program is not paused")
+	errNotLaunched = errors.New("program not launched")
+)
+
+type reference struct {
+	env   *types.Environ
+	value types.Primitive
+}
+
+type Server struct {
+	conn *Conn
+	dbg  *eval.Debugger
+	bud  *eval.Interpreter
+
+	program     string
+	stopOnEntry bool
+	configured  bool
+	started     bool
+	offset      int
+
+	mu     sync.Mutex
+	paused bool
+	cmds   chan func(*eval.Interpreter) bool
+	refs   []reference
+	done   chan struct{}
+	after  func()
+}
+
+func Serve(r io.Reader, w io.Writer) error {
+	s := Server{
+		conn: NewConn(r, w),
+		dbg:  &eval.Debugger{},
+		cmds: make(chan func(*eval.Interpreter) bool),
+		done: make(chan struct{}),
+	}
+	s.dbg.OnStop = s.stop
+	return s.Run()
+}
+
+func (s *Server) Run() error {
+	for {
+		req, err := s.conn.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				s.terminate()
+				return nil
+			}
+			return err
+		}
+		res, err := s.handle(req)
+		if err := s.conn.Reply(req, res, err); err != nil {
+			return err
+		}
+		if s.after != nil {
+			s.after()
+			s.after = nil
+		}
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+func (s *Server) handle(req Request) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return s.initialize(req.Arguments)
+	case "launch":
+		return s.launch(req.Arguments)
+	case "setBreakpoints":
+		return s.setBreakpoints(req.Arguments)
+	case "setExceptionBreakpoints":
+		return nil, nil
+	case "configurationDone":
+		s.configured = true
+		s.after = s.start
+		return nil, nil
+	case "threads":
+		res := ThreadsResponse{
+			Threads: []Thread{{Id: threadId, Name: "main"}},
+		}
+		return res, nil
+	case "stackTrace":
+		return s.stackTrace(req.Arguments)
+	case "scopes":
+		return s.scopes(req.Arguments)
+	case "variables":
+		return s.variables(req.Arguments)
+	case "evaluate":
+		return s.evaluate(req.Arguments)
+	case "continue":
+		return s.resume(eval.StepNone)
+	case "next":
+		return s.resume(eval.StepOver)
+	case "stepIn":
+		return s.resume(eval.StepIn)
+	case "stepOut":
+		return s.resume(eval.StepOut)
+	case "pause":
+		s.dbg.Pause()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.after = s.terminate
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s: command not supported", req.Command)
+	}
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var args InitializeArguments
+	if err := decode(params, &args); err != nil {
+		return nil, err
+	}
+	if args.LinesStartAt1 != nil && !*args.LinesStartAt1 {
+		s.offset = 1
+	}
+	s.after = func() {
+		s.conn.Emit("initialized", nil)
+	}
+	res := Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}
+	return res, nil
+}
+
+func (s *Server) launch(params json.RawMessage) (interface{}, error) {
+	var args LaunchArguments
+	if err := decode(params, &args); err != nil {
+		return nil, err
+	}
+	if args.Program == "" {
+		return nil, fmt.Errorf("launch: program not given")
+	}
+	if _, err := os.Stat(args.Program); err != nil {
+		return nil, err
+	}
+	s.program = args.Program
+	s.stopOnEntry = args.StopOnEntry
+
+	s.bud = eval.Default()
+	s.bud.Stdout = output{conn: s.conn, category: "stdout"}
+	s.bud.Stderr = output{conn: s.conn, category: "stderr"}
+	s.bud.Stdin = strings.NewReader("")
+	s.bud.Args = append([]string{args.Program}, args.Args...)
+	if !args.NoDebug {
+		s.bud.Hook = s.dbg
+	}
+	s.after = s.start
+	return nil, nil
+}
+
+func (s *Server) setBreakpoints(params json.RawMessage) (interface{}, error) {
+	var args SetBreakpointsArguments
+	if err := decode(params, &args); err != nil {
+		return nil, err
+	}
+	var (
+		res  SetBreakpointsResponse
+		file = args.Source.Path
+	)
+	lines, lerr := countLines(file)
+	s.dbg.ClearBreakpoints(file)
+	for _, sb := range args.Breakpoints {
+		var (
+			b    eval.Breakpoint
+			err  error
+			line = sb.Line + s.offset
+		)
+		if lerr == nil && line > lines {
+			err = fmt.Errorf("%d: line beyond end of file", sb.Line)
+		} else {
+			b, err = s.dbg.SetBreakpoint(file, line, sb.Condition)
+		}
+		bp := Breakpoint{
+			Id:       b.Id,
+			Verified: err == nil,
+			Line:     sb.Line,
+			Source:   &args.Source,
+		}
+		if err != nil {
+			bp.Message = err.Error()
+		}
+		res.Breakpoints = append(res.Breakpoints, bp)
+	}
+	if res.Breakpoints == nil {
+		res.Breakpoints = []Breakpoint{}
+	}
+	return res, nil
+}
+
+func (s *Server) stackTrace(params json.RawMessage) (interface{}, error) {
+	var args StackTraceArguments
+	if err := decode(params, &args); err != nil {
+		return nil, err
+	}
+	var res StackTraceResponse
+	err := s.call(func(i *eval.Interpreter) bool {
+		frames := i.Frames()
+		res.TotalFrames = len(frames)
+		for j := len(frames) - 1 - args.StartFrame; j >= 0; j-- {
+			if args.Levels > 0 && len(res.StackFrames) >= args.Levels {
+				break
+			}
+			f := frames[j]
+			sf := StackFrame{
+				Id:     len(frames) - j,
+				Name:   frameName(f),
+				Source: s.source(f.File),
+				Line:   f.Position.Line - s.offset,
+				Column: f.Position.Column - s.offset,
+			}
+			res.StackFrames = append(res.StackFrames, sf)
+		}
+		return false
+	})
+	if res.StackFrames == nil {
+		res.StackFrames = []StackFrame{}
+	}
+	return res, err
+}
+
+func (s *Server) scopes(params json.RawMessage) (interface{}, error) {
+	var args ScopesArguments
+	if err := decode(params, &args); err != nil {
+		return nil, err
+	}
+	var res ScopesResponse
+	err := s.call(func(i *eval.Interpreter) bool {
+		frames := i.Frames()
+		x := len(frames) - args.FrameId
+		if x < 0 || x >= len(frames) {
+			return false
+		}
+		sc := Scope{
+			Name:               "Locals",
+			VariablesReference: s.reference(reference{env: frames[x].Env}),
+		}
+		res.Scopes = append(res.Scopes, sc)
+		return false
+	})
+	if res.Scopes == nil {
+		res.Scopes = []Scope{}
+	}
+	return res, err
+}
+
+func (s *Server) variables(params json.RawMessage) (interface{}, error) {
+	var args VariablesArguments
+	if err := decode(params, &args); err != nil {
+		return nil, err
+	}
+	var res VariablesResponse
+	err := s.call(func(i *eval.Interpreter) bool {
+		x := args.VariablesReference - 1
+		if x < 0 || x >= len(s.refs) {
+			return false
+		}
+		res.Variables = s.expand(s.refs[x])
+		return false
+	})
+	if res.Variables == nil {
+		res.Variables = []Variable{}
+	}
+	return res, err
+}
+
+func (s *Server) evaluate(params json.RawMessage) (interface{}, error) {
+	var args EvaluateArguments
+	if err := decode(params, &args); err != nil {
+		return nil, err
+	}
+	var (
+		res  EvaluateResponse
+		eerr error
+	)
+	err := s.call(func(i *eval.Interpreter) bool {
+		var (
+			frames = i.Frames()
+			level  = len(frames) - 1
+		)
+		if args.FrameId > 0 {
+			level = len(frames) - args.FrameId
+		}
+		val, err := i.Evaluate(level, args.Expression)
+		if err != nil {
+			eerr = err
+			return false
+		}
+		v := s.variable("", val)
+		res.Result = v.Value
+		res.Type = v.Type
+		res.VariablesReference = v.VariablesReference
+		return false
+	})
+	if err == nil {
+		err = eerr
+	}
+	return res, err
+}
+
+func (s *Server) resume(mode eval.StepMode) (interface{}, error) {
+	if !s.isPaused() {
+		return nil, errNotPaused
+	}
+	s.after = func() {
+		s.call(func(i *eval.Interpreter) bool {
+			s.dbg.Resume(i, mode)
+			s.setPaused(false)
+			return true
+		})
+	}
+	return ContinueResponse{AllThreadsContinued: true}, nil
+}
+
+func (s *Server) start() {
+	if s.bud == nil || !s.configured || s.started {
+		return
+	}
+	s.started = true
+	if s.stopOnEntry {
+		s.dbg.Resume(s.bud, eval.StepIn)
+	}
+	go s.run()
+}
+
+func (s *Server) run() {
+	defer close(s.done)
+	var code int
+	r, err := os.Open(s.program)
+	if err == nil {
+		_, err = s.bud.Eval(r)
+		r.Close()
+		if err == nil || builtins.IsExit(err) {
+			if e := s.bud.Exit(); e != nil {
+				err = e
+			}
+		}
+		if c, ok := builtins.ExitCode(err); ok {
+			code, err = c, nil
+		}
+	}
+	if err != nil && !errors.Is(err, eval.ErrTerminated) {
+		s.conn.Emit("output", OutputEvent{Category: "stderr", Output: err.Error() + "\n"})
+		code = 1
+	}
+	s.conn.Emit("exited", ExitedEvent{ExitCode: code})
+	s.conn.Emit("terminated", nil)
+}
+
+func (s *Server) terminate() {
+	if !s.started {
+		return
+	}
+	s.dbg.Terminate()
+	if s.isPaused() {
+		s.call(func(i *eval.Interpreter) bool {
+			s.setPaused(false)
+			return true
+		})
+	}
+	<-s.done
+}
+
+func (s *Server) stop(i *eval.Interpreter, stop eval.Stop) error {
+	s.setPaused(true)
+	s.refs = s.refs[:0]
+
+	ev := StoppedEvent{
+		Reason:            stop.Reason,
+		ThreadId:          threadId,
+		AllThreadsStopped: true,
+	}
+	if stop.Reason == eval.StopBreakpoint {
+		ev.HitBreakpointIds = append(ev.HitBreakpointIds, stop.Breakpoint.Id)
+	}
+	if stop.Err != nil {
+		ev.Text = stop.Err.Error()
+	}
+	s.conn.Emit("stopped", ev)
+	for fn := range s.cmds {
+		if fn(i) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Server) call(fn func(*eval.Interpreter) bool) error {
+	if s.bud == nil {
+		return errNotLaunched
+	}
+	if !s.isPaused() {
+		return errNotPaused
+	}
+	done := make(chan struct{})
+	s.cmds <- func(i *eval.Interpreter) bool {
+		defer close(done)
+		return fn(i)
+	}
+	<-done
+	return nil
+}
+
+func (s *Server) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+func (s *Server) setPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
+func (s *Server) reference(ref reference) int {
+	s.refs = append(s.refs, ref)
+	return len(s.refs)
+}
+
+func (s *Server) expand(ref reference) []Variable {
+	var list []Variable
+	switch {
+	case ref.env != nil:
+		for _, n := range ref.env.Names() {
+			v, _ := ref.env.Value(n)
+			list = append(list, s.variable(n, v))
+		}
+	default:
+		switch v := ref.value.(type) {
+		case types.Array:
+			var x int
+			v.Iter(func(p types.Primitive) error {
+				list = append(list, s.variable("["+strconv.Itoa(x)+"]", p))
+				x++
+				return nil
+			})
+		case types.Dict:
+			values, _ := v.Raw().(map[types.Primitive]types.Primitive)
+			for k, p := range values {
+				list = append(list, s.variable(k.String(), p))
+			}
+			sort.Slice(list, func(i, j int) bool {
+				return list[i].Name < list[j].Name
+			})
+		}
+	}
+	return list
+}
+
+func (s *Server) variable(name string, value types.Primitive) Variable {
+	if value == nil {
+		value = types.CreateNil()
+	}
+	typ, _ := types.Type(value)
+	v := Variable{
+		Name:  name,
+		Value: value.String(),
+		Type:  typ,
+	}
+	switch value.(type) {
+	case types.String:
+		v.Value = strconv.Quote(v.Value)
+	case types.Array, types.Dict:
+		v.VariablesReference = s.reference(reference{value: value})
+	}
+	return v
+}
+
+func (s *Server) source(file string) *Source {
+	if file == "" {
+		return nil
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	return &Source{
+		Name: filepath.Base(file),
+		Path: file,
+	}
+}
+
+func countLines(file string) (int, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	n := bytes.Count(buf, []byte("\n"))
+	if len(buf) > 0 && buf[len(buf)-1] != '\n' {
+		n++
+	}
+	return n, nil
+}
+
+func frameName(f eval.Frame) string {
+	if f.Func == "" {
+		return f.Module
+	}
+	return f.Func
+}
+
+type output struct {
+	conn     *Conn
+	category string
+}
+
+func (o output) Write(b []byte) (int, error) {
+	ev := OutputEvent{
+		Category: o.category,
+		Output:   string(b),
+	}
+	return len(b), o.conn.Emit("output", ev)
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	return json.Unmarshal(params, v)
+}
@@ -0,0 +1,340 @@
+package dap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const script = `import io
+
+def add(a, b) {
+	let c = a + b
+	return c
+}
+
+let x = 1
+let y = add(x, 2)
+io.print([x, y])
+`
+
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type client struct {
+	t       *testing.T
+	w       io.WriteCloser
+	seq     int
+	msgs    chan message
+	pending []message
+	done    chan error
+}
+
+func connect(t *testing.T) *client {
+	t.Helper()
+	var (
+		inr, inw   = io.Pipe()
+		outr, outw = io.Pipe()
+		c          = client{
+			t:    t,
+			w:    inw,
+			msgs: make(chan message, 64),
+			done: make(chan error, 1),
+		}
+	)
+	go func() {
+		c.done <- Serve(inr, outw)
+		outw.Close()
+	}()
+	go func() {
+		defer close(c.msgs)
+		conn := NewConn(outr, io.Discard)
+		for {
+			hdr, err := conn.in.ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+			var size int
+			fmt.Sscan(hdr.Get("Content-Length"), &size)
+			body := make([]byte, size)
+			if _, err := io.ReadFull(conn.in.R, body); err != nil {
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(body, &msg); err == nil {
+				c.msgs <- msg
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		inw.Close()
+		outr.Close()
+	})
+	return &c
+}
+
+func (c *client) send(cmd string, args interface{}) int {
+	c.t.Helper()
+	c.seq++
+	req := map[string]interface{}{
+		"seq":       c.seq,
+		"type":      "request",
+		"command":   cmd,
+		"arguments": args,
+	}
+	buf, _ := json.Marshal(req)
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(buf), buf); err != nil {
+		c.t.Fatalf("%s: write request: %s", cmd, err)
+	}
+	return c.seq
+}
+
+func (c *client) request(cmd string, args, v interface{}) {
+	c.t.Helper()
+	msg := c.response(cmd, args)
+	if !msg.Success {
+		c.t.Fatalf("%s: request failed: %s", cmd, msg.Message)
+	}
+	if v != nil {
+		if err := json.Unmarshal(msg.Body, v); err != nil {
+			c.t.Fatalf("%s: decode body: %s", cmd, err)
+		}
+	}
+}
+
+func (c *client) response(cmd string, args interface{}) message {
+	c.t.Helper()
+	seq := c.send(cmd, args)
+	for {
+		msg := c.read(cmd)
+		if msg.Type == "response" && msg.RequestSeq == seq {
+			return msg
+		}
+		c.pending = append(c.pending, msg)
+	}
+}
+
+func (c *client) event(name string, v interface{}) {
+	c.t.Helper()
+	for i, msg := range c.pending {
+		if msg.Type == "event" && msg.Event == name {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			c.decode(msg, v)
+			return
+		}
+	}
+	for {
+		msg := c.read(name)
+		if msg.Type == "event" && msg.Event == name {
+			c.decode(msg, v)
+			return
+		}
+		c.pending = append(c.pending, msg)
+	}
+}
+
+func (c *client) decode(msg message, v interface{}) {
+	c.t.Helper()
+	if v == nil {
+		return
+	}
+	if err := json.Unmarshal(msg.Body, v); err != nil {
+		c.t.Fatalf("%s: decode body: %s", msg.Event, err)
+	}
+}
+
+func (c *client) read(what string) message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatalf("%s: connection closed", what)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("%s: timeout", what)
+	}
+	return message{}
+}
+
+func (c *client) stopped(reason string) {
+	c.t.Helper()
+	var ev StoppedEvent
+	c.event("stopped", &ev)
+	if ev.Reason != reason {
+		c.t.Fatalf("stopped: want reason %q, got %q", reason, ev.Reason)
+	}
+}
+
+func (c *client) top() StackFrame {
+	c.t.Helper()
+	var res StackTraceResponse
+	c.request("stackTrace", StackTraceArguments{ThreadId: threadId}, &res)
+	if len(res.StackFrames) == 0 {
+		c.t.Fatalf("stackTrace: no frames")
+	}
+	return res.StackFrames[0]
+}
+
+func writeScript(t *testing.T) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "main.bud")
+	if err := os.WriteFile(file, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestSession(t *testing.T) {
+	var (
+		file = writeScript(t)
+		c    = connect(t)
+	)
+	var caps Capabilities
+	c.request("initialize", InitializeArguments{ClientId: "test"}, &caps)
+	if !caps.SupportsConfigurationDoneRequest {
+		t.Errorf("initialize: configurationDone not supported")
+	}
+	c.event("initialized", nil)
+	c.request("launch", LaunchArguments{Program: file}, nil)
+
+	var bps SetBreakpointsResponse
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: file},
+		Breakpoints: []SourceBreakpoint{{Line: 4}, {Line: 100}},
+	}, &bps)
+	if len(bps.Breakpoints) != 2 {
+		t.Fatalf("setBreakpoints: want 2 breakpoints, got %d", len(bps.Breakpoints))
+	}
+	if b := bps.Breakpoints[0]; !b.Verified || b.Line != 4 {
+		t.Errorf("setBreakpoints: line 4 should be verified: %+v", b)
+	}
+	if b := bps.Breakpoints[1]; b.Verified || b.Message == "" {
+		t.Errorf("setBreakpoints: line 100 should not be verified: %+v", b)
+	}
+	c.request("configurationDone", nil, nil)
+	c.stopped("breakpoint")
+
+	var trace StackTraceResponse
+	c.request("stackTrace", StackTraceArguments{ThreadId: threadId}, &trace)
+	if len(trace.StackFrames) < 2 {
+		t.Fatalf("stackTrace: want at least 2 frames, got %+v", trace.StackFrames)
+	}
+	if f := trace.StackFrames[0]; f.Name != "add" || f.Line != 4 {
+		t.Errorf("stackTrace: unexpected top frame %+v", f)
+	}
+	if f := trace.StackFrames[1]; f.Line != 9 {
+		t.Errorf("stackTrace: unexpected caller frame %+v", f)
+	}
+
+	var scopes ScopesResponse
+	c.request("scopes", ScopesArguments{FrameId: trace.StackFrames[0].Id}, &scopes)
+	if len(scopes.Scopes) != 1 {
+		t.Fatalf("scopes: want 1 scope, got %+v", scopes.Scopes)
+	}
+	var vars VariablesResponse
+	c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &vars)
+	locals := make(map[string]string)
+	for _, v := range vars.Variables {
+		locals[v.Name] = v.Value
+	}
+	if locals["a"] != "1" || locals["b"] != "2" {
+		t.Errorf("variables: unexpected locals %+v", vars.Variables)
+	}
+
+	var res EvaluateResponse
+	c.request("evaluate", EvaluateArguments{Expression: "a + b", FrameId: trace.StackFrames[0].Id}, &res)
+	if res.Result != "3" {
+		t.Errorf("evaluate: want 3, got %q", res.Result)
+	}
+	if msg := c.response("evaluate", EvaluateArguments{Expression: "unknown"}); msg.Success {
+		t.Errorf("evaluate: undefined variable should fail")
+	}
+
+	c.request("next", nil, nil)
+	c.stopped("step")
+	if f := c.top(); f.Name != "add" || f.Line != 5 {
+		t.Errorf("next: unexpected frame %+v", f)
+	}
+
+	c.request("stepOut", nil, nil)
+	c.stopped("step")
+	if f := c.top(); f.Name == "add" || f.Line < 9 {
+		t.Errorf("stepOut: unexpected frame %+v", f)
+	}
+
+	c.request("continue", nil, nil)
+	var out OutputEvent
+	c.event("output", &out)
+	if out.Category != "stdout" || out.Output != "[1 3]\n" {
+		t.Errorf("output: unexpected event %+v", out)
+	}
+	var exit ExitedEvent
+	c.event("exited", &exit)
+	if exit.ExitCode != 0 {
+		t.Errorf("exited: want code 0, got %d", exit.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("serve: %s", err)
+	}
+}
+
+func TestNotPaused(t *testing.T) {
+	c := connect(t)
+	c.request("initialize", InitializeArguments{}, nil)
+	for _, cmd := range []string{"continue", "next", "stepOut", "stackTrace"} {
+		if msg := c.response(cmd, nil); msg.Success {
+			t.Errorf("%s: should fail when program is not launched", cmd)
+		}
+	}
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("serve: %s", err)
+	}
+}
+
+func TestConnRead(t *testing.T) {
+	frame := func(body string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var (
+		logs  bytes.Buffer
+		input = frame(`{"seq": 1, "type": "event", "event": "output"}`) +
+			frame(`{"seq": 2, "type": "request", "command": "initialize"}`) +
+			fmt.Sprintf("Content-Length: %d\r\n\r\n", maxMessageSize+1)
+		conn = NewConn(strings.NewReader(input), io.Discard)
+	)
+	conn.log = log.New(&logs, "", 0)
+
+	req, err := conn.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if req.Seq != 2 || req.Command != "initialize" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+	if !strings.Contains(logs.String(), "event") {
+		t.Errorf("skipped message not logged: %q", logs.String())
+	}
+	if _, err := conn.Read(); err == nil {
+		t.Errorf("oversized message should be rejected")
+	}
+}
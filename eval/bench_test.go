@@ -0,0 +1,38 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/parse"
+)
+
+func BenchmarkFib(b *testing.B) {
+	r, err := os.Open(filepath.Join("..", "examples", "fib.bud"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer r.Close()
+
+	expr, err := parse.New(r).Parse()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, e := range []Engine{TreeWalker, Bytecode} {
+		b.Run(e.String(), func(b *testing.B) {
+			benchmarkExec(b, expr, e)
+		})
+	}
+}
+
+func benchmarkExec(b *testing.B, expr ast.Expression, engine Engine) {
+	for i := 0; i < b.N; i++ {
+		bud := Default()
+		bud.Engine = engine
+		if _, err := bud.Exec(expr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
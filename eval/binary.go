@@ -98,6 +98,9 @@ func executeBinary(op rune, left, right types.Primitive) (types.Primitive, error
 		}
 		return left.Xor(right)
 	case token.Eq:
+		if types.IsNil(right) {
+			return types.CreateBool(types.IsNil(left)), nil
+		}
 		left, ok := left.(interface {
 			Eq(types.Primitive) (types.Primitive, error)
 		})
@@ -106,6 +109,9 @@ func executeBinary(op rune, left, right types.Primitive) (types.Primitive, error
 		}
 		return left.Eq(right)
 	case token.Ne:
+		if types.IsNil(right) {
+			return types.CreateBool(!types.IsNil(left)), nil
+		}
 		left, ok := left.(interface {
 			Ne(types.Primitive) (types.Primitive, error)
 		})
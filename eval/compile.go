@@ -0,0 +1,1176 @@
+package eval
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/token"
+	"github.com/midbel/buddy/types"
+	"github.com/midbel/slices"
+)
+
+var errCompile = errors.New("expression can not be compiled")
+
+type region struct {
+	loop      bool
+	finally   ast.Expression
+	depth     int
+	breaks    []int
+	continues []int
+}
+
+type compiler struct {
+	code    *Code
+	parent  *compiler
+	scopes  []map[string]int
+	regions []*region
+	depth   int
+	pos     token.Position
+
+	captured bool
+}
+
+func Compile(expr ast.Expression) (*Code, error) {
+	return compileBoxed(func(boxed bool) (*compiler, error) {
+		cp := compiler{
+			code: &Code{
+				Name:  "main",
+				boxed: boxed,
+			},
+		}
+		if err := cp.compile(expr); err != nil {
+			return nil, err
+		}
+		return &cp, cp.emit(opReturn)
+	})
+}
+
+func compileCode(fn ast.Function, parent *compiler) (*Code, error) {
+	return compileBoxed(func(boxed bool) (*compiler, error) {
+		cp := compiler{
+			code: &Code{
+				Name:  fn.Ident,
+				boxed: boxed,
+			},
+			parent: parent,
+			scopes: []map[string]int{make(map[string]int)},
+		}
+		cp.setPosition(fn.Position)
+		if err := cp.compileParameters(fn.Params); err != nil {
+			return nil, err
+		}
+		if err := cp.compile(fn.Body); err != nil {
+			return nil, err
+		}
+		return &cp, cp.emit(opReturn)
+	})
+}
+
+func compileBoxed(compile func(bool) (*compiler, error)) (*Code, error) {
+	cp, err := compile(false)
+	if err == nil && cp.captured {
+		cp, err = compile(true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cp.code, nil
+}
+
+func (c *compiler) compileParameters(params []ast.Expression) error {
+	for _, e := range params {
+		p, ok := e.(ast.Parameter)
+		if !ok {
+			return c.error(fmt.Errorf("parameter: %w", errCompile))
+		}
+		if _, err := c.declare(p.Ident); err != nil {
+			return err
+		}
+		c.code.Params = append(c.code.Params, p.Ident)
+	}
+	for j, e := range params {
+		p := e.(ast.Parameter)
+		if p.Expr == nil {
+			continue
+		}
+		c.setPosition(p.Position)
+		jump := len(c.code.Ops)
+		if err := c.emit(opJumpBound, j, 0); err != nil {
+			return err
+		}
+		if err := c.compile(p.Expr); err != nil {
+			return err
+		}
+		if err := c.storeLocal(j, true); err != nil {
+			return err
+		}
+		if err := c.emit(opPop); err != nil {
+			return err
+		}
+		if err := c.patch(jump); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) compile(expr ast.Expression) error {
+	switch e := expr.(type) {
+	case ast.Literal:
+		c.setPosition(e.Position)
+		return c.emitConst(types.CreateString(e.Str))
+	case ast.Double:
+		c.setPosition(e.Position)
+		return c.emitConst(types.CreateFloat(e.Value))
+	case ast.Integer:
+		c.setPosition(e.Position)
+		return c.emitConst(types.CreateInt(e.Value))
+	case ast.Boolean:
+		c.setPosition(e.Position)
+		return c.emitConst(types.CreateBool(e.Value))
+	case ast.Nil:
+		c.setPosition(e.Position)
+		return c.emit(opNil)
+	case ast.Template:
+		for i := range e.List {
+			if err := c.compile(e.List[i]); err != nil {
+				return err
+			}
+		}
+		c.setPosition(e.Position)
+		return c.emit(opConcat, len(e.List))
+	case ast.Placeholder:
+		if err := c.compile(e.Expr); err != nil {
+			return err
+		}
+		c.setPosition(e.Position)
+		return c.emit(opFormat, c.addName(e.Spec))
+	case ast.Variable:
+		c.setPosition(e.Position)
+		return c.compileLoad(e.Ident)
+	case ast.Array:
+		return c.compileArray(e)
+	case ast.Dict:
+		return c.compileDict(e)
+	case ast.Index:
+		return c.compileIndex(e)
+	case ast.Chain:
+		return c.compileChain(e)
+	case ast.Path:
+		return c.compilePath(e)
+	case ast.Call:
+		return c.compileCall(e)
+	case ast.Function:
+		return c.compileFunction(e)
+	case ast.Parameter:
+		return c.compile(e.Expr)
+	case ast.Assert:
+		if err := c.compile(e.Expr); err != nil {
+			return err
+		}
+		c.setPosition(e.Position)
+		return c.emit(opAssert)
+	case ast.Let:
+		return c.compileLet(e)
+	case ast.Assign:
+		return c.compileAssign(e)
+	case ast.Unary:
+		if err := c.compile(e.Right); err != nil {
+			return err
+		}
+		c.setPosition(e.Position)
+		return c.emit(opUnary, -int(e.Op))
+	case ast.Binary:
+		return c.compileBinary(e)
+	case ast.ListComp:
+		return c.compileListComp(e)
+	case ast.DictComp:
+		return c.compileDictComp(e)
+	case ast.Test:
+		return c.compileTest(e)
+	case ast.While:
+		return c.compileLoop(nil, e.Cdt, nil, e.Body)
+	case ast.For:
+		return c.compileLoop(e.Init, e.Cdt, e.Incr, e.Body)
+	case ast.ForEach:
+		return c.compileForeach(e)
+	case ast.Import:
+		c.setPosition(e.Position)
+		c.code.imports = append(c.code.imports, append(e.Ident, e.Alias))
+		return c.emit(opImport, len(c.code.imports)-1)
+	case ast.Script:
+		return c.compileScript(e)
+	case ast.Return:
+		return c.compileReturn(e)
+	case ast.Break:
+		c.setPosition(e.Position)
+		return c.compileBreak(false)
+	case ast.Continue:
+		c.setPosition(e.Position)
+		return c.compileBreak(true)
+	case ast.Try:
+		return c.compileTry(e)
+	case ast.Raise:
+		if err := c.compile(e.Right); err != nil {
+			return err
+		}
+		c.setPosition(e.Position)
+		return c.emit(opRaise)
+	default:
+		return c.error(fmt.Errorf("compile: %w", errCompile))
+	}
+}
+
+func (c *compiler) compileScript(s ast.Script) error {
+	if len(s.List) == 0 {
+		c.setPosition(s.Position)
+		return c.emit(opNil)
+	}
+	for i := range s.List {
+		if i > 0 {
+			if err := c.emit(opPop); err != nil {
+				return err
+			}
+		}
+		if err := c.compile(s.List[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) compileBlock(expr ast.Expression) error {
+	c.enterScope()
+	defer c.leaveScope()
+	return c.compile(expr)
+}
+
+func (c *compiler) compileArray(a ast.Array) error {
+	for i := range a.List {
+		if err := c.compile(a.List[i]); err != nil {
+			return err
+		}
+	}
+	c.setPosition(a.Position)
+	return c.emit(opArray, len(a.List))
+}
+
+func (c *compiler) compileDict(d ast.Dict) error {
+	for k, v := range d.List {
+		if err := c.compile(k); err != nil {
+			return err
+		}
+		if err := c.compile(v); err != nil {
+			return err
+		}
+	}
+	c.setPosition(d.Position)
+	return c.emit(opDict, len(d.List))
+}
+
+func (c *compiler) compileIndex(i ast.Index) error {
+	if err := c.compile(i.Arr); err != nil {
+		return err
+	}
+	for _, e := range i.List {
+		kind, err := c.compileAccessor(e)
+		if err != nil {
+			return err
+		}
+		c.setPosition(i.Position)
+		if err := c.emit(opAccess, kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) compileAccessor(expr ast.Expression) (int, error) {
+	s, ok := expr.(ast.Slice)
+	if !ok {
+		return 0, c.compile(expr)
+	}
+	kind := accSlice
+	for _, e := range []struct {
+		ast.Expression
+		flag int
+	}{{s.Start, accStart}, {s.End, accEnd}, {s.Step, accStep}} {
+		if e.Expression == nil {
+			continue
+		}
+		if err := c.compile(e.Expression); err != nil {
+			return 0, err
+		}
+		kind |= e.flag
+	}
+	return kind, nil
+}
+
+func (c *compiler) compileChain(ch ast.Chain) error {
+	if err := c.compile(ch.Left); err != nil {
+		return err
+	}
+	c.setPosition(ch.Position)
+	jump := len(c.code.Ops)
+	if err := c.emit(opJumpNil, 0); err != nil {
+		return err
+	}
+	if err := c.compile(ch.Key); err != nil {
+		return err
+	}
+	c.setPosition(ch.Position)
+	if err := c.emit(opChain); err != nil {
+		return err
+	}
+	return c.patch(jump)
+}
+
+func (c *compiler) compilePath(p ast.Path) error {
+	c.setPosition(p.Position)
+	if v, ok := p.Right.(ast.Variable); ok {
+		return c.emit(opMember, c.addName(p.Ident), c.addName(v.Ident))
+	}
+	call, ok := p.Right.(ast.Call)
+	if !ok {
+		return c.error(fmt.Errorf("path: %w", errCompile))
+	}
+	site, err := c.compileArguments(call)
+	if err != nil {
+		return err
+	}
+	c.setPosition(p.Position)
+	return c.emit(opCallPath, c.addName(p.Ident), c.addName(call.Ident), site)
+}
+
+func (c *compiler) compileCall(call ast.Call) error {
+	byname := call.Expr == nil && !c.isLocal(call.Ident)
+	switch {
+	case call.Expr != nil:
+		if err := c.compile(call.Expr); err != nil {
+			return err
+		}
+	case !byname:
+		c.setPosition(call.Position)
+		if err := c.compileLoad(call.Ident); err != nil {
+			return err
+		}
+	}
+	site, err := c.compileArguments(call)
+	if err != nil {
+		return err
+	}
+	c.setPosition(call.Position)
+	if byname {
+		return c.emit(opCallName, c.addName(call.Ident), site)
+	}
+	return c.emit(opCall, site)
+}
+
+func (c *compiler) compileArguments(call ast.Call) (int, error) {
+	var site callsite
+	for _, e := range call.Args {
+		var name string
+		if p, ok := e.(ast.Parameter); ok {
+			name = p.Ident
+		} else if len(site.names) > 0 && slices.Lst(site.names) != "" {
+			return 0, c.error(fmt.Errorf("expected named argument"))
+		}
+		if err := c.compile(e); err != nil {
+			return 0, err
+		}
+		site.names = append(site.names, name)
+	}
+	c.code.calls = append(c.code.calls, site)
+	return len(c.code.calls) - 1, nil
+}
+
+func (c *compiler) compileFunction(fn ast.Function) error {
+	var (
+		slot  int
+		local = fn.Ident != "" && len(c.scopes) > 0
+		err   error
+	)
+	if local {
+		if slot, err = c.declare(fn.Ident); err != nil {
+			return err
+		}
+	}
+	code, err := compileCode(fn, c)
+	if err != nil {
+		return err
+	}
+	c.code.funcs = append(c.code.funcs, code)
+	c.setPosition(fn.Position)
+	if err := c.emit(opClosure, len(c.code.funcs)-1); err != nil {
+		return err
+	}
+	switch {
+	case local:
+		return c.storeLocal(slot, true)
+	case fn.Ident != "":
+		return c.emit(opDefine, c.addName(fn.Ident))
+	default:
+		return nil
+	}
+}
+
+func (c *compiler) compileLet(e ast.Let) error {
+	if err := c.compile(e.Right); err != nil {
+		return err
+	}
+	c.setPosition(e.Position)
+	return c.define(e.Ident)
+}
+
+func (c *compiler) compileAssign(a ast.Assign) error {
+	if err := c.compile(a.Right); err != nil {
+		return err
+	}
+	c.setPosition(a.Position)
+	switch v := a.Ident.(type) {
+	case ast.Variable:
+		return c.compileStore(v.Ident)
+	case ast.Index:
+		return c.compileAssignIndex(a, v)
+	default:
+		return c.error(fmt.Errorf("assignment: %w", errCompile))
+	}
+}
+
+func (c *compiler) compileAssignIndex(a ast.Assign, ix ast.Index) error {
+	var (
+		root = ix.Arr
+		list = ix.List
+	)
+	for {
+		i, ok := root.(ast.Index)
+		if !ok {
+			break
+		}
+		root, list = i.Arr, append(append([]ast.Expression{}, i.List...), list...)
+	}
+	switch root.(type) {
+	case ast.Variable, ast.Array, ast.Dict:
+	default:
+		return c.error(fmt.Errorf("index assignment: %w", errCompile))
+	}
+	if err := c.compile(root); err != nil {
+		return err
+	}
+	var kinds []int
+	for _, e := range list {
+		kind, err := c.compileAccessor(e)
+		if err != nil {
+			return err
+		}
+		kinds = append(kinds, kind)
+	}
+	c.code.access = append(c.code.access, kinds)
+	c.setPosition(a.Position)
+	if err := c.emit(opSetIndex, len(c.code.access)-1); err != nil {
+		return err
+	}
+	if v, ok := root.(ast.Variable); ok {
+		if err := c.compileStore(v.Ident); err != nil {
+			return err
+		}
+	}
+	return c.emit(opPop)
+}
+
+func (c *compiler) compileBinary(b ast.Binary) error {
+	if err := c.compile(b.Left); err != nil {
+		return err
+	}
+	if b.Op == token.Nullish {
+		c.setPosition(b.Position)
+		jump := len(c.code.Ops)
+		if err := c.emit(opJumpNotNil, 0); err != nil {
+			return err
+		}
+		if err := c.compile(b.Right); err != nil {
+			return err
+		}
+		return c.patch(jump)
+	}
+	if err := c.compile(b.Right); err != nil {
+		return err
+	}
+	c.setPosition(b.Position)
+	return c.emit(opBinary, -int(b.Op))
+}
+
+func (c *compiler) compileListComp(lc ast.ListComp) error {
+	return c.compileComprehension(lc.Position, 0, lc.List, func() error {
+		return c.compile(lc.Body)
+	})
+}
+
+func (c *compiler) compileDictComp(dc ast.DictComp) error {
+	return c.compileComprehension(dc.Position, 1, dc.List, func() error {
+		if err := c.compile(dc.Key); err != nil {
+			return err
+		}
+		return c.compile(dc.Val)
+	})
+}
+
+func (c *compiler) compileComprehension(pos token.Position, kind int, list []ast.CompItem, body func() error) error {
+	c.setPosition(pos)
+	slot := c.hidden()
+	if err := c.emit(opCollect, kind); err != nil {
+		return err
+	}
+	if err := c.emit(opStore, slot); err != nil {
+		return err
+	}
+	if err := c.emit(opPop); err != nil {
+		return err
+	}
+	c.enterScope()
+	defer c.leaveScope()
+
+	var loop func([]ast.CompItem) error
+	loop = func(list []ast.CompItem) error {
+		if len(list) == 0 {
+			if err := body(); err != nil {
+				return err
+			}
+			c.setPosition(pos)
+			if kind == 0 {
+				return c.emit(opAppend, slot)
+			}
+			return c.emit(opAppendPair, slot)
+		}
+		curr := list[0]
+		if err := c.compile(curr.Iter); err != nil {
+			return err
+		}
+		c.setPosition(curr.Position)
+		if err := c.emit(opIter); err != nil {
+			return err
+		}
+		next := len(c.code.Ops)
+		if err := c.emit(opNext, 0); err != nil {
+			return err
+		}
+		c.enterScope()
+		if err := c.define(curr.Ident); err != nil {
+			return err
+		}
+		if err := c.emit(opPop); err != nil {
+			return err
+		}
+		var jumps []int
+		for _, e := range curr.Cdt {
+			if err := c.compile(e); err != nil {
+				return err
+			}
+			jumps = append(jumps, len(c.code.Ops))
+			if err := c.emit(opJumpFalse, 0); err != nil {
+				return err
+			}
+		}
+		if err := loop(list[1:]); err != nil {
+			return err
+		}
+		c.leaveScope()
+		for _, j := range jumps {
+			if err := c.patchTo(j, next); err != nil {
+				return err
+			}
+		}
+		if err := c.emit(opJump, next); err != nil {
+			return err
+		}
+		if err := c.patch(next); err != nil {
+			return err
+		}
+		return c.emit(opPop)
+	}
+	if err := loop(list); err != nil {
+		return err
+	}
+	if err := c.emit(opLoad, slot); err != nil {
+		return err
+	}
+	return c.emit(opFreeze)
+}
+
+func (c *compiler) compileTest(t ast.Test) error {
+	if err := c.compile(t.Cdt); err != nil {
+		return err
+	}
+	c.setPosition(t.Position)
+	jump := len(c.code.Ops)
+	if err := c.emit(opJumpFalse, 0); err != nil {
+		return err
+	}
+	if err := c.compileBlock(t.Csq); err != nil {
+		return err
+	}
+	end := len(c.code.Ops)
+	if err := c.emit(opJump, 0); err != nil {
+		return err
+	}
+	c.depth--
+	if err := c.patch(jump); err != nil {
+		return err
+	}
+	if t.Alt == nil {
+		c.setPosition(t.Position)
+		if err := c.emit(opNil); err != nil {
+			return err
+		}
+	} else if err := c.compileBlock(t.Alt); err != nil {
+		return err
+	}
+	return c.patch(end)
+}
+
+func (c *compiler) compileLoop(init, cdt, incr, body ast.Expression) error {
+	c.enterScope()
+	defer c.leaveScope()
+
+	slot := c.hidden()
+	if err := c.emit(opNil); err != nil {
+		return err
+	}
+	if err := c.emit(opStore, slot); err != nil {
+		return err
+	}
+	if err := c.emit(opPop); err != nil {
+		return err
+	}
+	if init != nil {
+		if err := c.compile(init); err != nil {
+			return err
+		}
+		if err := c.emit(opPop); err != nil {
+			return err
+		}
+	}
+	var (
+		start = len(c.code.Ops)
+		exit  = -1
+	)
+	if cdt != nil {
+		if err := c.compile(cdt); err != nil {
+			return err
+		}
+		exit = len(c.code.Ops)
+		if err := c.emit(opJumpFalse, 0); err != nil {
+			return err
+		}
+	}
+	reg := c.enterRegion(true, nil)
+	if err := c.compileBlock(body); err != nil {
+		return err
+	}
+	if err := c.emit(opStore, slot); err != nil {
+		return err
+	}
+	if err := c.emit(opPop); err != nil {
+		return err
+	}
+	c.leaveRegion()
+	for _, j := range reg.continues {
+		if err := c.patch(j); err != nil {
+			return err
+		}
+	}
+	if incr != nil {
+		if err := c.compile(incr); err != nil {
+			return err
+		}
+		if err := c.emit(opPop); err != nil {
+			return err
+		}
+	}
+	if err := c.emit(opJump, start); err != nil {
+		return err
+	}
+	if exit >= 0 {
+		if err := c.patch(exit); err != nil {
+			return err
+		}
+	}
+	for _, j := range reg.breaks {
+		if err := c.patch(j); err != nil {
+			return err
+		}
+	}
+	return c.emit(opLoad, slot)
+}
+
+func (c *compiler) compileForeach(f ast.ForEach) error {
+	c.setPosition(f.Position)
+	slot := c.hidden()
+	if err := c.emit(opNil); err != nil {
+		return err
+	}
+	if err := c.emit(opStore, slot); err != nil {
+		return err
+	}
+	if err := c.emit(opPop); err != nil {
+		return err
+	}
+	if err := c.compile(f.Iter); err != nil {
+		return err
+	}
+	c.setPosition(f.Position)
+	if err := c.emit(opIter); err != nil {
+		return err
+	}
+	next := len(c.code.Ops)
+	if err := c.emit(opNext, 0); err != nil {
+		return err
+	}
+	c.enterScope()
+	if err := c.define(f.Ident); err != nil {
+		return err
+	}
+	if err := c.emit(opPop); err != nil {
+		return err
+	}
+	reg := c.enterRegion(true, nil)
+	if err := c.compile(f.Body); err != nil {
+		return err
+	}
+	if err := c.emit(opStore, slot); err != nil {
+		return err
+	}
+	if err := c.emit(opPop); err != nil {
+		return err
+	}
+	c.leaveRegion()
+	c.leaveScope()
+	for _, j := range reg.continues {
+		if err := c.patchTo(j, next); err != nil {
+			return err
+		}
+	}
+	if err := c.emit(opJump, next); err != nil {
+		return err
+	}
+	if err := c.patch(next); err != nil {
+		return err
+	}
+	for _, j := range reg.breaks {
+		if err := c.patch(j); err != nil {
+			return err
+		}
+	}
+	if err := c.emit(opPop); err != nil {
+		return err
+	}
+	return c.emit(opLoad, slot)
+}
+
+func (c *compiler) compileReturn(r ast.Return) error {
+	if r.Right == nil {
+		c.setPosition(r.Position)
+		if err := c.emit(opNil); err != nil {
+			return err
+		}
+	} else if err := c.compile(r.Right); err != nil {
+		return err
+	}
+	if err := c.unwind(0); err != nil {
+		return err
+	}
+	c.setPosition(r.Position)
+	return c.emit(opReturn)
+}
+
+func (c *compiler) compileBreak(next bool) error {
+	var (
+		reg *region
+		pos int
+	)
+	for pos = len(c.regions) - 1; pos >= 0; pos-- {
+		if c.regions[pos].loop {
+			reg = c.regions[pos]
+			break
+		}
+	}
+	if reg == nil {
+		if next {
+			return c.error(fmt.Errorf("continue outside of loop"))
+		}
+		return c.error(fmt.Errorf("break outside of loop"))
+	}
+	depth := c.depth
+	if err := c.unwind(pos + 1); err != nil {
+		return err
+	}
+	for c.depth > reg.depth {
+		if err := c.emit(opPop); err != nil {
+			return err
+		}
+	}
+	offset := len(c.code.Ops)
+	if err := c.emit(opJump, 0); err != nil {
+		return err
+	}
+	if next {
+		reg.continues = append(reg.continues, offset)
+	} else {
+		reg.breaks = append(reg.breaks, offset)
+	}
+	c.depth = depth + 1
+	return nil
+}
+
+func (c *compiler) unwind(base int) error {
+	regions := c.regions
+	defer func() {
+		c.regions = regions
+	}()
+	for i := len(regions) - 1; i >= base; i-- {
+		reg := regions[i]
+		if reg.loop {
+			continue
+		}
+		c.regions = regions[:i]
+		if err := c.emit(opPopTry); err != nil {
+			return err
+		}
+		if reg.finally == nil {
+			continue
+		}
+		if err := c.compileBlock(reg.finally); err != nil {
+			return err
+		}
+		if err := c.emit(opPop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) compileTry(t ast.Try) error {
+	c.setPosition(t.Position)
+	var final int
+	if t.Finally != nil {
+		final = len(c.code.Ops)
+		if err := c.emit(opSetupFinally, 0); err != nil {
+			return err
+		}
+		c.enterRegion(false, t.Finally)
+	}
+	if t.Catch != nil {
+		catch := len(c.code.Ops)
+		if err := c.emit(opSetupTry, 0); err != nil {
+			return err
+		}
+		c.enterRegion(false, nil)
+		if err := c.compileBlock(t.Body); err != nil {
+			return err
+		}
+		c.leaveRegion()
+		if err := c.emit(opPopTry); err != nil {
+			return err
+		}
+		end := len(c.code.Ops)
+		if err := c.emit(opJump, 0); err != nil {
+			return err
+		}
+		if err := c.patch(catch); err != nil {
+			return err
+		}
+		c.enterScope()
+		if t.Ident != "" {
+			if err := c.define(t.Ident); err != nil {
+				return err
+			}
+		}
+		if err := c.emit(opPop); err != nil {
+			return err
+		}
+		if err := c.compile(t.Catch); err != nil {
+			return err
+		}
+		c.leaveScope()
+		if err := c.patch(end); err != nil {
+			return err
+		}
+	} else if err := c.compileBlock(t.Body); err != nil {
+		return err
+	}
+	if t.Finally == nil {
+		return nil
+	}
+	c.leaveRegion()
+	if err := c.emit(opPopTry); err != nil {
+		return err
+	}
+	if err := c.compileBlock(t.Finally); err != nil {
+		return err
+	}
+	if err := c.emit(opPop); err != nil {
+		return err
+	}
+	end := len(c.code.Ops)
+	if err := c.emit(opJump, 0); err != nil {
+		return err
+	}
+	c.depth--
+	if err := c.patch(final); err != nil {
+		return err
+	}
+	if err := c.compileBlock(t.Finally); err != nil {
+		return err
+	}
+	if err := c.emit(opPop); err != nil {
+		return err
+	}
+	if err := c.emit(opEndFinally); err != nil {
+		return err
+	}
+	c.depth++
+	return c.patch(end)
+}
+
+func (c *compiler) compileLoad(ident string) error {
+	if slot, ok := c.resolveLocal(ident); ok {
+		if c.code.boxed {
+			return c.emit(opLoadCell, slot)
+		}
+		return c.emit(opLoad, slot)
+	}
+	if ix, ok := c.resolveFree(ident); ok {
+		return c.emit(opLoadFree, ix)
+	}
+	return c.emit(opLoadName, c.addName(ident))
+}
+
+func (c *compiler) compileStore(ident string) error {
+	if slot, ok := c.resolveLocal(ident); ok {
+		return c.storeLocal(slot, false)
+	}
+	if ix, ok := c.resolveFree(ident); ok {
+		return c.emit(opStoreFree, ix)
+	}
+	return c.emit(opStoreName, c.addName(ident))
+}
+
+func (c *compiler) storeLocal(slot int, declare bool) error {
+	switch {
+	case !c.code.boxed:
+		return c.emit(opStore, slot)
+	case declare:
+		return c.emit(opDeclareCell, slot)
+	default:
+		return c.emit(opStoreCell, slot)
+	}
+}
+
+func (c *compiler) define(ident string) error {
+	if len(c.scopes) == 0 {
+		return c.emit(opDefine, c.addName(ident))
+	}
+	slot, err := c.declare(ident)
+	if err != nil {
+		return err
+	}
+	return c.storeLocal(slot, true)
+}
+
+func (c *compiler) declare(ident string) (int, error) {
+	scope := c.scopes[len(c.scopes)-1]
+	if _, ok := scope[ident]; ok {
+		return 0, c.error(fmt.Errorf("%s: variable already defined", ident))
+	}
+	c.code.locals = append(c.code.locals, ident)
+	scope[ident] = len(c.code.locals) - 1
+	return scope[ident], nil
+}
+
+func (c *compiler) hidden() int {
+	c.code.locals = append(c.code.locals, "")
+	return len(c.code.locals) - 1
+}
+
+func (c *compiler) isLocal(ident string) bool {
+	if _, ok := c.resolveLocal(ident); ok {
+		return true
+	}
+	_, ok := c.resolveFree(ident)
+	return ok
+}
+
+func (c *compiler) resolveLocal(ident string) (int, bool) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if slot, ok := c.scopes[i][ident]; ok {
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+func (c *compiler) resolveFree(ident string) (int, bool) {
+	for i, f := range c.code.frees {
+		if f.name == ident {
+			return i, true
+		}
+	}
+	if c.parent == nil {
+		return 0, false
+	}
+	up := upvalue{
+		name: ident,
+	}
+	if slot, ok := c.parent.resolveLocal(ident); ok {
+		up.local, up.index = true, slot
+		c.parent.captured = true
+	} else if ix, ok := c.parent.resolveFree(ident); ok {
+		up.index = ix
+	} else {
+		return 0, false
+	}
+	c.code.frees = append(c.code.frees, up)
+	return len(c.code.frees) - 1, true
+}
+
+func (c *compiler) addName(ident string) int {
+	for i := range c.code.names {
+		if c.code.names[i] == ident {
+			return i
+		}
+	}
+	c.code.names = append(c.code.names, ident)
+	return len(c.code.names) - 1
+}
+
+func (c *compiler) emitConst(value types.Primitive) error {
+	for i := range c.code.consts {
+		if c.code.consts[i] == value {
+			return c.emit(opConst, i)
+		}
+	}
+	c.code.consts = append(c.code.consts, value)
+	return c.emit(opConst, len(c.code.consts)-1)
+}
+
+func (c *compiler) emit(op opcode, args ...int) error {
+	if n := len(c.code.lines); n == 0 || c.code.lines[n-1].Position != c.pos {
+		c.code.lines = append(c.code.lines, lineinfo{
+			offset:   len(c.code.Ops),
+			Position: c.pos,
+		})
+	}
+	c.code.Ops = append(c.code.Ops, byte(op))
+	for _, a := range args {
+		if a < 0 || a > math.MaxUint16 {
+			return c.error(fmt.Errorf("%s: operand out of range", op))
+		}
+		c.code.Ops = binary.BigEndian.AppendUint16(c.code.Ops, uint16(a))
+	}
+	c.depth += stackEffect(op, args, c.code)
+	if c.depth > c.code.stack {
+		c.code.stack = c.depth
+	}
+	return nil
+}
+
+func (c *compiler) patch(offset int) error {
+	return c.patchTo(offset, len(c.code.Ops))
+}
+
+func (c *compiler) patchTo(offset, target int) error {
+	op := opcode(c.code.Ops[offset])
+	return c.patchAt(offset+1+(op.Width()-1)*2, target)
+}
+
+func (c *compiler) patchAt(offset, target int) error {
+	if target > math.MaxUint16 {
+		return c.error(fmt.Errorf("jump target out of range"))
+	}
+	binary.BigEndian.PutUint16(c.code.Ops[offset:], uint16(target))
+	return nil
+}
+
+func (c *compiler) setPosition(pos token.Position) {
+	c.pos = pos
+}
+
+func (c *compiler) enterScope() {
+	c.scopes = append(c.scopes, make(map[string]int))
+}
+
+func (c *compiler) leaveScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *compiler) enterRegion(loop bool, finally ast.Expression) *region {
+	reg := region{
+		loop:    loop,
+		finally: finally,
+		depth:   c.depth,
+	}
+	c.regions = append(c.regions, &reg)
+	return &reg
+}
+
+func (c *compiler) leaveRegion() {
+	c.regions = c.regions[:len(c.regions)-1]
+}
+
+func stackEffect(op opcode, args []int, code *Code) int {
+	switch op {
+	case opConst, opNil, opLoad, opLoadCell, opLoadFree, opLoadName, opClosure, opCollect, opImport, opMember:
+		return 1
+	case opAccess:
+		return -accessCount(args[0])
+	case opPop, opBinary, opChain, opJumpFalse, opAppend, opEndFinally:
+		return -1
+	case opAppendPair:
+		return -2
+	case opArray, opConcat:
+		return 1 - args[0]
+	case opDict:
+		return 1 - 2*args[0]
+	case opSetIndex:
+		n := 1
+		for _, k := range code.access[args[0]] {
+			n += accessCount(k)
+		}
+		return 1 - n
+	case opCall:
+		return -len(code.calls[args[0]].names)
+	case opCallName:
+		return 1 - len(code.calls[args[1]].names)
+	case opCallPath:
+		return 1 - len(code.calls[args[2]].names)
+	case opNext:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func accessCount(kind int) int {
+	if kind&accSlice == 0 {
+		return 1
+	}
+	var n int
+	for _, f := range []int{accStart, accEnd, accStep} {
+		if kind&f != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *compiler) error(err error) error {
+	return positionError{
+		Token: token.Token{
+			Position: c.pos,
+		},
+		err: err,
+	}
+}
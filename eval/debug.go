@@ -0,0 +1,329 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/parse"
+	"github.com/midbel/buddy/token"
+	"github.com/midbel/buddy/types"
+)
+
+type Hook interface {
+	Statement(*Interpreter, ast.Expression) error
+	Enter(*Interpreter, Frame) error
+	Leave(*Interpreter, Frame, types.Primitive, error)
+}
+
+type Frame struct {
+	Func     string
+	Module   string
+	File     string
+	Position token.Position
+	Env      *types.Environ
+}
+
+func (i *Interpreter) Frames() []Frame {
+	return append([]Frame{}, i.frames...)
+}
+
+func (i *Interpreter) Evaluate(level int, str string) (types.Primitive, error) {
+	if level < 0 || level >= len(i.frames) {
+		return nil, fmt.Errorf("%d: frame not found", level)
+	}
+	expr, err := parse.New(strings.NewReader(str)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		hook = i.Hook
+		env  = i.Environ
+	)
+	i.Hook = nil
+	i.Environ = i.frames[level].Env
+	defer func() {
+		i.Hook = hook
+		i.Environ = env
+	}()
+	res, err := execute(expr, i)
+	for curr := err; curr != nil; curr = errors.Unwrap(curr) {
+		if e, ok := curr.(positionError); ok {
+			err = e.err
+		}
+	}
+	return res, err
+}
+
+func (i *Interpreter) pushFrame(fn string, pos token.Position) Frame {
+	var (
+		mod  = i.stack.Top()
+		file string
+	)
+	if m, ok := mod.(*userModule); ok {
+		file = m.file
+	}
+	frame := Frame{
+		Func:     fn,
+		Module:   mod.Id(),
+		File:     file,
+		Position: pos,
+		Env:      i.Environ,
+	}
+	i.frames = append(i.frames, frame)
+	return frame
+}
+
+func (i *Interpreter) popFrame() {
+	if n := len(i.frames); n > 0 {
+		i.frames = i.frames[:n-1]
+	}
+}
+
+func (i *Interpreter) enterFrame(fn string, pos token.Position) error {
+	frame := i.pushFrame(fn, pos)
+	if err := i.Hook.Enter(i, frame); err != nil {
+		i.popFrame()
+		return err
+	}
+	return nil
+}
+
+func (i *Interpreter) leaveFrame(res types.Primitive, err error) {
+	n := len(i.frames)
+	if n == 0 {
+		return
+	}
+	i.Hook.Leave(i, i.frames[n-1], res, err)
+	i.popFrame()
+}
+
+func (i *Interpreter) statement(expr ast.Expression) error {
+	if n := len(i.frames); n > 0 {
+		top := &i.frames[n-1]
+		if p, ok := expr.(interface{ Pos() token.Position }); ok {
+			top.Position = p.Pos()
+		}
+		top.Env = i.Environ
+	}
+	return i.Hook.Statement(i, expr)
+}
+
+const (
+	StopEntry      = "entry"
+	StopStep       = "step"
+	StopBreakpoint = "breakpoint"
+	StopPause      = "pause"
+)
+
+var ErrTerminated = errors.New("execution terminated")
+
+type StepMode int
+
+const (
+	StepNone StepMode = iota
+	StepIn
+	StepOver
+	StepOut
+)
+
+type Breakpoint struct {
+	Id   int
+	File string
+	Line int
+	Cond string
+}
+
+type Stop struct {
+	Reason     string
+	Breakpoint Breakpoint
+	Frame      Frame
+	Err        error
+}
+
+type location struct {
+	file   string
+	line   int
+	column int
+	depth  int
+}
+
+type Debugger struct {
+	OnStop   func(*Interpreter, Stop) error
+	OnReturn func(*Interpreter, Frame, types.Primitive)
+
+	mu      sync.Mutex
+	breaks  []Breakpoint
+	lastId  int
+	mode    StepMode
+	depth   int
+	prev    location
+	started bool
+	pause   bool
+	done    bool
+}
+
+func (d *Debugger) SetBreakpoint(file string, line int, cond string) (Breakpoint, error) {
+	if line <= 0 {
+		return Breakpoint{}, fmt.Errorf("%d: invalid line", line)
+	}
+	if cond != "" {
+		if _, err := parse.New(strings.NewReader(cond)).Parse(); err != nil {
+			return Breakpoint{}, err
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastId++
+	b := Breakpoint{
+		Id:   d.lastId,
+		File: file,
+		Line: line,
+		Cond: cond,
+	}
+	d.breaks = append(d.breaks, b)
+	return b, nil
+}
+
+func (d *Debugger) ClearBreakpoint(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for j := range d.breaks {
+		if d.breaks[j].Id == id {
+			d.breaks = append(d.breaks[:j], d.breaks[j+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var list []Breakpoint
+	for _, b := range d.breaks {
+		if !sameFile(b.File, file) {
+			list = append(list, b)
+		}
+	}
+	d.breaks = list
+}
+
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Breakpoint{}, d.breaks...)
+}
+
+func (d *Debugger) Resume(i *Interpreter, mode StepMode) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mode = mode
+	d.depth = len(i.frames)
+}
+
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pause = true
+}
+
+func (d *Debugger) Terminate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.done = true
+}
+
+func (d *Debugger) Statement(i *Interpreter, expr ast.Expression) error {
+	if len(i.frames) == 0 {
+		return nil
+	}
+	d.mu.Lock()
+	if d.done {
+		d.mu.Unlock()
+		return ErrTerminated
+	}
+	var (
+		top  = i.frames[len(i.frames)-1]
+		curr = location{
+			file:   top.File,
+			line:   top.Position.Line,
+			column: top.Position.Column,
+			depth:  len(i.frames),
+		}
+		nested = curr.file == d.prev.file && curr.depth == d.prev.depth && curr.line == d.prev.line && curr.column > d.prev.column
+		stop   = Stop{
+			Frame: top,
+		}
+	)
+	d.prev = curr
+	switch {
+	case d.pause:
+		stop.Reason = StopPause
+		d.pause = false
+	case d.mode == StepIn && !d.started:
+		stop.Reason = StopEntry
+	case d.mode == StepIn:
+		stop.Reason = StopStep
+	case d.mode == StepOver && !nested && curr.depth <= d.depth:
+		stop.Reason = StopStep
+	case d.mode == StepOut && !nested && curr.depth < d.depth:
+		stop.Reason = StopStep
+	}
+	d.started = true
+	breaks := append([]Breakpoint{}, d.breaks...)
+	d.mu.Unlock()
+
+	if stop.Reason == "" && !nested {
+		for _, b := range breaks {
+			if b.Line != curr.line || !sameFile(b.File, curr.file) {
+				continue
+			}
+			if b.Cond != "" {
+				res, err := i.Evaluate(len(i.frames)-1, b.Cond)
+				if err == nil && !res.True() {
+					continue
+				}
+				stop.Err = err
+			}
+			stop.Reason = StopBreakpoint
+			stop.Breakpoint = b
+			break
+		}
+	}
+	if stop.Reason == "" || d.OnStop == nil {
+		return nil
+	}
+	return d.OnStop(i, stop)
+}
+
+func (d *Debugger) Enter(i *Interpreter, frame Frame) error {
+	return nil
+}
+
+func (d *Debugger) Leave(i *Interpreter, frame Frame, res types.Primitive, err error) {
+	if d.OnReturn == nil || err != nil {
+		return
+	}
+	d.mu.Lock()
+	ok := len(i.frames) == d.depth && (d.mode == StepOver || d.mode == StepOut)
+	d.mu.Unlock()
+	if ok {
+		d.OnReturn(i, frame, res)
+	}
+}
+
+func sameFile(want, file string) bool {
+	if filepath.Clean(want) == filepath.Clean(file) {
+		return true
+	}
+	if !strings.ContainsRune(want, filepath.Separator) {
+		return filepath.Base(file) == want
+	}
+	w, err1 := filepath.Abs(want)
+	f, err2 := filepath.Abs(file)
+	return err1 == nil && err2 == nil && w == f
+}
@@ -0,0 +1,224 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/midbel/buddy/builtins"
+	"github.com/midbel/buddy/faults"
+	"github.com/midbel/buddy/token"
+	"github.com/midbel/buddy/types"
+)
+
+const (
+	KindIncompatible = "incompatible"
+	KindOperation    = "operation"
+	KindZero         = "zero"
+	KindAssert       = "assert"
+	KindType         = "type"
+	KindIndex        = "index"
+	KindKey          = "key"
+	KindRaise        = "raise"
+	KindRuntime      = "runtime"
+)
+
+type positionError struct {
+	token.Token
+	err error
+}
+
+func (e positionError) Error() string {
+	return fmt.Sprintf("at %s: %s", e.Position, e.err)
+}
+
+func (e positionError) Unwrap() error {
+	return e.err
+}
+
+type callError struct {
+	fn     string
+	module string
+	file   string
+	err    error
+}
+
+func (e callError) Error() string {
+	return fmt.Sprintf("%s: %s", e.fn, e.err)
+}
+
+func (e callError) Unwrap() error {
+	return e.err
+}
+
+type AssertError struct {
+	Op    rune
+	Left  types.Primitive
+	Right types.Primitive
+	Value types.Primitive
+}
+
+func (e AssertError) Error() string {
+	if e.Left == nil || e.Right == nil {
+		return types.ErrAssert.Error()
+	}
+	return fmt.Sprintf("%s: %s %s %s", types.ErrAssert, e.Left, comparison(e.Op), e.Right)
+}
+
+func (e AssertError) Unwrap() error {
+	return types.ErrAssert
+}
+
+func comparison(op rune) string {
+	switch op {
+	case token.Eq:
+		return "=="
+	case token.Ne:
+		return "!="
+	case token.Lt:
+		return "<"
+	case token.Le:
+		return "<="
+	case token.Gt:
+		return ">"
+	case token.Ge:
+		return ">="
+	default:
+		return "?"
+	}
+}
+
+type raiseError struct {
+	value types.Primitive
+}
+
+func (e raiseError) Error() string {
+	return e.value.String()
+}
+
+func (i *Interpreter) callError(fn string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var (
+		mod  = i.stack.Top()
+		file string
+	)
+	if m, ok := mod.(*userModule); ok {
+		file = m.file
+	}
+	return callError{
+		fn:     fn,
+		module: mod.Id(),
+		file:   file,
+		err:    err,
+	}
+}
+
+func (i *Interpreter) runtimeError(err error) error {
+	if err == nil || builtins.IsExit(err) {
+		return err
+	}
+	var (
+		rerr = faults.RuntimeError{
+			Err: err,
+		}
+		file  string
+		found bool
+	)
+	if m, ok := i.stack.Top().(*userModule); ok {
+		file = m.file
+	}
+	for curr := err; curr != nil; curr = errors.Unwrap(curr) {
+		switch e := curr.(type) {
+		case positionError:
+			rerr.Token = e.Token
+			rerr.File = file
+			rerr.Message = e.err.Error()
+			found = true
+		case callError:
+			rerr.Frames = append(rerr.Frames, faults.Frame{
+				Func:     e.fn,
+				Module:   e.module,
+				File:     file,
+				Position: rerr.Position,
+			})
+			file = e.file
+		}
+	}
+	if !found {
+		return err
+	}
+	rerr.Line = i.sourceLine(rerr.File, rerr.Position.Line)
+	return &rerr
+}
+
+func isCatchable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, errReturn):
+	case errors.Is(err, errBreak):
+	case errors.Is(err, errContinue):
+	case builtins.IsExit(err):
+	case errors.Is(err, ErrStepLimit):
+	case errors.Is(err, ErrMemoryLimit):
+	case errors.Is(err, ErrInterrupted):
+	default:
+		return true
+	}
+	return false
+}
+
+func errorValue(err error) types.Primitive {
+	var rerr raiseError
+	if errors.As(err, &rerr) {
+		if _, ok := rerr.value.(types.Dict); ok {
+			return rerr.value
+		}
+	}
+	var (
+		pos  token.Position
+		msg  = err.Error()
+		curr = err
+	)
+	for curr != nil {
+		if e, ok := curr.(positionError); ok {
+			pos = e.Position
+			msg = e.err.Error()
+		}
+		curr = errors.Unwrap(curr)
+	}
+	dict := types.CreateDict().(types.Dict)
+	dict.Set(types.CreateString("message"), types.CreateString(msg))
+	dict.Set(types.CreateString("kind"), types.CreateString(errorKind(err)))
+	dict.Set(types.CreateString("line"), types.CreateInt(int64(pos.Line)))
+	dict.Set(types.CreateString("column"), types.CreateInt(int64(pos.Column)))
+	if rerr.value != nil {
+		dict.Set(types.CreateString("value"), rerr.value)
+	}
+	return dict
+}
+
+func errorKind(err error) string {
+	var rerr raiseError
+	switch {
+	case errors.As(err, &rerr):
+		return KindRaise
+	case errors.Is(err, types.ErrIncompatible):
+		return KindIncompatible
+	case errors.Is(err, types.ErrZero):
+		return KindZero
+	case errors.Is(err, types.ErrAssert):
+		return KindAssert
+	case errors.Is(err, types.ErrOperation):
+		return KindOperation
+	case errors.Is(err, types.ErrIndex):
+		return KindIndex
+	case errors.Is(err, types.ErrKey):
+		return KindKey
+	case errors.Is(err, builtins.ErrType):
+		return KindType
+	default:
+		return KindRuntime
+	}
+}
@@ -0,0 +1,33 @@
+package eval
+
+import "testing"
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		Expr string
+		Kind string
+	}{
+		{Expr: `1 < "a"`, Kind: KindIncompatible},
+		{Expr: `true * 2`, Kind: KindOperation},
+		{Expr: `1 / 0`, Kind: KindZero},
+		{Expr: `assert 1 == 2`, Kind: KindAssert},
+		{Expr: `strings.repeat("a", "b")`, Kind: KindType},
+		{Expr: `raise "boom"`, Kind: KindRaise},
+		{Expr: `unknown`, Kind: KindRuntime},
+		{Expr: `[1, 2][5]`, Kind: KindIndex},
+		{Expr: `{"a": 1}["b"]`, Kind: KindKey},
+	}
+	for _, e := range []Engine{TreeWalker, Bytecode} {
+		for _, tt := range tests {
+			src := "import strings\nlet kind = nil\ntry {\n\t" + tt.Expr + "\n} catch(e) {\n\tkind = e[\"kind\"]\n}\nkind"
+			got, err := runEngine(src, e)
+			if err != "" {
+				t.Errorf("%s (%s): unexpected error: %s", tt.Expr, e, err)
+				continue
+			}
+			if got != tt.Kind {
+				t.Errorf("%s (%s): want kind %q, got %q", tt.Expr, e, tt.Kind, got)
+			}
+		}
+	}
+}
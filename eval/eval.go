@@ -4,10 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/midbel/buddy/ast"
-	"github.com/midbel/buddy/builtins"
-	"github.com/midbel/buddy/parse"
 	"github.com/midbel/buddy/token"
 	"github.com/midbel/buddy/types"
 	"github.com/midbel/slices"
@@ -26,47 +25,11 @@ func Eval(r io.Reader) (types.Primitive, error) {
 }
 
 func EvalEnv(r io.Reader, env *types.Environ) (types.Primitive, error) {
-	expr, err := parse.New(r).Parse()
-	if err != nil {
-		return nil, err
-	}
-	bud := New(env)
-	if s, ok := expr.(ast.Script); ok {
-		mod, ok := bud.stack.Top().(*userModule)
-		if !ok {
-			return nil, fmt.Errorf("fail to initialize main module")
-		}
-		for k, expr := range s.Symbols {
-			call, err := callableFromExpression(expr)
-			if err != nil {
-				return nil, err
-			}
-			if err := mod.Append(k, call); err != nil {
-				return nil, err
-			}
-		}
-	}
-	return eval(expr, bud)
+	return New(env).Eval(r)
 }
 
 func Execute(expr ast.Expression, env *types.Environ) (types.Primitive, error) {
-	bud := New(env)
-	if s, ok := expr.(ast.Script); ok {
-		mod, ok := bud.stack.Top().(*userModule)
-		if !ok {
-			return nil, fmt.Errorf("fail to initialize main module")
-		}
-		for k, expr := range s.Symbols {
-			call, err := callableFromExpression(expr)
-			if err != nil {
-				return nil, err
-			}
-			if err := mod.Append(k, call); err != nil {
-				return nil, err
-			}
-		}
-	}
-	return eval(expr, bud)
+	return New(env).Exec(expr)
 }
 
 func eval(expr ast.Expression, env *Interpreter) (types.Primitive, error) {
@@ -74,6 +37,9 @@ func eval(expr ast.Expression, env *Interpreter) (types.Primitive, error) {
 		res types.Primitive
 		err error
 	)
+	if err := env.step(); err != nil {
+		return nil, err
+	}
 	switch e := expr.(type) {
 	case ast.Literal:
 		res = types.CreateString(e.Str)
@@ -83,69 +49,89 @@ func eval(expr ast.Expression, env *Interpreter) (types.Primitive, error) {
 		res = types.CreateInt(e.Value)
 	case ast.Boolean:
 		res = types.CreateBool(e.Value)
+	case ast.Nil:
+		res = types.CreateNil()
+	case ast.Template:
+		res, err = env.allocate(evalTemplate(e, env))
+		err = wrapError(err, e.Token)
+	case ast.Placeholder:
+		res, err = evalPlaceholder(e, env)
+		err = wrapError(err, e.Token)
+	case ast.Chain:
+		res, err = evalChain(e, env)
+		err = wrapError(err, e.Token)
 	case ast.Variable:
-		res, err = env.Resolve(e.Ident)
-		err = wrapError(err, e.Position)
+		res, err = evalVariable(e, env)
+		err = wrapError(err, e.Token)
 	case ast.Array:
-		res, err = evalArray(e, env)
-		err = wrapError(err, e.Position)
+		res, err = env.allocate(evalArray(e, env))
+		err = wrapError(err, e.Token)
 	case ast.Dict:
-		res, err = evalDict(e, env)
-		err = wrapError(err, e.Position)
+		res, err = env.allocate(evalDict(e, env))
+		err = wrapError(err, e.Token)
 	case ast.Index:
 		res, err = evalIndex(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Path:
 		res, err = evalPath(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Call:
 		res, err = evalCall(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
+	case ast.Function:
+		res, err = evalFunction(e, env)
+		err = wrapError(err, e.Token)
 	case ast.Parameter:
 		res, err = eval(e.Expr, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Assert:
 		res, err = evalAssert(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Let:
 		res, err = evalLet(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Assign:
 		res, err = evalAssign(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Unary:
 		res, err = evalUnary(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Binary:
 		res, err = evalBinary(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.ListComp:
 		res, err = evalListComp(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.DictComp:
 		res, err = evalDictComp(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Test:
 		res, err = evalTest(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.While:
 		res, err = evalWhile(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.For:
 		res, err = evalFor(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.ForEach:
 		res, err = evalForeach(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Import:
 		res, err = evalImport(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Script:
 		res, err = evalScript(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
 	case ast.Return:
 		res, err = evalReturn(e, env)
-		err = wrapError(err, e.Position)
+		err = wrapError(err, e.Token)
+	case ast.Try:
+		res, err = evalTry(e, env)
+		err = wrapError(err, e.Token)
+	case ast.Raise:
+		res, err = evalRaise(e, env)
+		err = wrapError(err, e.Token)
 	case ast.Break:
 		return nil, errBreak
 	case ast.Continue:
@@ -153,9 +139,45 @@ func eval(expr ast.Expression, env *Interpreter) (types.Primitive, error) {
 	default:
 		return nil, fmt.Errorf("eval: %w", errEval)
 	}
+	if res == nil && err == nil {
+		res = types.CreateNil()
+	}
 	return res, err
 }
 
+func evalVariable(v ast.Variable, env *Interpreter) (types.Primitive, error) {
+	return resolveVariable(v.Ident, env)
+}
+
+func resolveVariable(ident string, env *Interpreter) (types.Primitive, error) {
+	res, err := env.Resolve(ident)
+	if err == nil {
+		return res, nil
+	}
+	if call, err1 := env.Lookup("", ident); err1 == nil {
+		return types.CreateFunction(ident, call), nil
+	}
+	if call, err1 := env.lookupBuiltin(ident); err1 == nil {
+		return types.CreateFunction(ident, call), nil
+	}
+	return nil, err
+}
+
+func evalFunction(f ast.Function, env *Interpreter) (types.Primitive, error) {
+	call := userCallable{
+		fun: f,
+		env: env.Environ,
+		mod: env.stack.Top(),
+	}
+	fn := types.CreateFunction(f.Ident, call)
+	if f.Ident != "" {
+		if err := env.Define(f.Ident, fn); err != nil {
+			return nil, err
+		}
+	}
+	return fn, nil
+}
+
 func evalArray(a ast.Array, env *Interpreter) (types.Primitive, error) {
 	var list []types.Primitive
 	for i := range a.List {
@@ -188,33 +210,68 @@ func evalDict(a ast.Dict, env *Interpreter) (types.Primitive, error) {
 }
 
 func evalIndex(i ast.Index, env *Interpreter) (types.Primitive, error) {
-	p, err := eval(i.Arr, env)
+	res, err := eval(i.Arr, env)
 	if err != nil {
 		return nil, err
 	}
-	c, ok := p.(types.Container)
-	if !ok {
-		return nil, types.ContainerError(p)
-	}
-	var res types.Primitive
-	for _, e := range slices.Slice(i.List) {
-		ix, err := eval(e, env)
+	for _, e := range i.List {
+		acc, err := evalAccessor(e, env)
 		if err != nil {
 			return nil, err
 		}
-		if res, err = c.Get(ix); err != nil {
+		if res, err = acc.Get(res); err != nil {
 			return nil, err
 		}
-		c, ok = res.(types.Container)
-		if !ok {
-			return nil, types.ContainerError(res)
+	}
+	return res, nil
+}
+
+func evalTemplate(t ast.Template, env *Interpreter) (types.Primitive, error) {
+	var str strings.Builder
+	for i := range t.List {
+		res, err := eval(t.List[i], env)
+		if err != nil {
+			return nil, err
 		}
+		str.WriteString(res.String())
 	}
-	res, err = eval(slices.Lst(i.List), env)
+	return types.CreateString(str.String()), nil
+}
+
+func evalPlaceholder(p ast.Placeholder, env *Interpreter) (types.Primitive, error) {
+	res, err := eval(p.Expr, env)
+	if err != nil {
+		return nil, err
+	}
+	str, err := formatValue(res, p.Spec)
 	if err != nil {
 		return nil, err
 	}
-	return c.Get(res)
+	return types.CreateString(str), nil
+}
+
+func evalChain(c ast.Chain, env *Interpreter) (types.Primitive, error) {
+	res, err := eval(c.Left, env)
+	if err != nil || types.IsNil(res) {
+		return res, err
+	}
+	key, err := eval(c.Key, env)
+	if err != nil {
+		return nil, err
+	}
+	return getChain(res, key)
+}
+
+func getChain(res, key types.Primitive) (types.Primitive, error) {
+	ct, ok := res.(types.Container)
+	if !ok {
+		return nil, types.ContainerError(res)
+	}
+	res, err := ct.Get(key)
+	if errors.Is(err, types.ErrKey) || errors.Is(err, types.ErrIndex) {
+		return types.CreateNil(), nil
+	}
+	return res, err
 }
 
 func evalPath(p ast.Path, env *Interpreter) (types.Primitive, error) {
@@ -227,6 +284,8 @@ func evalPath(p ast.Path, env *Interpreter) (types.Primitive, error) {
 		return env.Call(p.Ident, right.Ident, func(call types.Callable) (types.Primitive, error) {
 			return call.Call(env, args)
 		})
+	case ast.Variable:
+		return env.member(p.Ident, right.Ident)
 	default:
 		return nil, fmt.Errorf("path: %w", errEval)
 	}
@@ -237,6 +296,16 @@ func evalCall(c ast.Call, env *Interpreter) (types.Primitive, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.Expr != nil {
+		fn, err := eval(c.Expr, env)
+		if err != nil {
+			return nil, err
+		}
+		return env.Apply(fn, args)
+	}
+	if fn, err := env.Resolve(c.Ident); err == nil {
+		return env.Apply(fn, args)
+	}
 	return env.Call("", c.Ident, func(call types.Callable) (types.Primitive, error) {
 		return call.Call(env, args)
 	})
@@ -273,16 +342,52 @@ func evalArguments(c ast.Call, env *Interpreter) ([]types.Argument, error) {
 }
 
 func evalAssert(a ast.Assert, env *Interpreter) (types.Primitive, error) {
+	if b, ok := a.Expr.(ast.Binary); ok && isComparison(b.Op) {
+		return evalAssertCompare(b, env)
+	}
 	res, err := eval(a.Expr, env)
 	if err != nil {
 		return nil, err
 	}
 	if !res.True() {
-		return nil, types.ErrAssert
+		return nil, AssertError{Value: res}
 	}
 	return res, nil
 }
 
+func evalAssertCompare(b ast.Binary, env *Interpreter) (types.Primitive, error) {
+	left, err := eval(b.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := eval(b.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	res, err := executeBinary(b.Op, left, right)
+	if err != nil {
+		return nil, wrapError(err, b.Token)
+	}
+	if !res.True() {
+		return nil, AssertError{
+			Op:    b.Op,
+			Left:  left,
+			Right: right,
+			Value: res,
+		}
+	}
+	return res, nil
+}
+
+func isComparison(op rune) bool {
+	switch op {
+	case token.Eq, token.Ne, token.Lt, token.Le, token.Gt, token.Ge:
+		return true
+	default:
+		return false
+	}
+}
+
 func evalLet(e ast.Let, env *Interpreter) (types.Primitive, error) {
 	res, err := eval(e.Right, env)
 	if err != nil {
@@ -320,11 +425,17 @@ func evalBinary(b ast.Binary, env *Interpreter) (types.Primitive, error) {
 	if err != nil {
 		return nil, err
 	}
+	if b.Op == token.Nullish {
+		if !types.IsNil(left) {
+			return left, nil
+		}
+		return eval(b.Right, env)
+	}
 	right, err := eval(b.Right, env)
 	if err != nil {
 		return nil, err
 	}
-	return executeBinary(b.Op, left, right)
+	return env.binary(b.Op, left, right)
 }
 
 func evalListComp(lc ast.ListComp, env *Interpreter) (types.Primitive, error) {
@@ -339,6 +450,7 @@ func evalListComp(lc ast.ListComp, env *Interpreter) (types.Primitive, error) {
 		res, err := eval(lc.Body, env)
 		if err == nil {
 			arr = append(arr, res)
+			err = env.Reserve(types.SizeItem)
 		}
 		return err
 	})
@@ -366,7 +478,7 @@ func evalDictComp(dc ast.DictComp, env *Interpreter) (types.Primitive, error) {
 			return err
 		}
 		dict.(types.Dict).Set(key, val)
-		return nil
+		return env.Reserve(types.SizeEntry)
 	})
 	if err != nil {
 		return nil, err
@@ -388,6 +500,11 @@ func evalCompItem(cis []ast.CompItem, env *Interpreter, do func() error) error {
 		return types.IterationError(it)
 	}
 	return iter.Iter(func(p types.Primitive) error {
+		if err := env.Interrupted(); err != nil {
+			return err
+		}
+		env.enterScope()
+		defer env.leaveScope()
 		env.Define(curr.Ident, p)
 		for i := range curr.Cdt {
 			res, err := eval(curr.Cdt[i], env)
@@ -399,8 +516,6 @@ func evalCompItem(cis []ast.CompItem, env *Interpreter, do func() error) error {
 			}
 		}
 		if len(cis) > 1 {
-			env.enterScope()
-			defer env.leaveScope()
 			return evalCompItem(slices.Rest(cis), env, do)
 		}
 		return do()
@@ -450,6 +565,9 @@ func evalLoop(body, cdt, incr ast.Expression, env *Interpreter) (types.Primitive
 		err error
 	)
 	for {
+		if err := env.Interrupted(); err != nil {
+			return nil, err
+		}
 		tmp, err1 := eval(cdt, env)
 		if err1 != nil {
 			return nil, err1
@@ -462,10 +580,9 @@ func evalLoop(body, cdt, incr ast.Expression, env *Interpreter) (types.Primitive
 			if errors.Is(err, errBreak) {
 				break
 			}
-			if errors.Is(err, errContinue) {
-				continue
+			if !errors.Is(err, errContinue) {
+				return nil, err
 			}
-			return nil, err
 		}
 		if incr != nil {
 			_, err := eval(incr, env)
@@ -488,13 +605,22 @@ func evalForeach(f ast.ForEach, env *Interpreter) (types.Primitive, error) {
 	}
 	var res types.Primitive
 	err = iter.Iter(func(p types.Primitive) error {
+		if err := env.Interrupted(); err != nil {
+			return err
+		}
 		env.enterScope()
 		defer env.leaveScope()
 		env.Define(f.Ident, p)
 
 		res, err = eval(f.Body, env)
+		if errors.Is(err, errContinue) {
+			err = nil
+		}
 		return err
 	})
+	if errors.Is(err, errBreak) {
+		err = nil
+	}
 	return res, err
 }
 
@@ -508,34 +634,79 @@ func evalScript(s ast.Script, env *Interpreter) (types.Primitive, error) {
 		err error
 	)
 	for i := range s.List {
-		res, err = eval(s.List[i], env)
-		if err != nil && !errors.Is(err, errReturn) {
-			if builtins.IsExit(err) {
-				return res, err
+		if env.Hook != nil {
+			if err = env.statement(s.List[i]); err != nil {
+				break
 			}
-			break
 		}
-		if errors.Is(err, errReturn) {
-			return res, nil
+		res, err = eval(s.List[i], env)
+		if err != nil {
+			break
 		}
 	}
 	return res, err
 }
 
 func evalReturn(ret ast.Return, env *Interpreter) (types.Primitive, error) {
-	var (
-		res types.Primitive
-		err error
-	)
-	if ret.Right != nil {
-		res, err = eval(ret.Right, env)
-		if err == nil {
-			err = errReturn
+	if ret.Right == nil {
+		return types.CreateNil(), errReturn
+	}
+	res, err := eval(ret.Right, env)
+	if err == nil {
+		err = errReturn
+	}
+	return res, err
+}
+
+func evalTry(t ast.Try, env *Interpreter) (types.Primitive, error) {
+	res, err := evalBlock(t.Body, env)
+	if t.Catch != nil && isCatchable(err) {
+		res, err = evalCatch(t, err, env)
+	}
+	if t.Finally != nil {
+		if fres, ferr := evalBlock(t.Finally, env); ferr != nil {
+			return fres, ferr
 		}
 	}
 	return res, err
 }
 
+func evalCatch(t ast.Try, err error, env *Interpreter) (types.Primitive, error) {
+	env.enterScope()
+	defer env.leaveScope()
+	if t.Ident != "" {
+		if err := env.Define(t.Ident, errorValue(err)); err != nil {
+			return nil, err
+		}
+	}
+	return eval(t.Catch, env)
+}
+
+func evalRaise(r ast.Raise, env *Interpreter) (types.Primitive, error) {
+	res, err := eval(r.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	return nil, raiseError{value: res}
+}
+
+func evalBlock(expr ast.Expression, env *Interpreter) (types.Primitive, error) {
+	env.enterScope()
+	defer env.leaveScope()
+	return eval(expr, env)
+}
+
+func execute(expr ast.Expression, env *Interpreter) (types.Primitive, error) {
+	res, err := eval(expr, env)
+	if errors.Is(err, errReturn) {
+		err = nil
+	}
+	if res == nil && err == nil {
+		res = types.CreateNil()
+	}
+	return res, err
+}
+
 func assignIndex(i ast.Index, value types.Primitive, env *Interpreter) error {
 	var (
 		res types.Primitive
@@ -544,6 +715,8 @@ func assignIndex(i ast.Index, value types.Primitive, env *Interpreter) error {
 	switch a := i.Arr.(type) {
 	case ast.Variable:
 		res, err = env.Resolve(a.Ident)
+	case ast.Index:
+		res, err = eval(a, env)
 	case ast.Array:
 		res, err = eval(a, env)
 	case ast.Dict:
@@ -554,38 +727,114 @@ func assignIndex(i ast.Index, value types.Primitive, env *Interpreter) error {
 	if err != nil {
 		return err
 	}
-	c, ok := res.(types.Container)
-	if !ok {
-		return types.ContainerError(res)
+	list := make([]accessor, len(i.List))
+	for j, e := range i.List {
+		if list[j], err = evalAccessor(e, env); err != nil {
+			return err
+		}
+	}
+	if res, err = assignAccessor(res, list, value); err != nil {
+		return err
 	}
-	for _, e := range slices.Slice(i.List) {
-		x, err := eval(e, env)
+	switch a := i.Arr.(type) {
+	case ast.Variable:
+		err = env.Assign(a.Ident, res)
+	case ast.Index:
+		err = assignIndex(a, res, env)
+	}
+	return err
+}
+
+func assignAccessor(curr types.Primitive, list []accessor, value types.Primitive) (types.Primitive, error) {
+	acc := slices.Fst(list)
+	if len(list) > 1 {
+		sub, err := acc.Get(curr)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if res, err = c.Get(x); err != nil {
-			return err
+		if value, err = assignAccessor(sub, slices.Rest(list), value); err != nil {
+			return nil, err
 		}
-		c, ok = res.(types.Container)
+	}
+	return acc.Set(curr, value)
+}
+
+type accessor struct {
+	index types.Primitive
+	slice bool
+	start types.Primitive
+	end   types.Primitive
+	step  types.Primitive
+}
+
+func evalAccessor(expr ast.Expression, env *Interpreter) (accessor, error) {
+	var (
+		acc accessor
+		err error
+	)
+	s, ok := expr.(ast.Slice)
+	if !ok {
+		acc.index, err = eval(expr, env)
+		return acc, err
+	}
+	acc.slice = true
+	if s.Start != nil {
+		if acc.start, err = eval(s.Start, env); err != nil {
+			return acc, err
+		}
+	}
+	if s.End != nil {
+		if acc.end, err = eval(s.End, env); err != nil {
+			return acc, err
+		}
+	}
+	if s.Step != nil {
+		acc.step, err = eval(s.Step, env)
+	}
+	return acc, err
+}
+
+func (a accessor) Get(p types.Primitive) (types.Primitive, error) {
+	if a.slice {
+		s, ok := p.(types.Sliceable)
 		if !ok {
-			return types.ContainerError(res)
+			return nil, types.SliceError(p)
 		}
+		return s.Slice(a.start, a.end, a.step)
 	}
-	if res, err = eval(slices.Lst(i.List), env); err != nil {
-		return err
+	c, ok := p.(types.Container)
+	if !ok {
+		return nil, types.ContainerError(p)
 	}
-	_, err = c.Set(res, value)
-	return err
+	return c.Get(a.index)
+}
+
+func (a accessor) Set(p, value types.Primitive) (types.Primitive, error) {
+	if a.slice {
+		s, ok := p.(types.Sliceable)
+		if !ok {
+			return nil, types.SliceError(p)
+		}
+		return s.SetSlice(a.start, a.end, a.step, value)
+	}
+	c, ok := p.(types.Container)
+	if !ok {
+		return nil, types.ContainerError(p)
+	}
+	return c.Set(a.index, value)
 }
 
-func wrapError(err error, pos token.Position) error {
+func wrapError(err error, tok token.Token) error {
 	switch {
 	case err == nil:
 	case errors.Is(err, errContinue):
 	case errors.Is(err, errBreak):
 	case errors.Is(err, errReturn):
 	default:
-		err = fmt.Errorf("at %s: %w", pos, err)
+		err = positionError{
+			Token: tok,
+			err:   err,
+		}
 	}
 	return err
 }
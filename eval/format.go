@@ -0,0 +1,199 @@
+package eval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/midbel/buddy/types"
+)
+
+type formatSpec struct {
+	fill  rune
+	align rune
+	sign  rune
+	alt   bool
+	zero  bool
+	width int
+	prec  int
+	verb  rune
+}
+
+func formatValue(p types.Primitive, spec string) (string, error) {
+	if spec == "" {
+		return p.String(), nil
+	}
+	fs, err := parseSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	str, err := fs.format(p)
+	if err != nil {
+		return "", fmt.Errorf("%w: format %q can not be applied to %s", types.ErrIncompatible, spec, p)
+	}
+	return fs.pad(str, isNumber(p)), nil
+}
+
+func parseSpec(spec string) (formatSpec, error) {
+	var (
+		fs  = formatSpec{prec: -1}
+		str = []rune(spec)
+		ptr int
+	)
+	isAlign := func(r rune) bool {
+		return r == '<' || r == '>' || r == '^'
+	}
+	if len(str) > 1 && isAlign(str[1]) {
+		fs.fill, fs.align = str[0], str[1]
+		ptr += 2
+	} else if len(str) > 0 && isAlign(str[0]) {
+		fs.align = str[0]
+		ptr++
+	}
+	if ptr < len(str) && (str[ptr] == '+' || str[ptr] == '-' || str[ptr] == ' ') {
+		fs.sign = str[ptr]
+		ptr++
+	}
+	if ptr < len(str) && str[ptr] == '#' {
+		fs.alt = true
+		ptr++
+	}
+	if ptr < len(str) && str[ptr] == '0' {
+		fs.zero = true
+		ptr++
+	}
+	number := func() int {
+		var n int
+		for ; ptr < len(str) && str[ptr] >= '0' && str[ptr] <= '9'; ptr++ {
+			n = n*10 + int(str[ptr]-'0')
+		}
+		return n
+	}
+	fs.width = number()
+	if ptr < len(str) && str[ptr] == '.' {
+		ptr++
+		if ptr >= len(str) || str[ptr] < '0' || str[ptr] > '9' {
+			return fs, fmt.Errorf("%s: precision expected in format", spec)
+		}
+		fs.prec = number()
+	}
+	if ptr < len(str) {
+		fs.verb = str[ptr]
+		ptr++
+	}
+	if ptr < len(str) {
+		return fs, fmt.Errorf("%s: invalid format", spec)
+	}
+	switch fs.verb {
+	case 0, 's', 'd', 'x', 'X', 'o', 'b', 'f', 'e', 'E', 'g', 'G', '%':
+	default:
+		return fs, fmt.Errorf("%s: unknown format type %c", spec, fs.verb)
+	}
+	return fs, nil
+}
+
+func (f formatSpec) format(p types.Primitive) (string, error) {
+	verb := f.verb
+	if verb == 0 {
+		switch p.Raw().(type) {
+		case int64:
+			verb = 'd'
+		case float64:
+			verb = 'g'
+			if f.prec >= 0 {
+				verb = 'f'
+			}
+		default:
+			verb = 's'
+		}
+	}
+	var (
+		flags strings.Builder
+		value any
+	)
+	flags.WriteRune('%')
+	if f.sign == '+' || f.sign == ' ' {
+		flags.WriteRune(f.sign)
+	}
+	if f.alt {
+		flags.WriteRune('#')
+	}
+	if f.zero && f.align == 0 && verb != 's' {
+		flags.WriteRune('0')
+		flags.WriteString(strconv.Itoa(f.width))
+	}
+	switch verb {
+	case 's':
+		str := p.String()
+		if f.prec >= 0 && utf8.RuneCountInString(str) > f.prec {
+			str = string([]rune(str)[:f.prec])
+		}
+		return str, nil
+	case 'd', 'x', 'X', 'o', 'b':
+		n, ok := p.Raw().(int64)
+		if !ok {
+			return "", types.ErrIncompatible
+		}
+		value = n
+	case 'f', 'e', 'E', 'g', 'G', '%':
+		switch n := p.Raw().(type) {
+		case int64:
+			value = float64(n)
+		case float64:
+			value = n
+		default:
+			return "", types.ErrIncompatible
+		}
+		if f.prec >= 0 {
+			flags.WriteRune('.')
+			flags.WriteString(strconv.Itoa(f.prec))
+		}
+	}
+	if verb == '%' {
+		return fmt.Sprintf(flags.String()+"f%%", value.(float64)*100), nil
+	}
+	return fmt.Sprintf(flags.String()+string(verb), value), nil
+}
+
+func (f formatSpec) pad(str string, number bool) string {
+	size := utf8.RuneCountInString(str)
+	if f.width <= size {
+		return str
+	}
+	var (
+		fill  = f.fill
+		align = f.align
+		diff  = f.width - size
+	)
+	if fill == 0 {
+		fill = ' '
+		if f.zero {
+			fill = '0'
+		}
+	}
+	if align == 0 {
+		align = '<'
+		if number || f.zero {
+			align = '>'
+		}
+	}
+	switch align {
+	case '>':
+		return strings.Repeat(string(fill), diff) + str
+	case '^':
+		left := diff / 2
+		return strings.Repeat(string(fill), left) + str + strings.Repeat(string(fill), diff-left)
+	default:
+		return str + strings.Repeat(string(fill), diff)
+	}
+}
+
+func isNumber(p types.Primitive) bool {
+	switch p.Raw().(type) {
+	case int64, float64:
+		return true
+	default:
+		return false
+	}
+}
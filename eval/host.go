@@ -0,0 +1,328 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/midbel/buddy/builtins"
+	"github.com/midbel/buddy/types"
+)
+
+const typeAny = "any"
+
+var (
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	contextType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typesCtxType  = reflect.TypeOf((*types.Context)(nil)).Elem()
+	primitiveType = reflect.TypeOf((*types.Primitive)(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+type Param struct {
+	Name    string
+	Type    string
+	Default types.Primitive
+}
+
+func (p Param) accept(value types.Primitive) error {
+	if p.Type == "" || p.Type == typeAny {
+		return nil
+	}
+	name, _ := types.Type(value)
+	if name == p.Type || (p.Type == "float" && name == "integer") {
+		return nil
+	}
+	return fmt.Errorf("%w: %s: expected %s, got %s", builtins.ErrType, p.Name, p.Type, name)
+}
+
+type Func struct {
+	Name     string
+	Params   []Param
+	Variadic bool
+	Run      func(types.Context, []types.Primitive) (types.Primitive, error)
+}
+
+func MakeFunc(name string, fn any, names ...string) (Func, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return Func{}, fmt.Errorf("%s: function expected, got %T", name, fn)
+	}
+	var (
+		typ    = v.Type()
+		offset int
+		ctx    reflect.Type
+	)
+	if typ.NumIn() > 0 && (typ.In(0) == contextType || typ.In(0) == typesCtxType) {
+		ctx = typ.In(0)
+		offset++
+	}
+	switch n := typ.NumOut(); {
+	case n > 2:
+		return Func{}, fmt.Errorf("%s: too many results returned", name)
+	case n == 2 && typ.Out(1) != errorType:
+		return Func{}, fmt.Errorf("%s: last result should be an error", name)
+	}
+	in := make([]reflect.Type, 0, typ.NumIn()-offset)
+	for j := offset; j < typ.NumIn(); j++ {
+		in = append(in, typ.In(j))
+	}
+	f := Func{
+		Name:     name,
+		Variadic: typ.IsVariadic(),
+	}
+	for j, t := range in {
+		if f.Variadic && j == len(in)-1 {
+			t = t.Elem()
+			in[j] = t
+		}
+		p := Param{
+			Name: fmt.Sprintf("arg%d", j),
+			Type: typeOf(t),
+		}
+		if j < len(names) {
+			p.Name = names[j]
+		}
+		f.Params = append(f.Params, p)
+	}
+	f.Run = func(c types.Context, args []types.Primitive) (types.Primitive, error) {
+		values := make([]reflect.Value, 0, len(args)+offset)
+		if ctx == contextType {
+			values = append(values, reflect.ValueOf(hostContext(c)))
+		} else if ctx != nil {
+			values = append(values, reflect.ValueOf(&c).Elem())
+		}
+		for j, a := range args {
+			var (
+				k = j
+				t reflect.Type
+			)
+			if k >= len(in) {
+				k = len(in) - 1
+			}
+			t = in[k]
+			val := reflect.New(t).Elem()
+			if err := types.DecodeValue(a, val); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Params[k].Name, err)
+			}
+			values = append(values, val)
+		}
+		return hostResults(v.Call(values))
+	}
+	return f, nil
+}
+
+func (f Func) Arity() int {
+	return len(f.Params)
+}
+
+func (f Func) Call(ctx types.Context, args []types.Argument) (types.Primitive, error) {
+	if f.Run == nil {
+		return nil, fmt.Errorf("%s can not be called", f.Name)
+	}
+	values, err := f.bind(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	res, err := f.Run(ctx, values)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", f.Name, err)
+	}
+	if res == nil && err == nil {
+		res = types.CreateNil()
+	}
+	return res, err
+}
+
+func (f Func) bind(args []types.Argument) ([]types.Primitive, error) {
+	var (
+		params = f.Params
+		vararg Param
+		rest   []types.Primitive
+		ptr    int
+	)
+	if f.Variadic && len(params) > 0 {
+		vararg = params[len(params)-1]
+		params = params[:len(params)-1]
+	}
+	values := make([]types.Primitive, len(params))
+	for ; ptr < len(args) && args[ptr].Name == ""; ptr++ {
+		if ptr < len(params) {
+			values[ptr] = args[ptr].Value
+			continue
+		}
+		if !f.Variadic {
+			return nil, fmt.Errorf("too many arguments given")
+		}
+		if err := vararg.accept(args[ptr].Value); err != nil {
+			return nil, err
+		}
+		rest = append(rest, args[ptr].Value)
+	}
+	for ; ptr < len(args); ptr++ {
+		x := f.findParameter(params, args[ptr].Name)
+		if x < 0 {
+			return nil, fmt.Errorf("%s: parameter not found", args[ptr].Name)
+		}
+		if values[x] != nil {
+			return nil, fmt.Errorf("%s: argument already given", args[ptr].Name)
+		}
+		values[x] = args[ptr].Value
+	}
+	for j, p := range params {
+		if values[j] == nil {
+			if p.Default == nil {
+				return nil, fmt.Errorf("%s: missing argument", p.Name)
+			}
+			values[j] = p.Default
+		}
+		if err := p.accept(values[j]); err != nil {
+			return nil, err
+		}
+	}
+	return append(values, rest...), nil
+}
+
+func (f Func) findParameter(params []Param, name string) int {
+	for j := range params {
+		if params[j].Name == name {
+			return j
+		}
+	}
+	return -1
+}
+
+type Module struct {
+	Name  string
+	Funcs map[string]Func
+}
+
+func (m Module) Id() string {
+	return m.Name
+}
+
+func (m Module) Lookup(mod, name string) (types.Callable, error) {
+	if mod != "" {
+		return nil, fmt.Errorf("%s: no sub module defined", name)
+	}
+	f, ok := m.Funcs[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: function not defined", name)
+	}
+	return f, nil
+}
+
+func (i *Interpreter) Register(name string, fn any, names ...string) error {
+	f, err := MakeFunc(name, fn, names...)
+	if err != nil {
+		return err
+	}
+	return i.RegisterFunc(f)
+}
+
+func (i *Interpreter) RegisterFunc(fn Func) error {
+	if fn.Name == "" {
+		return fmt.Errorf("function without name can not be registered")
+	}
+	if _, ok := i.funcs[fn.Name]; ok {
+		return fmt.Errorf("%s: function already registered", fn.Name)
+	}
+	if i.funcs == nil {
+		i.funcs = make(map[string]types.Callable)
+	}
+	i.funcs[fn.Name] = fn
+	return nil
+}
+
+func (i *Interpreter) RegisterModule(mod types.Module) error {
+	if _, err := i.hostModule(mod.Id()); err == nil {
+		return fmt.Errorf("%s: module already registered", mod.Id())
+	}
+	i.modules = append(i.modules, mod)
+	return nil
+}
+
+func (i *Interpreter) DefineValue(name string, value any) error {
+	p, err := types.ValueOf(value)
+	if err != nil {
+		return err
+	}
+	return i.Define(name, p)
+}
+
+func (i *Interpreter) hostModule(name string) (types.Module, error) {
+	for _, m := range i.modules {
+		if m.Id() == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: undefined module", name)
+}
+
+func (i *Interpreter) lookupBuiltin(ident string) (types.Callable, error) {
+	if fn, ok := i.funcs[ident]; ok {
+		return fn, nil
+	}
+	return builtins.LookupBuiltin(ident)
+}
+
+func hostContext(ctx types.Context) context.Context {
+	if i, ok := ctx.(*Interpreter); ok && i.Context != nil {
+		return i.Context
+	}
+	return context.Background()
+}
+
+func hostResults(out []reflect.Value) (types.Primitive, error) {
+	if len(out) == 0 {
+		return nil, nil
+	}
+	if last := out[len(out)-1]; last.Type() == errorType {
+		if !last.IsNil() {
+			return nil, last.Interface().(error)
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return types.ValueOf(out[0].Interface())
+}
+
+func typeOf(t reflect.Type) string {
+	if t == timeType {
+		return "time"
+	}
+	if t.Implements(primitiveType) {
+		return typeAny
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return "array"
+	case reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "dict"
+	default:
+		return typeAny
+	}
+}
+
+func moduleName(ident []string) string {
+	return strings.Join(ident, ".")
+}
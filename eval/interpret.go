@@ -1,15 +1,22 @@
 package eval
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/midbel/buddy/ast"
 	"github.com/midbel/buddy/builtins"
 	"github.com/midbel/buddy/parse"
+	"github.com/midbel/buddy/token"
 	"github.com/midbel/buddy/types"
 	"github.com/midbel/slices"
 )
@@ -18,17 +25,55 @@ const LimitDepth = 1024
 
 type CallFunc func(call types.Callable) (types.Primitive, error)
 
+type Engine int
+
+const (
+	TreeWalker Engine = iota
+	Bytecode
+)
+
+func (e Engine) String() string {
+	switch e {
+	case TreeWalker:
+		return "tree"
+	case Bytecode:
+		return "bytecode"
+	default:
+		return "unknown"
+	}
+}
+
 type Interpreter struct {
 	Stdout io.Writer
 	Stderr io.Writer
 	Stdin  io.Reader
 
+	Args           []string
+	DisableExec    bool
+	DisableEnviron bool
+
+	Engine     Engine
 	ImportPats []string
+	Roots      []string
 	MaxDepth   int
-	currDepth  int
+	MaxSteps   int
+	// MaxMemory bounds the total size of every string, array and dict
+	// built during a run. The count is cumulative: memory released by
+	// the garbage collector is never given back.
+	MaxMemory int
+	Context   context.Context
+	Hook      Hook
+	currDepth int
+	running   bool
+	limits
 
 	stack   *slices.Stack[types.Module]
+	frames  []Frame
+	exits   []types.Primitive
 	modules []types.Module
+	funcs   map[string]types.Callable
+	sources map[string]string
+	input   *bufio.Reader
 	*types.Environ
 }
 
@@ -50,19 +95,159 @@ func New(env *types.Environ) *Interpreter {
 	return &i
 }
 
+func (i *Interpreter) Output() io.Writer {
+	if i.Stdout == nil {
+		return io.Discard
+	}
+	return i.Stdout
+}
+
+func (i *Interpreter) ErrOutput() io.Writer {
+	if i.Stderr == nil {
+		return io.Discard
+	}
+	return i.Stderr
+}
+
+func (i *Interpreter) Arguments() []string {
+	return i.Args
+}
+
+func (i *Interpreter) ExecAllowed() bool {
+	return !i.DisableExec
+}
+
+func (i *Interpreter) EnvironAllowed() bool {
+	return !i.DisableEnviron
+}
+
+func (i *Interpreter) AtExit(fn types.Primitive) error {
+	if _, ok := fn.(types.Callable); !ok {
+		return types.CallableError(fn)
+	}
+	i.exits = append(i.exits, fn)
+	return nil
+}
+
+func (i *Interpreter) Exit() error {
+	var err error
+	for n := len(i.exits); n > 0; n = len(i.exits) {
+		fn := i.exits[n-1]
+		i.exits = i.exits[:n-1]
+		if _, e := i.Apply(fn, nil); e != nil && err == nil {
+			err = i.runtimeError(e)
+		}
+	}
+	return err
+}
+
+func (i *Interpreter) Input() *bufio.Reader {
+	if r, ok := i.Stdin.(*bufio.Reader); ok {
+		return r
+	}
+	if i.input == nil {
+		r := i.Stdin
+		if r == nil {
+			r = strings.NewReader("")
+		}
+		i.input = bufio.NewReader(r)
+	}
+	return i.input
+}
+
 func (i *Interpreter) Eval(r io.Reader) (types.Primitive, error) {
-	expr, err := parse.New(r).Parse()
+	file, expr, err := i.parse(r)
 	if err != nil {
 		return nil, err
 	}
-	return eval(expr, i)
+	if mod, ok := i.stack.Top().(*userModule); ok {
+		mod.file = file
+	}
+	return i.Exec(expr)
 }
 
 func (i *Interpreter) EvalString(str string) (types.Primitive, error) {
 	return i.Eval(strings.NewReader(str))
 }
 
+func (i *Interpreter) Exec(expr ast.Expression) (types.Primitive, error) {
+	if !i.running {
+		i.running = true
+		defer func() {
+			i.running = false
+		}()
+		i.reset()
+		res, err := i.exec(expr)
+		return res, i.runtimeError(err)
+	}
+	return i.exec(expr)
+}
+
+func (i *Interpreter) exec(expr ast.Expression) (types.Primitive, error) {
+	if err := i.register(expr); err != nil {
+		return nil, err
+	}
+	if i.Engine == TreeWalker {
+		if i.Hook != nil {
+			i.pushFrame("", token.Position{})
+			defer i.popFrame()
+		}
+		return execute(expr, i)
+	}
+	code, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return i.runCode(code)
+}
+
+func (i *Interpreter) register(expr ast.Expression) error {
+	s, ok := expr.(ast.Script)
+	if !ok {
+		return nil
+	}
+	mod, ok := i.stack.Top().(*userModule)
+	if !ok {
+		return fmt.Errorf("%s: fail to register functions", i.stack.Top().Id())
+	}
+	for ident, expr := range s.Symbols {
+		call, err := i.callable(expr)
+		if err != nil {
+			return err
+		}
+		if err := mod.Append(ident, call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Interpreter) callable(expr ast.Expression) (types.Callable, error) {
+	if i.Engine == TreeWalker {
+		return callableFromExpression(expr)
+	}
+	fun, ok := expr.(ast.Function)
+	if !ok {
+		return nil, fmt.Errorf("expression is not a function definition")
+	}
+	code, err := compileCode(fun, nil)
+	if err != nil {
+		return nil, err
+	}
+	call := vmCallable{
+		code: code,
+	}
+	return call, nil
+}
+
 func (i *Interpreter) Load(ident []string, alias string) error {
+	if mod, err := i.hostModule(moduleName(ident)); err == nil {
+		reg, ok := i.stack.Top().(mutableModule)
+		if !ok {
+			return fmt.Errorf("host module can not be imported")
+		}
+		return reg.Register(alias, mod)
+	}
 	if mod, err := builtins.LookupModule(slices.Lst(ident)); err == nil {
 		tmp := i.stack.Top()
 		if reg, ok := tmp.(mutableModule); !ok {
@@ -72,35 +257,31 @@ func (i *Interpreter) Load(ident []string, alias string) error {
 		}
 		return nil
 	}
-	r, err := os.Open(filepath.Join(ident...) + ".bud")
+	r, err := i.openModule(ident)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
 
-	expr, err := parse.New(r).Parse()
+	file, expr, err := i.parse(r)
 	if err != nil {
 		return err
 	}
 
+	if _, ok := expr.(ast.Script); !ok {
+		return fmt.Errorf("fail to load module from %s", strings.Join(ident, "."))
+	}
+
 	mod := emptyModule(slices.Lst(ident))
+	mod.file = file
 	i.stack.Push(mod)
-
-	if _, err := eval(expr, i); err != nil {
-		return err
+	_, err = i.Exec(expr)
+	if err != nil {
+		err = i.callError(token.KwImport, err)
 	}
 	i.stack.Pop()
-
-	s, ok := expr.(ast.Script)
-	if !ok {
-		return fmt.Errorf("fail to load module from %s", strings.Join(ident, "."))
-	}
-	for ident, expr := range s.Symbols {
-		call, err := callableFromExpression(expr)
-		if err != nil {
-			return err
-		}
-		mod.Append(ident, call)
+	if err != nil {
+		return err
 	}
 
 	tmp := i.stack.Top()
@@ -112,6 +293,71 @@ func (i *Interpreter) Load(ident []string, alias string) error {
 	return nil
 }
 
+func (i *Interpreter) openModule(ident []string) (*os.File, error) {
+	file := filepath.Join(ident...) + ".bud"
+	if len(i.ImportPats) == 0 {
+		return os.Open(file)
+	}
+	for _, dir := range i.ImportPats {
+		r, err := os.Open(filepath.Join(dir, file))
+		if err == nil {
+			return r, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%s: module not found in %s", strings.Join(ident, "."), strings.Join(i.ImportPats, ", "))
+}
+
+func (i *Interpreter) parse(r io.Reader) (string, ast.Expression, error) {
+	var file string
+	if n, ok := r.(interface{ Name() string }); ok {
+		file = n.Name()
+	}
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return file, nil, err
+	}
+	if i.sources == nil {
+		i.sources = make(map[string]string)
+	}
+	i.sources[file] = string(buf)
+
+	expr, err := parse.New(namedReader{
+		Reader: bytes.NewReader(buf),
+		name:   file,
+	}).Parse()
+	return file, expr, err
+}
+
+func (i *Interpreter) sourceLine(file string, line int) string {
+	src, ok := i.sources[file]
+	if !ok || line <= 0 {
+		return ""
+	}
+	for ; line > 1; line-- {
+		x := strings.IndexByte(src, '\n')
+		if x < 0 {
+			return ""
+		}
+		src = src[x+1:]
+	}
+	if x := strings.IndexByte(src, '\n'); x >= 0 {
+		src = src[:x]
+	}
+	return strings.TrimSuffix(src, "\r")
+}
+
+type namedReader struct {
+	*bytes.Reader
+	name string
+}
+
+func (r namedReader) Name() string {
+	return r.name
+}
+
 func (i *Interpreter) Call(mod, ident string, call CallFunc) (types.Primitive, error) {
 	if err := i.enter(); err != nil {
 		return nil, err
@@ -136,13 +382,25 @@ func (i *Interpreter) Call(mod, ident string, call CallFunc) (types.Primitive, e
 	if err == nil {
 		return call(fn)
 	}
-	fn, err = builtins.LookupBuiltin(ident)
+	fn, err = i.lookupBuiltin(ident)
 	if err != nil {
 		return nil, err
 	}
 	return call(fn)
 }
 
+func (i *Interpreter) Apply(fn types.Primitive, args []types.Argument) (types.Primitive, error) {
+	call, ok := fn.(types.Callable)
+	if !ok {
+		return nil, types.CallableError(fn)
+	}
+	if err := i.enter(); err != nil {
+		return nil, err
+	}
+	defer i.leave()
+	return call.Call(i, args)
+}
+
 func (i *Interpreter) Lookup(mod, ident string) (types.Callable, error) {
 	if mod == "" {
 		return i.stack.Top().Lookup("", ident)
@@ -154,6 +412,69 @@ func (i *Interpreter) Lookup(mod, ident string) (types.Callable, error) {
 	return m.Lookup("", ident)
 }
 
+func (i *Interpreter) member(mod, ident string) (types.Primitive, error) {
+	m, err := i.lookupModule(mod)
+	if err != nil {
+		return nil, err
+	}
+	call, err := m.Lookup("", ident)
+	if err == nil {
+		return types.CreateFunction(ident, call), nil
+	}
+	if v, ok := m.(valueModule); ok {
+		return v.Value(i, ident)
+	}
+	return nil, err
+}
+
+func (i *Interpreter) Functions() []string {
+	var list []string
+	if mod, ok := i.stack.Top().(*userModule); ok {
+		for n := range mod.callables {
+			list = append(list, n)
+		}
+	}
+	for n := range i.funcs {
+		list = append(list, n)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (i *Interpreter) Imports() []string {
+	var list []string
+	if mod, ok := i.stack.Top().(*userModule); ok {
+		for n := range mod.modules {
+			list = append(list, n)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (i *Interpreter) Members(alias string) []string {
+	mod, err := i.lookupModule(alias)
+	if err != nil {
+		return nil
+	}
+	var list []string
+	switch m := mod.(type) {
+	case *userModule:
+		for n := range m.callables {
+			list = append(list, n)
+		}
+		list = append(list, m.Environ.Names()...)
+	case builtins.Module:
+		list = m.Names()
+	case Module:
+		for n := range m.Funcs {
+			list = append(list, n)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
 func (i *Interpreter) lookupModule(ident string) (types.Module, error) {
 	var (
 		curr    = i.stack.Top()
@@ -177,6 +498,9 @@ func (i *Interpreter) enter() error {
 	if i.currDepth >= i.MaxDepth {
 		return fmt.Errorf("max call stacked reached!")
 	}
+	if err := i.Interrupted(); err != nil {
+		return err
+	}
 	i.currDepth++
 	return nil
 }
@@ -0,0 +1,99 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/midbel/buddy/token"
+	"github.com/midbel/buddy/types"
+)
+
+var (
+	ErrStepLimit   = errors.New("step limit exceeded")
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+	ErrInterrupted = errors.New("execution interrupted")
+)
+
+type interruptError struct {
+	err error
+}
+
+func (e interruptError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInterrupted, e.err)
+}
+
+func (e interruptError) Is(err error) bool {
+	return err == ErrInterrupted
+}
+
+func (e interruptError) Unwrap() error {
+	return e.err
+}
+
+type limits struct {
+	steps  int
+	memory int
+	done   <-chan struct{}
+}
+
+func (i *Interpreter) reset() {
+	i.limits = limits{}
+	if i.Context != nil {
+		i.limits.done = i.Context.Done()
+	}
+}
+
+func (i *Interpreter) step() error {
+	i.limits.steps++
+	if i.MaxSteps > 0 && i.limits.steps > i.MaxSteps {
+		return ErrStepLimit
+	}
+	return nil
+}
+
+func (i *Interpreter) Done() <-chan struct{} {
+	return i.limits.done
+}
+
+func (i *Interpreter) Interrupted() error {
+	if i.limits.done == nil {
+		return nil
+	}
+	select {
+	case <-i.limits.done:
+		err := i.Context.Err()
+		if err == nil {
+			err = context.Canceled
+		}
+		return interruptError{err: err}
+	default:
+		return nil
+	}
+}
+
+func (i *Interpreter) Reserve(size int) error {
+	if i.MaxMemory <= 0 {
+		return nil
+	}
+	i.limits.memory += size
+	if i.limits.memory > i.MaxMemory {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+func (i *Interpreter) allocate(res types.Primitive, err error) (types.Primitive, error) {
+	if err != nil || i.MaxMemory <= 0 {
+		return res, err
+	}
+	return res, i.Reserve(types.SizeOf(res))
+}
+
+func (i *Interpreter) binary(op rune, left, right types.Primitive) (types.Primitive, error) {
+	res, err := executeBinary(op, left, right)
+	if op != token.Add && op != token.Mul {
+		return res, err
+	}
+	return i.allocate(res, err)
+}
@@ -0,0 +1,124 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Src   string
+		Setup func(*Interpreter) context.CancelFunc
+		Err   error
+	}{
+		{
+			Name: "steps",
+			Src: `let n = 0
+try {
+	while true {
+		n += 1
+	}
+} catch(e) {
+	n = -1
+}
+n`,
+			Setup: func(i *Interpreter) context.CancelFunc {
+				i.MaxSteps = 1000
+				return func() {}
+			},
+			Err: ErrStepLimit,
+		},
+		{
+			Name: "timeout",
+			Src: `let n = 0
+try {
+	while true {
+		n += 1
+	}
+} catch(e) {
+	n = -1
+} finally {
+	n = -2
+}
+n`,
+			Setup: func(i *Interpreter) context.CancelFunc {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				i.Context = ctx
+				return cancel
+			},
+			Err: ErrInterrupted,
+		},
+		{
+			Name: "memory",
+			Src: `let s = "x"
+try {
+	while true {
+		s = s + "x"
+	}
+} catch(e) {
+	s = ""
+}
+s`,
+			Setup: func(i *Interpreter) context.CancelFunc {
+				i.MaxMemory = 1 << 16
+				return func() {}
+			},
+			Err: ErrMemoryLimit,
+		},
+		{
+			Name: "memory-builtin",
+			Src: `import strings
+let s = nil
+try {
+	s = strings.repeat("x", 1 << 20)
+} catch(e) {
+	s = ""
+}
+s`,
+			Setup: func(i *Interpreter) context.CancelFunc {
+				i.MaxMemory = 1 << 16
+				return func() {}
+			},
+			Err: ErrMemoryLimit,
+		},
+		{
+			Name: "memory-cumulative",
+			Src: `import strings
+let list = []
+try {
+	while len(list) < 64 {
+		list = list + [strings.upper(strings.repeat("x", 1 << 10))]
+	}
+} catch(e) {
+	list = []
+}
+len(list)`,
+			Setup: func(i *Interpreter) context.CancelFunc {
+				i.MaxMemory = 1 << 16
+				return func() {}
+			},
+			Err: ErrMemoryLimit,
+		},
+	}
+	for _, tt := range tests {
+		for _, engine := range []Engine{TreeWalker, Bytecode} {
+			t.Run(tt.Name+"/"+engine.String(), func(t *testing.T) {
+				bud := Default()
+				bud.Engine = engine
+				cancel := tt.Setup(bud)
+				defer cancel()
+
+				res, err := bud.EvalString(tt.Src)
+				if err == nil {
+					t.Fatalf("expected %q error, got result %s", tt.Err, res)
+				}
+				if !errors.Is(err, tt.Err) {
+					t.Fatalf("expected %q error, got %q", tt.Err, err)
+				}
+			})
+		}
+	}
+}
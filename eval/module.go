@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/midbel/buddy/ast"
@@ -12,8 +13,13 @@ type mutableModule interface {
 	Register(string, types.Module) error
 }
 
+type valueModule interface {
+	Value(types.Context, string) (types.Primitive, error)
+}
+
 type userModule struct {
 	name      string
+	file      string
 	callables map[string]types.Callable
 	modules   map[string]types.Module
 	*types.Environ
@@ -73,6 +79,8 @@ func (m *userModule) Lookup(mod, ident string) (types.Callable, error) {
 
 type userCallable struct {
 	fun ast.Function
+	env *types.Environ
+	mod types.Module
 }
 
 func callableFromExpression(expr ast.Expression) (types.Callable, error) {
@@ -96,9 +104,10 @@ func (c userCallable) Call(ctx types.Context, args []types.Argument) (types.Prim
 	defer func() {
 		i.Environ = old
 	}()
-	i.Environ = types.EmptyEnv()
-	if err := c.setDefault(i); err != nil {
-		return nil, err
+	i.Environ = types.EnclosedEnv(c.env)
+	if c.mod != nil {
+		i.stack.Push(c.mod)
+		defer i.stack.Pop()
 	}
 	var (
 		ptr int
@@ -129,11 +138,32 @@ func (c userCallable) Call(ctx types.Context, args []types.Argument) (types.Prim
 		set[par.Ident] = struct{}{}
 		i.Define(par.Ident, args[ptr].Value)
 	}
+	if err := c.setDefault(i, set); err != nil {
+		return nil, err
+	}
+	if i.Hook != nil {
+		if err := i.enterFrame(c.name(), c.fun.Position); err != nil {
+			return nil, err
+		}
+	}
 	res, err := eval(c.fun.Body, i)
-	if err != nil {
-		err = fmt.Errorf("%s: %w", c.fun.Ident, err)
+	if errors.Is(err, errReturn) {
+		err = nil
 	}
-	return res, err
+	if res == nil && err == nil {
+		res = types.CreateNil()
+	}
+	if i.Hook != nil {
+		i.leaveFrame(res, err)
+	}
+	return res, i.callError(c.name(), err)
+}
+
+func (c userCallable) name() string {
+	if c.fun.Ident == "" {
+		return "lambda"
+	}
+	return c.fun.Ident
 }
 
 func (c userCallable) Arity() int {
@@ -150,12 +180,15 @@ func (c userCallable) findParameter(ident string) (ast.Parameter, error) {
 	return ast.Parameter{}, fmt.Errorf("%s: parameter not found", ident)
 }
 
-func (c userCallable) setDefault(i *Interpreter) error {
+func (c userCallable) setDefault(i *Interpreter, set map[string]struct{}) error {
 	for _, e := range c.fun.Params {
 		p, ok := e.(ast.Parameter)
 		if !ok || p.Expr == nil {
 			continue
 		}
+		if _, ok := set[p.Ident]; ok {
+			continue
+		}
 		res, err := eval(p.Expr, i)
 		if err != nil {
 			return err
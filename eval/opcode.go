@@ -0,0 +1,241 @@
+package eval
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/midbel/buddy/token"
+	"github.com/midbel/buddy/types"
+)
+
+type opcode byte
+
+const (
+	opNop opcode = iota
+	opConst
+	opNil
+	opPop
+	opLoad
+	opStore
+	opLoadCell
+	opStoreCell
+	opDeclareCell
+	opLoadFree
+	opStoreFree
+	opLoadName
+	opStoreName
+	opDefine
+	opArray
+	opDict
+	opAccess
+	opSetIndex
+	opChain
+	opConcat
+	opFormat
+	opUnary
+	opBinary
+	opJump
+	opJumpFalse
+	opJumpNotNil
+	opJumpNil
+	opJumpBound
+	opIter
+	opNext
+	opCollect
+	opAppend
+	opAppendPair
+	opFreeze
+	opCall
+	opCallName
+	opCallPath
+	opMember
+	opClosure
+	opImport
+	opAssert
+	opRaise
+	opSetupTry
+	opSetupFinally
+	opPopTry
+	opEndFinally
+	opReturn
+)
+
+type opinfo struct {
+	name  string
+	width int
+}
+
+var opcodes = map[opcode]opinfo{
+	opNop:          {"nop", 0},
+	opConst:        {"const", 1},
+	opNil:          {"nil", 0},
+	opPop:          {"pop", 0},
+	opLoad:         {"load", 1},
+	opStore:        {"store", 1},
+	opLoadCell:     {"load-cell", 1},
+	opStoreCell:    {"store-cell", 1},
+	opDeclareCell:  {"declare-cell", 1},
+	opLoadFree:     {"load-free", 1},
+	opStoreFree:    {"store-free", 1},
+	opLoadName:     {"load-name", 1},
+	opStoreName:    {"store-name", 1},
+	opDefine:       {"define", 1},
+	opArray:        {"array", 1},
+	opDict:         {"dict", 1},
+	opAccess:       {"access", 1},
+	opSetIndex:     {"set-index", 1},
+	opChain:        {"chain", 0},
+	opConcat:       {"concat", 1},
+	opFormat:       {"format", 1},
+	opUnary:        {"unary", 1},
+	opBinary:       {"binary", 1},
+	opJump:         {"jump", 1},
+	opJumpFalse:    {"jump-false", 1},
+	opJumpNotNil:   {"jump-not-nil", 1},
+	opJumpNil:      {"jump-nil", 1},
+	opJumpBound:    {"jump-bound", 2},
+	opIter:         {"iter", 0},
+	opNext:         {"next", 1},
+	opCollect:      {"collect", 1},
+	opAppend:       {"append", 1},
+	opAppendPair:   {"append-pair", 1},
+	opFreeze:       {"freeze", 0},
+	opCall:         {"call", 1},
+	opCallName:     {"call-name", 2},
+	opCallPath:     {"call-path", 3},
+	opMember:       {"member", 2},
+	opClosure:      {"closure", 1},
+	opImport:       {"import", 1},
+	opAssert:       {"assert", 0},
+	opRaise:        {"raise", 0},
+	opSetupTry:     {"setup-try", 1},
+	opSetupFinally: {"setup-finally", 1},
+	opPopTry:       {"pop-try", 0},
+	opEndFinally:   {"end-finally", 0},
+	opReturn:       {"return", 0},
+}
+
+func (o opcode) String() string {
+	i, ok := opcodes[o]
+	if !ok {
+		return fmt.Sprintf("op(%d)", o)
+	}
+	return i.name
+}
+
+func (o opcode) Width() int {
+	return opcodes[o].width
+}
+
+const (
+	accSlice = 1 << iota
+	accStart
+	accEnd
+	accStep
+)
+
+type upvalue struct {
+	name  string
+	local bool
+	index int
+}
+
+type callsite struct {
+	names []string
+}
+
+func (c callsite) Arguments(values []types.Primitive) []types.Argument {
+	args := make([]types.Argument, len(values))
+	for i := range values {
+		args[i] = types.NamedArg(c.names[i], i, values[i])
+	}
+	return args
+}
+
+type lineinfo struct {
+	offset int
+	token.Position
+}
+
+type Code struct {
+	Name   string
+	Params []string
+	Ops    []byte
+
+	consts  []types.Primitive
+	names   []string
+	locals  []string
+	stack   int
+	boxed   bool
+	frees   []upvalue
+	funcs   []*Code
+	calls   []callsite
+	imports [][]string
+	access  [][]int
+	lines   []lineinfo
+}
+
+func (c *Code) position(offset int) token.Position {
+	var pos token.Position
+	for _, i := range c.lines {
+		if i.offset > offset {
+			break
+		}
+		pos = i.Position
+	}
+	return pos
+}
+
+func (c *Code) token(offset int) token.Token {
+	return token.Token{
+		Position: c.position(offset),
+	}
+}
+
+func (c *Code) operand(offset int) int {
+	return int(binary.BigEndian.Uint16(c.Ops[offset:]))
+}
+
+func (c *Code) Disassemble(w io.Writer) {
+	c.disassemble(w, 0)
+}
+
+func (c *Code) disassemble(w io.Writer, level int) {
+	var (
+		prefix = strings.Repeat("  ", level)
+		name   = c.Name
+	)
+	if name == "" {
+		name = "lambda"
+	}
+	fmt.Fprintf(w, "%s%s(%s) locals=%d frees=%d\n", prefix, name, strings.Join(c.Params, ", "), len(c.locals), len(c.frees))
+	for ip := 0; ip < len(c.Ops); {
+		var (
+			op   = opcode(c.Ops[ip])
+			args []string
+		)
+		for j := 0; j < op.Width(); j++ {
+			args = append(args, fmt.Sprintf("%d", c.operand(ip+1+j*2)))
+		}
+		fmt.Fprintf(w, "%s%04d %-8s %-14s %s", prefix, ip, c.position(ip), op, strings.Join(args, " "))
+		switch op {
+		case opConst:
+			fmt.Fprintf(w, " (%s)", c.consts[c.operand(ip+1)])
+		case opLoadName, opStoreName, opDefine, opCallName, opFormat:
+			fmt.Fprintf(w, " (%s)", c.names[c.operand(ip+1)])
+		case opLoad, opStore, opLoadCell, opStoreCell, opDeclareCell, opJumpBound:
+			fmt.Fprintf(w, " (%s)", c.locals[c.operand(ip+1)])
+		case opLoadFree, opStoreFree:
+			fmt.Fprintf(w, " (%s)", c.frees[c.operand(ip+1)].name)
+		case opCallPath, opMember:
+			fmt.Fprintf(w, " (%s.%s)", c.names[c.operand(ip+1)], c.names[c.operand(ip+3)])
+		}
+		fmt.Fprintln(w)
+		ip += 1 + op.Width()*2
+	}
+	for _, f := range c.funcs {
+		f.disassemble(w, level+1)
+	}
+}
@@ -0,0 +1,54 @@
+package eval
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (i *Interpreter) ResolveFile(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	if len(i.Roots) == 0 {
+		return abs, nil
+	}
+	real := realPath(abs)
+	for _, r := range i.Roots {
+		root, err := filepath.Abs(r)
+		if err != nil {
+			continue
+		}
+		if isWithin(realPath(root), real) {
+			return abs, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w: outside of allowed directories", file, fs.ErrPermission)
+}
+
+func realPath(file string) string {
+	var rest []string
+	for {
+		dir, err := filepath.EvalSymlinks(file)
+		if err == nil {
+			return filepath.Join(append([]string{dir}, rest...)...)
+		}
+		parent := filepath.Dir(file)
+		if parent == file {
+			return filepath.Join(append([]string{file}, rest...)...)
+		}
+		rest = append([]string{filepath.Base(file)}, rest...)
+		file = parent
+	}
+}
+
+func isWithin(root, file string) bool {
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)))
+}
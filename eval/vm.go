@@ -0,0 +1,662 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/midbel/buddy/types"
+	"github.com/midbel/slices"
+)
+
+type cell struct {
+	value types.Primitive
+}
+
+type handler struct {
+	addr    int
+	sp      int
+	finally bool
+}
+
+type frame struct {
+	code     *Code
+	ip       int
+	stack    []types.Primitive
+	locals   []types.Primitive
+	cells    []*cell
+	frees    []*cell
+	handlers []handler
+}
+
+func createFrame(code *Code, frees []*cell) *frame {
+	var (
+		size = len(code.locals)
+		buf  = make([]types.Primitive, size+code.stack+1)
+	)
+	f := frame{
+		code:   code,
+		locals: buf[:size:size],
+		stack:  buf[size:size],
+		frees:  frees,
+	}
+	if code.boxed {
+		f.cells = make([]*cell, len(code.locals))
+	}
+	return &f
+}
+
+func (f *frame) read() int {
+	x := f.code.operand(f.ip)
+	f.ip += 2
+	return x
+}
+
+func (f *frame) push(p types.Primitive) {
+	f.stack = append(f.stack, p)
+}
+
+func (f *frame) pop() types.Primitive {
+	n := len(f.stack) - 1
+	p := f.stack[n]
+	f.stack = f.stack[:n]
+	return p
+}
+
+func (f *frame) top() types.Primitive {
+	return f.stack[len(f.stack)-1]
+}
+
+func (f *frame) popn(n int) []types.Primitive {
+	var (
+		size = len(f.stack) - n
+		list = make([]types.Primitive, n)
+	)
+	copy(list, f.stack[size:])
+	f.stack = f.stack[:size]
+	return list
+}
+
+func (f *frame) bound(slot int) bool {
+	if f.code.boxed {
+		return f.cells[slot] != nil && f.cells[slot].value != nil
+	}
+	return f.locals[slot] != nil
+}
+
+func (f *frame) bind(slot int, value types.Primitive) {
+	if f.code.boxed {
+		f.cells[slot] = &cell{value: value}
+		return
+	}
+	f.locals[slot] = value
+}
+
+func (f *frame) bindArguments(args []types.Argument) error {
+	var (
+		ptr int
+		set = make([]bool, len(f.code.Params))
+	)
+	for ; ptr < len(args); ptr++ {
+		if args[ptr].Name != "" {
+			break
+		}
+		if ptr >= len(f.code.Params) {
+			return fmt.Errorf("variadic argument not supported")
+		}
+		set[ptr] = true
+		f.bind(ptr, args[ptr].Value)
+	}
+	for ; ptr < len(args); ptr++ {
+		slot := -1
+		for j := range f.code.Params {
+			if f.code.Params[j] == args[ptr].Name {
+				slot = j
+				break
+			}
+		}
+		if slot < 0 {
+			return fmt.Errorf("%s: parameter not found", args[ptr].Name)
+		}
+		if set[slot] {
+			return fmt.Errorf("%s: argument already given", args[ptr].Name)
+		}
+		set[slot] = true
+		f.bind(slot, args[ptr].Value)
+	}
+	return nil
+}
+
+func (f *frame) recover(err error) bool {
+	for len(f.handlers) > 0 {
+		h := slices.Lst(f.handlers)
+		f.handlers = slices.Slice(f.handlers)
+		if !h.finally && !isCatchable(err) {
+			continue
+		}
+		f.stack = f.stack[:h.sp]
+		if h.finally {
+			f.push(pendingError{err: err})
+		} else {
+			f.push(errorValue(err))
+		}
+		f.ip = h.addr
+		return true
+	}
+	return false
+}
+
+func (f *frame) undefined(slot int) error {
+	return fmt.Errorf("%s undefined variable", f.code.locals[slot])
+}
+
+func (i *Interpreter) runCode(code *Code) (types.Primitive, error) {
+	return i.run(createFrame(code, nil))
+}
+
+func (i *Interpreter) run(f *frame) (types.Primitive, error) {
+	for {
+		var (
+			pc  = f.ip
+			op  = opcode(f.code.Ops[pc])
+			err error
+		)
+		f.ip++
+		if err = i.step(); err != nil {
+			return nil, wrapError(err, f.code.token(pc))
+		}
+		switch op {
+		case opNop:
+		case opConst:
+			f.push(f.code.consts[f.read()])
+		case opNil:
+			f.push(types.CreateNil())
+		case opPop:
+			f.pop()
+		case opLoad:
+			slot := f.read()
+			if v := f.locals[slot]; v != nil {
+				f.push(v)
+			} else {
+				err = f.undefined(slot)
+			}
+		case opStore:
+			f.locals[f.read()] = f.top()
+		case opLoadCell:
+			slot := f.read()
+			if c := f.cells[slot]; c != nil && c.value != nil {
+				f.push(c.value)
+			} else {
+				err = f.undefined(slot)
+			}
+		case opStoreCell:
+			slot := f.read()
+			if c := f.cells[slot]; c != nil {
+				c.value = f.top()
+			} else {
+				err = fmt.Errorf("%s: variable not declared", f.code.locals[slot])
+			}
+		case opDeclareCell:
+			slot := f.read()
+			if c := f.cells[slot]; c != nil && c.value == nil {
+				c.value = f.top()
+			} else {
+				f.cells[slot] = &cell{value: f.top()}
+			}
+		case opLoadFree:
+			ix := f.read()
+			if v := f.frees[ix].value; v != nil {
+				f.push(v)
+			} else {
+				err = fmt.Errorf("%s undefined variable", f.code.frees[ix].name)
+			}
+		case opStoreFree:
+			f.frees[f.read()].value = f.top()
+		case opLoadName:
+			var res types.Primitive
+			if res, err = resolveVariable(f.code.names[f.read()], i); err == nil {
+				f.push(res)
+			}
+		case opStoreName:
+			err = i.Assign(f.code.names[f.read()], f.top())
+		case opDefine:
+			err = i.Define(f.code.names[f.read()], f.top())
+		case opArray:
+			var res types.Primitive
+			if res, err = i.allocate(types.CreateArray(f.popn(f.read())), nil); err == nil {
+				f.push(res)
+			}
+		case opDict:
+			var (
+				list = f.popn(f.read() * 2)
+				dict = types.CreateDict()
+			)
+			for j := 0; err == nil && j < len(list); j += 2 {
+				dict, err = dict.(types.Container).Set(list[j], list[j+1])
+			}
+			if dict, err = i.allocate(dict, err); err == nil {
+				f.push(dict)
+			}
+		case opAccess:
+			var (
+				acc = f.accessor(f.read())
+				res types.Primitive
+			)
+			if res, err = acc.Get(f.pop()); err == nil {
+				f.push(res)
+			}
+		case opSetIndex:
+			var (
+				kinds = f.code.access[f.read()]
+				list  = make([]accessor, len(kinds))
+			)
+			for j := len(kinds) - 1; j >= 0; j-- {
+				list[j] = f.accessor(kinds[j])
+			}
+			var (
+				root  = f.pop()
+				value = f.pop()
+			)
+			if root, err = assignAccessor(root, list, value); err == nil {
+				f.push(value)
+				f.push(root)
+			}
+		case opChain:
+			var (
+				key = f.pop()
+				res types.Primitive
+			)
+			if res, err = getChain(f.pop(), key); err == nil {
+				f.push(res)
+			}
+		case opConcat:
+			var str strings.Builder
+			for _, p := range f.popn(f.read()) {
+				str.WriteString(p.String())
+			}
+			var res types.Primitive
+			if res, err = i.allocate(types.CreateString(str.String()), nil); err == nil {
+				f.push(res)
+			}
+		case opFormat:
+			var (
+				spec = f.code.names[f.read()]
+				str  string
+			)
+			if str, err = formatValue(f.pop(), spec); err == nil {
+				f.push(types.CreateString(str))
+			}
+		case opUnary:
+			var (
+				op  = rune(-f.read())
+				res types.Primitive
+			)
+			if res, err = executeUnary(op, f.pop()); err == nil {
+				f.push(res)
+			}
+		case opBinary:
+			var (
+				op    = rune(-f.read())
+				right = f.pop()
+				res   types.Primitive
+			)
+			if res, err = i.binary(op, f.pop(), right); err == nil {
+				f.push(res)
+			}
+		case opJump:
+			addr := f.read()
+			if addr < pc {
+				err = i.Interrupted()
+			}
+			f.ip = addr
+		case opJumpFalse:
+			addr := f.read()
+			if !f.pop().True() {
+				f.ip = addr
+			}
+		case opJumpNotNil:
+			addr := f.read()
+			if !types.IsNil(f.top()) {
+				f.ip = addr
+			} else {
+				f.pop()
+			}
+		case opJumpNil:
+			addr := f.read()
+			if types.IsNil(f.top()) {
+				f.ip = addr
+			}
+		case opJumpBound:
+			var (
+				slot = f.read()
+				addr = f.read()
+			)
+			if f.bound(slot) {
+				f.ip = addr
+			}
+		case opIter:
+			var it types.Primitive
+			if it, err = createIterator(f.pop()); err == nil {
+				f.push(it)
+			}
+		case opNext:
+			var (
+				addr = f.read()
+				it   = f.top().(*iterator)
+				next types.Primitive
+				ok   bool
+			)
+			if next, ok, err = it.Next(); err == nil {
+				if ok {
+					f.push(next)
+				} else {
+					f.ip = addr
+				}
+			}
+		case opCollect:
+			f.push(createCollector(f.read() == 1))
+		case opAppend:
+			c := f.locals[f.read()].(*collector)
+			c.Append(f.pop())
+			err = i.Reserve(types.SizeItem)
+		case opAppendPair:
+			var (
+				c   = f.locals[f.read()].(*collector)
+				val = f.pop()
+			)
+			if err = c.Set(f.pop(), val); err == nil {
+				err = i.Reserve(types.SizeEntry)
+			}
+		case opFreeze:
+			f.push(f.pop().(*collector).Value())
+		case opCall:
+			var (
+				site = f.code.calls[f.read()]
+				args = site.Arguments(f.popn(len(site.names)))
+				res  types.Primitive
+			)
+			if res, err = i.Apply(f.pop(), args); err == nil {
+				f.push(res)
+			}
+		case opCallName:
+			var (
+				name = f.code.names[f.read()]
+				site = f.code.calls[f.read()]
+				args = site.Arguments(f.popn(len(site.names)))
+				res  types.Primitive
+			)
+			if fn, ok := i.Value(name); ok {
+				res, err = i.Apply(fn, args)
+			} else {
+				res, err = i.Call("", name, func(call types.Callable) (types.Primitive, error) {
+					return call.Call(i, args)
+				})
+			}
+			if err == nil {
+				f.push(res)
+			}
+		case opCallPath:
+			var (
+				mod  = f.code.names[f.read()]
+				name = f.code.names[f.read()]
+				site = f.code.calls[f.read()]
+				args = site.Arguments(f.popn(len(site.names)))
+				res  types.Primitive
+			)
+			res, err = i.Call(mod, name, func(call types.Callable) (types.Primitive, error) {
+				return call.Call(i, args)
+			})
+			if err == nil {
+				f.push(res)
+			}
+		case opMember:
+			var (
+				mod  = f.code.names[f.read()]
+				name = f.code.names[f.read()]
+				res  types.Primitive
+			)
+			if res, err = i.member(mod, name); err == nil {
+				f.push(res)
+			}
+		case opClosure:
+			f.push(i.createClosure(f, f.code.funcs[f.read()]))
+		case opImport:
+			list := f.code.imports[f.read()]
+			if err = i.Load(slices.Slice(list), slices.Lst(list)); err == nil {
+				f.push(types.CreateNil())
+			}
+		case opAssert:
+			if !f.top().True() {
+				err = types.ErrAssert
+			}
+		case opRaise:
+			err = raiseError{value: f.pop()}
+		case opSetupTry, opSetupFinally:
+			h := handler{
+				addr:    f.read(),
+				sp:      len(f.stack),
+				finally: op == opSetupFinally,
+			}
+			f.handlers = append(f.handlers, h)
+		case opPopTry:
+			f.handlers = slices.Slice(f.handlers)
+		case opEndFinally:
+			p := f.pop().(pendingError)
+			if f.recover(p.err) {
+				continue
+			}
+			return nil, p.err
+		case opReturn:
+			if len(f.stack) == 0 {
+				return types.CreateNil(), nil
+			}
+			return f.pop(), nil
+		default:
+			err = fmt.Errorf("%s: unknown opcode", op)
+		}
+		if err == nil {
+			continue
+		}
+		err = wrapError(err, f.code.token(pc))
+		if !f.recover(err) {
+			return nil, err
+		}
+	}
+}
+
+func (f *frame) accessor(kind int) accessor {
+	var acc accessor
+	if kind&accSlice == 0 {
+		acc.index = f.pop()
+		return acc
+	}
+	acc.slice = true
+	if kind&accStep != 0 {
+		acc.step = f.pop()
+	}
+	if kind&accEnd != 0 {
+		acc.end = f.pop()
+	}
+	if kind&accStart != 0 {
+		acc.start = f.pop()
+	}
+	return acc
+}
+
+func (i *Interpreter) createClosure(f *frame, code *Code) types.Primitive {
+	call := vmCallable{
+		code:  code,
+		frees: make([]*cell, len(code.frees)),
+		env:   i.Environ,
+		mod:   i.stack.Top(),
+	}
+	for j, up := range code.frees {
+		if !up.local {
+			call.frees[j] = f.frees[up.index]
+			continue
+		}
+		if f.cells[up.index] == nil {
+			f.cells[up.index] = &cell{}
+		}
+		call.frees[j] = f.cells[up.index]
+	}
+	return types.CreateFunction(code.Name, call)
+}
+
+type vmCallable struct {
+	code  *Code
+	frees []*cell
+	env   *types.Environ
+	mod   types.Module
+}
+
+func (c vmCallable) Call(ctx types.Context, args []types.Argument) (types.Primitive, error) {
+	i, ok := ctx.(*Interpreter)
+	if !ok {
+		return nil, fmt.Errorf("%s: bytecode can only be run by an interpreter", c.name())
+	}
+	old := i.Environ
+	defer func() {
+		i.Environ = old
+	}()
+	i.Environ = types.EnclosedEnv(c.env)
+	if c.mod != nil {
+		i.stack.Push(c.mod)
+		defer i.stack.Pop()
+	}
+	f := createFrame(c.code, c.frees)
+	if err := f.bindArguments(args); err != nil {
+		return nil, err
+	}
+	res, err := i.run(f)
+	return res, i.callError(c.name(), err)
+}
+
+func (c vmCallable) name() string {
+	if c.code.Name == "" {
+		return "lambda"
+	}
+	return c.code.Name
+}
+
+func (c vmCallable) Arity() int {
+	return len(c.code.Params)
+}
+
+type pendingError struct {
+	err error
+}
+
+func (_ pendingError) String() string {
+	return "<error>"
+}
+
+func (e pendingError) Raw() any {
+	return e.err
+}
+
+func (_ pendingError) True() bool {
+	return false
+}
+
+func (_ pendingError) Not() (types.Primitive, error) {
+	return nil, types.ErrOperation
+}
+
+type iterator struct {
+	list []types.Primitive
+	ptr  int
+	next types.NextFunc
+}
+
+func createIterator(p types.Primitive) (types.Primitive, error) {
+	if it, ok := p.(types.Iterator); ok {
+		return &iterator{next: it.Next}, nil
+	}
+	iter, ok := p.(types.Iterable)
+	if !ok {
+		return nil, types.IterationError(p)
+	}
+	var it iterator
+	err := iter.Iter(func(p types.Primitive) error {
+		it.list = append(it.list, p)
+		return nil
+	})
+	return &it, err
+}
+
+func (i *iterator) Done() bool {
+	return i.next == nil && i.ptr >= len(i.list)
+}
+
+func (i *iterator) Next() (types.Primitive, bool, error) {
+	if i.next != nil {
+		return i.next()
+	}
+	if i.Done() {
+		return nil, false, nil
+	}
+	p := i.list[i.ptr]
+	i.ptr++
+	return p, true, nil
+}
+
+func (_ *iterator) String() string {
+	return "<iterator>"
+}
+
+func (i *iterator) Raw() any {
+	return i.list
+}
+
+func (i *iterator) True() bool {
+	return !i.Done()
+}
+
+func (_ *iterator) Not() (types.Primitive, error) {
+	return nil, types.ErrOperation
+}
+
+type collector struct {
+	list []types.Primitive
+	dict types.Primitive
+}
+
+func createCollector(dict bool) types.Primitive {
+	var c collector
+	if dict {
+		c.dict = types.CreateDict()
+	}
+	return &c
+}
+
+func (c *collector) Append(p types.Primitive) {
+	c.list = append(c.list, p)
+}
+
+func (c *collector) Set(key, value types.Primitive) error {
+	_, err := c.dict.(types.Container).Set(key, value)
+	return err
+}
+
+func (c *collector) Value() types.Primitive {
+	if c.dict != nil {
+		return c.dict
+	}
+	return types.CreateArray(c.list)
+}
+
+func (_ *collector) String() string {
+	return "<collector>"
+}
+
+func (c *collector) Raw() any {
+	return c.list
+}
+
+func (_ *collector) True() bool {
+	return true
+}
+
+func (_ *collector) Not() (types.Primitive, error) {
+	return nil, types.ErrOperation
+}
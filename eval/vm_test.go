@@ -0,0 +1,268 @@
+package eval
+
+import (
+	"strings"
+	"testing"
+)
+
+var engineTests = []struct {
+	Name string
+	Src  string
+}{
+	{
+		Name: "arithmetic",
+		Src:  "let x = 7\n(x * 3 - 1) / 4 + x % 3 - -2 ** 2",
+	},
+	{
+		Name: "closure-counter",
+		Src: `def counter() {
+	let n = 0
+	return def() {
+		n += 1
+		return n
+	}
+}
+let c = counter()
+c()
+c()
+c()`,
+	},
+	{
+		Name: "closure-loop",
+		Src: `let fns = []
+for i in [1, 2, 3] {
+	let x = i * 10
+	fns = fns + [def() { return x + i }]
+}
+[f() for f in fns]`,
+	},
+	{
+		Name: "closure-argument",
+		Src: `def apply(fn, x) {
+	return fn(x)
+}
+let k = 3
+apply(def(v) { return v * k }, 5)`,
+	},
+	{
+		Name: "recursion",
+		Src: `def fib(n) {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+fib(15)`,
+	},
+	{
+		Name: "default-parameters",
+		Src: `def f(a, b = a * 2, c = b + 1) {
+	return [a, b, c]
+}
+[f(1), f(1, 5), f(1, c=0)]`,
+	},
+	{
+		Name: "try-finally-return",
+		Src: `def run() {
+	let log = []
+	let f = def() {
+		try {
+			return "body"
+		} finally {
+			log = log + ["finally"]
+		}
+		return "after"
+	}
+	let res = f()
+	return [res, log]
+}
+run()`,
+	},
+	{
+		Name: "try-finally-override",
+		Src: `def f() {
+	try {
+		return 1
+	} finally {
+		return 2
+	}
+}
+f()`,
+	},
+	{
+		Name: "try-finally-break",
+		Src: `let log = []
+for i in [1, 2, 3] {
+	try {
+		if i == 2 {
+			break
+		}
+		log = log + [i]
+	} finally {
+		log = log + [f"f{i}"]
+	}
+}
+log`,
+	},
+	{
+		Name: "try-finally-continue",
+		Src: `let log = []
+let i = 0
+while i < 3 {
+	i += 1
+	try {
+		if i == 2 {
+			continue
+		}
+		log = log + [i]
+	} finally {
+		log = log + [-i]
+	}
+}
+log`,
+	},
+	{
+		Name: "try-catch-raise",
+		Src: `let res = nil
+try {
+	raise {"code": 42}
+} catch(e) {
+	res = e["code"]
+} finally {
+	res = res + 1
+}
+res`,
+	},
+	{
+		Name: "try-catch-runtime",
+		Src: `let res = nil
+try {
+	1 / 0
+} catch(e) {
+	res = e["kind"]
+}
+res`,
+	},
+	{
+		Name: "nested-try",
+		Src: `let log = []
+try {
+	try {
+		raise "inner"
+	} finally {
+		log = log + ["inner finally"]
+	}
+} catch(e) {
+	log = log + [e["message"]]
+}
+log`,
+	},
+	{
+		Name: "slices",
+		Src: `let a = [1, 2, 3, 4, 5]
+let s = "abcdef"
+[a[1:3], a[:2], a[3:], a[::2], a[-2:], s[1:4], s[::-1], a[::-1]]`,
+	},
+	{
+		Name: "optional-chaining",
+		Src: `let d = {"a": {"b": 1}}
+let n = nil
+[d?.a?.b, n?.a, n?.a?.b, d?.x?.y]`,
+	},
+	{
+		Name: "nullish",
+		Src: `let n = nil
+let z = 0
+[n ?? 1, z ?? 1, nil ?? nil ?? 3, n?.x ?? "default"]`,
+	},
+	{
+		Name: "f-strings",
+		Src: `let name = "world"
+let n = 3.14159
+let d = {"k": [1, 2]}
+[f"hello {name}", f"{n:.2f}", f"{d["k"][1] * 2}", f"{{literal}}", f"{name:>8}|"]`,
+	},
+	{
+		Name: "comprehensions",
+		Src: `let words = ["a", "bb", "ccc"]
+let d = {w: len(w) for w in words}
+[[len(w) for w in words if len(w) > 1], d["a"], d["ccc"], len(d)]`,
+	},
+	{
+		Name: "string-iteration",
+		Src: `let out = []
+for c in "hé" {
+	out = out + [c]
+}
+[out, [c for c in "hé!" if c != "!"]]`,
+	},
+	{
+		Name: "loops",
+		Src: `let total = 0
+let i = 0
+for i = 0; i < 10; i += 1 {
+	if i % 2 == 0 {
+		continue
+	}
+	if i > 7 {
+		break
+	}
+	total += i
+}
+total`,
+	},
+	{
+		Name: "error-undefined",
+		Src:  "unknown + 1",
+	},
+	{
+		Name: "error-index",
+		Src:  "let a = [1]\na[5]",
+	},
+	{
+		Name: "error-type",
+		Src:  `1 < "a"`,
+	},
+	{
+		Name: "error-raise",
+		Src:  `raise "boom"`,
+	},
+}
+
+func TestEngines(t *testing.T) {
+	for _, tt := range engineTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			want, werr := runEngine(tt.Src, TreeWalker)
+			got, gerr := runEngine(tt.Src, Bytecode)
+			if werr != gerr {
+				t.Fatalf("errors mismatched: tree %q, bytecode %q", werr, gerr)
+			}
+			if want != got {
+				t.Fatalf("results mismatched: tree %s, bytecode %s", want, got)
+			}
+		})
+	}
+}
+
+func TestIterateString(t *testing.T) {
+	src := "let out = []\nfor c in \"hé\" {\n\tout = out + [c]\n}\nout"
+	for _, engine := range []Engine{TreeWalker, Bytecode} {
+		res, err := runEngine(src, engine)
+		if err != "" {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+		if want := "[h é]"; res != want {
+			t.Errorf("%s: want %s, got %s", engine, want, res)
+		}
+	}
+}
+
+func runEngine(src string, engine Engine) (string, string) {
+	bud := Default()
+	bud.Engine = engine
+	res, err := bud.Eval(strings.NewReader(src))
+	if err != nil {
+		return "", err.Error()
+	}
+	return res.String(), ""
+}
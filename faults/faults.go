@@ -6,7 +6,7 @@ import (
 	"io"
 	"strings"
 
-	"github.com/midbel/buddy/parse"
+	"github.com/midbel/buddy/token"
 )
 
 func PrintError(w io.Writer, err error) {
@@ -14,14 +14,17 @@ func PrintError(w io.Writer, err error) {
 		return
 	}
 	var (
-		perr parse.ParseError
+		perr ParseError
+		rerr *RuntimeError
 		errl *ErrorList
 	)
 
-	if errors.As(err, &perr) {
-		printParseError(w, perr)
-	} else if errors.As(err, &errl) {
+	if errors.As(err, &errl) {
 		printErrorList(w, errl)
+	} else if errors.As(err, &perr) {
+		printParseError(w, perr)
+	} else if errors.As(err, &rerr) {
+		printRuntimeError(w, rerr)
 	} else {
 		fmt.Fprintln(w, err)
 	}
@@ -45,30 +48,56 @@ func (es *ErrorList) Append(err error) {
 }
 
 func (es *ErrorList) Error() string {
-	return "too many errors..."
+	switch n := es.Size(); {
+	case n == 0:
+		return "no errors"
+	case n == 1:
+		return (*es)[0].Error()
+	case n >= MaxErrorCount:
+		return "too many errors..."
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", (*es)[0], n-1)
+	}
 }
 
 func printErrorList(w io.Writer, err *ErrorList) {
-	for i := range *err {
-		fmt.Fprintln(w, (*err)[i])
+	for i, e := range *err {
+		if i >= MaxErrorCount {
+			break
+		}
+		PrintError(w, e)
+	}
+	if err.Size() >= MaxErrorCount {
+		fmt.Fprintln(w, "too many errors...")
 	}
-	fmt.Fprintln(w, err)
 }
 
-func printParseError(w io.Writer, err parse.ParseError) {
-	var (
-		space = strings.Repeat(" ", err.Token.Position.Column-1)
-		tilde = "^"
-	)
-	if err.Token.Literal != "" {
-		tilde += strings.Repeat("~", len(err.Token.Literal))
-	}
+type ParseError struct {
+	token.Token
+	File    string
+	Line    string
+	Message string
+}
 
-	line := strings.ReplaceAll(err.Line, "\t", " ")
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s %s: %s", fileName(e.File), e.Position, e.Message)
+}
 
-	fmt.Fprintf(w, "\x1b[1;91m%s: parsing error at %s:\x1b[0m", err.File, err.Position)
+func printParseError(w io.Writer, err ParseError) {
+	fmt.Fprintf(w, "\x1b[1;91m%s: parsing error at %s:\x1b[0m", fileName(err.File), err.Position)
 	fmt.Fprintln(w)
-	fmt.Fprintln(w, line)
-	fmt.Fprintf(w, "%s%s \x1b[1;91m%s\x1b[0m", space, tilde, err.Message)
+	if err.Token.Position.Column > 0 {
+		var (
+			space = strings.Repeat(" ", err.Token.Position.Column-1)
+			tilde = "^"
+		)
+		if err.Token.Literal != "" {
+			tilde += strings.Repeat("~", len(err.Token.Literal))
+		}
+		fmt.Fprintln(w, strings.ReplaceAll(err.Line, "\t", " "))
+		fmt.Fprintf(w, "%s%s \x1b[1;91m%s\x1b[0m", space, tilde, err.Message)
+	} else {
+		fmt.Fprintf(w, "\x1b[1;91m%s\x1b[0m", err.Message)
+	}
 	fmt.Fprintln(w)
 }
@@ -0,0 +1,68 @@
+package faults
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/midbel/buddy/token"
+)
+
+type Frame struct {
+	Func     string
+	Module   string
+	File     string
+	Position token.Position
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%s (%s) %s:%s", f.Func, f.Module, fileName(f.File), f.Position)
+}
+
+type RuntimeError struct {
+	token.Token
+	File    string
+	Line    string
+	Message string
+	Frames  []Frame
+	Err     error
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s %s: %s", fileName(e.File), e.Position, e.Message)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+func printRuntimeError(w io.Writer, err *RuntimeError) {
+	fmt.Fprintf(w, "\x1b[1;91m%s: runtime error at %s:\x1b[0m", fileName(err.File), err.Position)
+	fmt.Fprintln(w)
+	if err.Line != "" && err.Position.Column > 0 {
+		var (
+			space = strings.Repeat(" ", err.Position.Column-1)
+			tilde = "^"
+		)
+		if err.Literal != "" {
+			tilde += strings.Repeat("~", len(err.Literal)-1)
+		}
+		fmt.Fprintln(w, strings.ReplaceAll(err.Line, "\t", " "))
+		fmt.Fprintf(w, "%s%s \x1b[1;91m%s\x1b[0m", space, tilde, err.Message)
+	} else {
+		fmt.Fprintf(w, "\x1b[1;91m%s\x1b[0m", err.Message)
+	}
+	fmt.Fprintln(w)
+	for j := len(err.Frames) - 1; j >= 0; j-- {
+		fmt.Fprintf(w, "  at %s", err.Frames[j])
+		fmt.Fprintln(w)
+	}
+}
+
+func fileName(file string) string {
+	if file == "" {
+		return "<input>"
+	}
+	return filepath.Base(file)
+}
@@ -0,0 +1,856 @@
+package format
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/parse"
+	"github.com/midbel/buddy/scan"
+	"github.com/midbel/buddy/token"
+)
+
+type namedReader struct {
+	*bytes.Reader
+	name string
+}
+
+func (r namedReader) Name() string {
+	return r.name
+}
+
+func Format(r io.Reader) ([]byte, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var file string
+	if n, ok := r.(interface{ Name() string }); ok {
+		file = n.Name()
+	}
+	return Source(file, src)
+}
+
+func Source(file string, src []byte) ([]byte, error) {
+	return SourceIndent(file, src, "\t")
+}
+
+func SourceIndent(file string, src []byte, indent string) ([]byte, error) {
+	expr, err := parse.New(namedReader{bytes.NewReader(src), file}).Parse()
+	if err != nil {
+		return nil, err
+	}
+	p := printer{
+		tab:      indent,
+		closing:  make(map[token.Position]token.Position),
+		literals: make(map[token.Position]string),
+	}
+	p.scan(src)
+	p.printScript(expr.(ast.Script))
+	return p.buf.Bytes(), nil
+}
+
+func Expression(expr ast.Expression) string {
+	var p printer
+	p.printExpr(expr)
+	return p.buf.String()
+}
+
+type printer struct {
+	buf      bytes.Buffer
+	tab      string
+	level    int
+	last     int
+	comments []token.Token
+	closing  map[token.Position]token.Position
+	literals map[token.Position]string
+}
+
+func (p *printer) scan(src []byte) {
+	var (
+		scan  = scan.Scan(bytes.NewReader(src))
+		lines = []int{0}
+		stack []token.Position
+	)
+	for i, b := range src {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	for {
+		tok := scan.Scan()
+		switch tok.Type {
+		case token.EOF:
+			return
+		case token.Comment:
+			p.comments = append(p.comments, tok)
+		case token.Literal:
+			if str := rawLiteral(src, lines, tok.Position); str != "" {
+				p.literals[tok.Position] = str
+			}
+		case token.Lcurly:
+			stack = append(stack, tok.Position)
+		case token.Rcurly:
+			if n := len(stack); n > 0 {
+				p.closing[stack[n-1]] = tok.Position
+				stack = stack[:n-1]
+			}
+		}
+	}
+}
+
+func (p *printer) printScript(s ast.Script) {
+	list := append([]ast.Expression{}, s.List...)
+	for _, e := range s.Symbols {
+		list = append(list, e)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return before(position(list[i]), position(list[j]))
+	})
+	p.printList(list)
+	p.flushComments(math.MaxInt)
+}
+
+func (p *printer) printList(list []ast.Expression) {
+	for i := 0; i < len(list); {
+		if _, ok := list[i].(ast.Import); ok {
+			i = p.printImports(list, i)
+			continue
+		}
+		p.printStmt(list[i])
+		i++
+	}
+}
+
+type importStmt struct {
+	ast.Import
+	comments []token.Token
+	trailing []token.Token
+}
+
+func (p *printer) printImports(list []ast.Expression, i int) int {
+	var (
+		group []importStmt
+		first int
+		last  int
+	)
+	for ; i < len(list); i++ {
+		imp, ok := list[i].(ast.Import)
+		if !ok {
+			break
+		}
+		var (
+			comments = p.takeComments(imp.Line - 1)
+			n        = detached(comments, imp.Line)
+			start    = imp.Line
+		)
+		if n < len(comments) {
+			start = comments[n].Line
+		}
+		if len(group) > 0 && (n > 0 || start > last+1) {
+			p.comments = append(comments, p.comments...)
+			break
+		}
+		if len(group) == 0 {
+			p.printComments(comments[:n])
+			first = start
+		}
+		group = append(group, importStmt{
+			Import:   imp,
+			comments: comments[n:],
+			trailing: p.takeComments(imp.Line),
+		})
+		last = imp.Line
+	}
+	sort.SliceStable(group, func(i, j int) bool {
+		return importKey(group[i].Import) < importKey(group[j].Import)
+	})
+	p.space(first)
+	for _, g := range group {
+		for _, c := range g.comments {
+			p.indent()
+			p.printComment(c)
+			p.buf.WriteString("\n")
+		}
+		p.indent()
+		p.printImport(g.Import)
+		p.printTrailing(g.trailing)
+	}
+	p.last = last
+	return i
+}
+
+func (p *printer) printStmt(e ast.Expression) {
+	line := position(e).Line
+	p.flushComments(line)
+	p.space(line)
+	p.indent()
+	p.printExpr(e)
+	end := p.endLine(e)
+	p.printTrailing(p.takeComments(end))
+	p.last = end
+}
+
+func (p *printer) printTrailing(list []token.Token) {
+	for i, c := range list {
+		if i > 0 || isMultiline(c) {
+			p.buf.WriteString("\n")
+			p.indent()
+		} else {
+			p.buf.WriteString(" ")
+		}
+		p.printComment(c)
+	}
+	p.buf.WriteString("\n")
+}
+
+func (p *printer) flushComments(line int) {
+	p.printComments(p.takeComments(line - 1))
+}
+
+func (p *printer) printComments(list []token.Token) {
+	for _, c := range list {
+		p.space(c.Line)
+		p.indent()
+		p.printComment(c)
+		p.buf.WriteString("\n")
+		p.last = c.Line + strings.Count(c.Literal, "\n")
+	}
+}
+
+func (p *printer) takeComments(line int) []token.Token {
+	var n int
+	for n < len(p.comments) && p.comments[n].Line <= line {
+		n++
+	}
+	list := p.comments[:n]
+	p.comments = p.comments[n:]
+	return list
+}
+
+func (p *printer) printComment(c token.Token) {
+	if isMultiline(c) {
+		p.buf.WriteString("/* ")
+		p.buf.WriteString(strings.TrimSpace(c.Literal))
+		p.buf.WriteString(" */")
+		return
+	}
+	p.buf.WriteString("//")
+	if str := strings.TrimSpace(c.Literal); str != "" {
+		p.buf.WriteString(" ")
+		p.buf.WriteString(str)
+	}
+}
+
+func (p *printer) space(line int) {
+	if p.last > 0 && line > p.last+1 {
+		p.buf.WriteString("\n")
+	}
+}
+
+func (p *printer) indent() {
+	tab := p.tab
+	if tab == "" {
+		tab = "\t"
+	}
+	p.buf.WriteString(strings.Repeat(tab, p.level))
+}
+
+func (p *printer) printBlock(e ast.Expression) {
+	b, ok := e.(ast.Script)
+	if !ok {
+		p.printExpr(e)
+		return
+	}
+	end, ok := p.closing[b.Position]
+	if !ok {
+		end.Line = p.endLine(b)
+	}
+	if len(b.List) == 0 && (len(p.comments) == 0 || p.comments[0].Line >= end.Line) {
+		p.buf.WriteString("{}")
+		return
+	}
+	p.buf.WriteString("{\n")
+	p.level++
+	p.last = 0
+	p.printList(b.List)
+	p.flushComments(end.Line)
+	p.level--
+	p.indent()
+	p.buf.WriteString("}")
+	p.last = end.Line
+}
+
+func (p *printer) printExpr(expr ast.Expression) {
+	switch e := expr.(type) {
+	case ast.Literal:
+		if str, ok := p.literals[e.Position]; ok {
+			p.buf.WriteString(str)
+			break
+		}
+		p.buf.WriteString(quote(e.Str))
+	case ast.Template:
+		p.buf.WriteString(e.Literal)
+	case ast.Integer:
+		p.buf.WriteString(e.Literal)
+	case ast.Double:
+		p.buf.WriteString(e.Literal)
+	case ast.Boolean:
+		p.buf.WriteString(strconv.FormatBool(e.Value))
+	case ast.Nil:
+		p.buf.WriteString(token.KwNil)
+	case ast.Variable:
+		p.buf.WriteString(e.Ident)
+	case ast.Array:
+		p.buf.WriteString("[")
+		p.printArgs(e.List...)
+		p.buf.WriteString("]")
+	case ast.Dict:
+		p.printDict(e)
+	case ast.ListComp:
+		p.buf.WriteString("[")
+		p.printExpr(e.Body)
+		p.printComprehension(e.List)
+		p.buf.WriteString("]")
+	case ast.DictComp:
+		p.buf.WriteString("{")
+		p.printExpr(e.Key)
+		p.buf.WriteString(": ")
+		p.printExpr(e.Val)
+		p.printComprehension(e.List)
+		p.buf.WriteString("}")
+	case ast.Index:
+		p.printOperand(e.Arr, parse.Power(e), false)
+		p.buf.WriteString("[")
+		p.printArgs(e.List...)
+		p.buf.WriteString("]")
+	case ast.Slice:
+		if e.Start != nil {
+			p.printExpr(e.Start)
+		}
+		p.buf.WriteString(":")
+		if e.End != nil {
+			p.printExpr(e.End)
+		}
+		if e.Step != nil {
+			p.buf.WriteString(":")
+			p.printExpr(e.Step)
+		}
+	case ast.Path:
+		p.buf.WriteString(e.Ident)
+		p.buf.WriteString(".")
+		p.printExpr(e.Right)
+	case ast.Chain:
+		p.printOperand(e.Left, parse.Power(e), false)
+		p.buf.WriteString("?.")
+		if k, ok := e.Key.(ast.Literal); ok && k.Type == token.Ident {
+			p.buf.WriteString(k.Str)
+			break
+		}
+		p.buf.WriteString("[")
+		p.printExpr(e.Key)
+		p.buf.WriteString("]")
+	case ast.Call:
+		if e.Expr != nil {
+			p.printOperand(e.Expr, parse.Power(e), false)
+		} else {
+			p.buf.WriteString(e.Ident)
+		}
+		p.buf.WriteString("(")
+		p.printArgs(e.Args...)
+		p.buf.WriteString(")")
+	case ast.Parameter:
+		p.buf.WriteString(e.Ident)
+		if e.Expr != nil {
+			p.buf.WriteString("=")
+			p.printExpr(e.Expr)
+		}
+	case ast.Unary:
+		p.buf.WriteString(operator(e.Op))
+		p.printOperand(e.Right, parse.Power(e), true)
+	case ast.Binary:
+		pow := parse.Power(e)
+		p.printOperand(e.Left, pow, false)
+		p.buf.WriteString(" ")
+		p.buf.WriteString(operator(e.Op))
+		p.buf.WriteString(" ")
+		p.printOperand(e.Right, pow, true)
+	case ast.Assign:
+		p.printExpr(e.Ident)
+		right := e.Right
+		if b, ok := right.(ast.Binary); ok && e.Type != token.Assign {
+			right = b.Right
+		}
+		p.buf.WriteString(" ")
+		p.buf.WriteString(operator(e.Type))
+		p.buf.WriteString(" ")
+		p.printExpr(right)
+	case ast.Let:
+		p.buf.WriteString(token.KwLet)
+		p.buf.WriteString(" ")
+		p.buf.WriteString(e.Ident)
+		p.buf.WriteString(" = ")
+		p.printExpr(e.Right)
+	case ast.Test:
+		if e.Type == token.Ternary {
+			p.printOperand(e.Cdt, parse.Power(e), false)
+			p.buf.WriteString(" ? ")
+			p.printExpr(e.Csq)
+			p.buf.WriteString(" : ")
+			p.printExpr(e.Alt)
+			break
+		}
+		p.buf.WriteString(token.KwIf)
+		p.buf.WriteString(" ")
+		p.printExpr(e.Cdt)
+		p.buf.WriteString(" ")
+		p.printBlock(e.Csq)
+		if e.Alt != nil {
+			p.buf.WriteString(" ")
+			p.buf.WriteString(token.KwElse)
+			p.buf.WriteString(" ")
+			p.printBlock(e.Alt)
+		}
+	case ast.While:
+		p.buf.WriteString(token.KwWhile)
+		p.buf.WriteString(" ")
+		p.printExpr(e.Cdt)
+		p.buf.WriteString(" ")
+		p.printBlock(e.Body)
+	case ast.For:
+		p.buf.WriteString(token.KwFor)
+		p.buf.WriteString(" ")
+		if e.Init != nil {
+			p.printExpr(e.Init)
+		}
+		p.buf.WriteString("; ")
+		if e.Cdt != nil {
+			p.printExpr(e.Cdt)
+		}
+		p.buf.WriteString(";")
+		if e.Incr != nil {
+			p.buf.WriteString(" ")
+			p.printExpr(e.Incr)
+		}
+		p.buf.WriteString(" ")
+		p.printBlock(e.Body)
+	case ast.ForEach:
+		p.buf.WriteString(token.KwFor)
+		p.buf.WriteString(" ")
+		p.buf.WriteString(e.Ident)
+		p.buf.WriteString(" ")
+		p.buf.WriteString(token.KwIn)
+		p.buf.WriteString(" ")
+		p.printExpr(e.Iter)
+		p.buf.WriteString(" ")
+		p.printBlock(e.Body)
+	case ast.Try:
+		p.buf.WriteString(token.KwTry)
+		p.buf.WriteString(" ")
+		p.printBlock(e.Body)
+		if e.Catch != nil {
+			p.buf.WriteString(" ")
+			p.buf.WriteString(token.KwCatch)
+			if e.Ident != "" {
+				p.buf.WriteString("(")
+				p.buf.WriteString(e.Ident)
+				p.buf.WriteString(")")
+			}
+			p.buf.WriteString(" ")
+			p.printBlock(e.Catch)
+		}
+		if e.Finally != nil {
+			p.buf.WriteString(" ")
+			p.buf.WriteString(token.KwFinally)
+			p.buf.WriteString(" ")
+			p.printBlock(e.Finally)
+		}
+	case ast.Function:
+		p.buf.WriteString(token.KwDef)
+		if e.Ident != "" {
+			p.buf.WriteString(" ")
+			p.buf.WriteString(e.Ident)
+		}
+		p.buf.WriteString("(")
+		p.printArgs(e.Params...)
+		p.buf.WriteString(") ")
+		p.printBlock(e.Body)
+	case ast.Return:
+		p.buf.WriteString(token.KwReturn)
+		if e.Right != nil {
+			p.buf.WriteString(" ")
+			p.printExpr(e.Right)
+		}
+	case ast.Raise:
+		p.buf.WriteString(token.KwRaise)
+		p.buf.WriteString(" ")
+		p.printExpr(e.Right)
+	case ast.Assert:
+		p.buf.WriteString(token.KwAssert)
+		p.buf.WriteString(" ")
+		p.printExpr(e.Expr)
+	case ast.Break:
+		p.buf.WriteString(token.KwBreak)
+	case ast.Continue:
+		p.buf.WriteString(token.KwContinue)
+	case ast.Import:
+		p.printImport(e)
+	case ast.Script:
+		p.printBlock(e)
+	}
+}
+
+func (p *printer) printArgs(list ...ast.Expression) {
+	for i, e := range list {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.printExpr(e)
+	}
+}
+
+func (p *printer) printOperand(e ast.Expression, pow int, right bool) {
+	var paren bool
+	switch e := e.(type) {
+	case ast.Binary, ast.Assign:
+		paren = parse.Power(e) < pow || (right && parse.Power(e) == pow)
+	case ast.Unary:
+		paren = !right && parse.Power(e) < pow
+	case ast.Index:
+		paren = right && parse.Power(e) <= pow
+	case ast.Test:
+		paren = true
+	}
+	if paren {
+		p.buf.WriteString("(")
+	}
+	p.printExpr(e)
+	if paren {
+		p.buf.WriteString(")")
+	}
+}
+
+func (p *printer) printDict(d ast.Dict) {
+	keys := make([]ast.Expression, 0, len(d.List))
+	for k := range d.List {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return before(position(keys[i]), position(keys[j]))
+	})
+	p.buf.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.printExpr(k)
+		p.buf.WriteString(": ")
+		p.printExpr(d.List[k])
+	}
+	p.buf.WriteString("}")
+}
+
+func (p *printer) printComprehension(list []ast.CompItem) {
+	for _, c := range list {
+		p.buf.WriteString(" ")
+		p.buf.WriteString(token.KwFor)
+		p.buf.WriteString(" ")
+		p.buf.WriteString(c.Ident)
+		p.buf.WriteString(" ")
+		p.buf.WriteString(token.KwIn)
+		p.buf.WriteString(" ")
+		p.printExpr(c.Iter)
+		for _, e := range c.Cdt {
+			p.buf.WriteString(" ")
+			p.buf.WriteString(token.KwIf)
+			p.buf.WriteString(" ")
+			p.printExpr(e)
+		}
+	}
+}
+
+func (p *printer) printImport(imp ast.Import) {
+	if len(imp.Symbols) == 0 {
+		p.buf.WriteString(token.KwImport)
+		p.buf.WriteString(" ")
+		p.buf.WriteString(strings.Join(imp.Ident, "."))
+		if imp.Alias != "" && imp.Alias != imp.Ident[len(imp.Ident)-1] {
+			p.buf.WriteString(" ")
+			p.buf.WriteString(token.KwAs)
+			p.buf.WriteString(" ")
+			p.buf.WriteString(imp.Alias)
+		}
+		return
+	}
+	list := append([]ast.Symbol{}, imp.Symbols...)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Ident < list[j].Ident
+	})
+	p.buf.WriteString(token.KwFrom)
+	p.buf.WriteString(" ")
+	p.buf.WriteString(strings.Join(imp.Ident, "."))
+	p.buf.WriteString(" ")
+	p.buf.WriteString(token.KwImport)
+	p.buf.WriteString(" ")
+	for i, s := range list {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.buf.WriteString(s.Ident)
+		if s.Alias != "" && s.Alias != s.Ident {
+			p.buf.WriteString(" ")
+			p.buf.WriteString(token.KwAs)
+			p.buf.WriteString(" ")
+			p.buf.WriteString(s.Alias)
+		}
+	}
+}
+
+func (p *printer) endLine(expr ast.Expression) int {
+	line := position(expr).Line
+	switch e := expr.(type) {
+	case ast.Script:
+		if end, ok := p.closing[e.Position]; ok {
+			return end.Line
+		}
+		for _, e := range e.List {
+			line = max(line, p.endLine(e))
+		}
+	case ast.Function:
+		line = p.endLine(e.Body)
+	case ast.Test:
+		line = max(line, p.endLine(e.Csq))
+		if e.Alt != nil {
+			line = max(line, p.endLine(e.Alt))
+		}
+	case ast.While:
+		line = max(line, p.endLine(e.Body))
+	case ast.For:
+		line = max(line, p.endLine(e.Body))
+	case ast.ForEach:
+		line = max(line, p.endLine(e.Body))
+	case ast.Try:
+		line = max(line, p.endLine(e.Body))
+		if e.Catch != nil {
+			line = max(line, p.endLine(e.Catch))
+		}
+		if e.Finally != nil {
+			line = max(line, p.endLine(e.Finally))
+		}
+	case ast.Let:
+		line = max(line, p.endLine(e.Right))
+	case ast.Assign:
+		line = max(line, p.endLine(e.Right))
+	case ast.Binary:
+		line = max(line, p.endLine(e.Left))
+		line = max(line, p.endLine(e.Right))
+	case ast.Unary:
+		line = max(line, p.endLine(e.Right))
+	case ast.Return:
+		if e.Right != nil {
+			line = max(line, p.endLine(e.Right))
+		}
+	case ast.Raise:
+		line = max(line, p.endLine(e.Right))
+	case ast.Assert:
+		line = max(line, p.endLine(e.Expr))
+	case ast.Parameter:
+		if e.Expr != nil {
+			line = max(line, p.endLine(e.Expr))
+		}
+	case ast.Path:
+		line = max(line, p.endLine(e.Right))
+	case ast.Chain:
+		line = max(line, p.endLine(e.Key))
+	case ast.Call:
+		if e.Expr != nil {
+			line = max(line, p.endLine(e.Expr))
+		}
+		for _, a := range e.Args {
+			line = max(line, p.endLine(a))
+		}
+	case ast.Index:
+		line = max(line, p.endLine(e.Arr))
+		for _, a := range e.List {
+			line = max(line, p.endLine(a))
+		}
+	case ast.Array:
+		for _, a := range e.List {
+			line = max(line, p.endLine(a))
+		}
+	case ast.Dict:
+		for k, v := range e.List {
+			line = max(line, p.endLine(k))
+			line = max(line, p.endLine(v))
+		}
+	case ast.Literal:
+		line += strings.Count(p.literals[e.Position], "\n")
+	case ast.Template:
+		line += strings.Count(e.Literal, "\n")
+	}
+	return line
+}
+
+func position(e ast.Expression) token.Position {
+	if p, ok := e.(interface{ Pos() token.Position }); ok {
+		return p.Pos()
+	}
+	return token.Position{}
+}
+
+func before(p1, p2 token.Position) bool {
+	if p1.Line == p2.Line {
+		return p1.Column < p2.Column
+	}
+	return p1.Line < p2.Line
+}
+
+func importKey(imp ast.Import) string {
+	key := strings.Join(imp.Ident, ".")
+	if len(imp.Symbols) > 0 {
+		key += " " + token.KwFrom
+	}
+	return key
+}
+
+func detached(list []token.Token, line int) int {
+	n := len(list)
+	for ; n > 0; n-- {
+		c := list[n-1]
+		if c.Line+strings.Count(c.Literal, "\n") != line-1 {
+			break
+		}
+		line = c.Line
+	}
+	return n
+}
+
+func isMultiline(c token.Token) bool {
+	return strings.Contains(c.Literal, "\n")
+}
+
+func quote(str string) string {
+	return strconv.Quote(str)
+}
+
+func rawLiteral(src []byte, lines []int, pos token.Position) string {
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return ""
+	}
+	str := src[lines[pos.Line-1]:]
+	for i := 1; i < pos.Column && len(str) > 0; i++ {
+		_, size := utf8.DecodeRune(str)
+		str = str[size:]
+	}
+	if len(str) == 0 {
+		return ""
+	}
+	delim := str[:1]
+	switch delim[0] {
+	case '`':
+		if end := bytes.IndexByte(str[1:], '`'); end >= 0 {
+			return string(str[:end+2])
+		}
+		return ""
+	case '\'', '"':
+	default:
+		return ""
+	}
+	if multi := bytes.Repeat(delim, 3); bytes.HasPrefix(str, multi) {
+		delim = multi
+	}
+	for i := len(delim); i < len(str); i++ {
+		if str[i] == '\\' {
+			i++
+			continue
+		}
+		if bytes.HasPrefix(str[i:], delim) {
+			return string(str[:i+len(delim)])
+		}
+	}
+	return ""
+}
+
+func operator(op rune) string {
+	switch op {
+	case token.Add:
+		return "+"
+	case token.AddAssign:
+		return "+="
+	case token.Sub:
+		return "-"
+	case token.SubAssign:
+		return "-="
+	case token.Mul:
+		return "*"
+	case token.MulAssign:
+		return "*="
+	case token.Pow:
+		return "**"
+	case token.Div:
+		return "/"
+	case token.DivAssign:
+		return "/="
+	case token.Mod:
+		return "%"
+	case token.ModAssign:
+		return "%="
+	case token.Lshift:
+		return "<<"
+	case token.LshiftAssign:
+		return "<<="
+	case token.Rshift:
+		return ">>"
+	case token.RshiftAssign:
+		return ">>="
+	case token.BinAnd:
+		return "&"
+	case token.BinAndAssign:
+		return "&="
+	case token.BinOr:
+		return "|"
+	case token.BinOrAssign:
+		return "|="
+	case token.BinXor:
+		return "^"
+	case token.BinXorAssign:
+		return "^="
+	case token.BinNot:
+		return "~"
+	case token.Lt:
+		return "<"
+	case token.Le:
+		return "<="
+	case token.Gt:
+		return ">"
+	case token.Ge:
+		return ">="
+	case token.Eq:
+		return "=="
+	case token.Ne:
+		return "!="
+	case token.Assign:
+		return "="
+	case token.Nullish:
+		return "??"
+	case token.Not:
+		return "!"
+	case token.And:
+		return "&&"
+	case token.Or:
+		return "||"
+	}
+	return "?"
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
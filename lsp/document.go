@@ -0,0 +1,516 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/builtins"
+	"github.com/midbel/buddy/faults"
+	"github.com/midbel/buddy/format"
+	"github.com/midbel/buddy/parse"
+	"github.com/midbel/buddy/token"
+	"github.com/midbel/buddy/types"
+	"github.com/midbel/buddy/visitors"
+)
+
+type symbol struct {
+	module string
+	name   string
+}
+
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	script  ast.Script
+	modules map[string]string
+	symbols map[string]symbol
+}
+
+func createDocument(uri string) *document {
+	return &document{
+		uri: uri,
+	}
+}
+
+func (d *document) Update(text string) []Diagnostic {
+	d.text = text
+	d.lines = strings.Split(text, "\n")
+
+	expr, err := parse.New(strings.NewReader(text)).Parse()
+	if err != nil {
+		return d.diagnostics(err, SeverityError)
+	}
+	d.script = expr.(ast.Script)
+	d.modules = make(map[string]string)
+	d.symbols = make(map[string]symbol)
+	for _, e := range d.script.List {
+		imp, ok := e.(ast.Import)
+		if !ok {
+			continue
+		}
+		mod := strings.Join(imp.Ident, ".")
+		if len(imp.Symbols) == 0 {
+			d.modules[imp.Alias] = mod
+			continue
+		}
+		for _, s := range imp.Symbols {
+			d.symbols[s.Alias] = symbol{
+				module: mod,
+				name:   s.Ident,
+			}
+		}
+	}
+	var (
+		lint = d.script
+		all  = []visitors.Visitor{
+			visitors.Variable(),
+			visitors.Import(),
+			visitors.Loop(),
+		}
+	)
+	lint.Symbols = make(map[string]ast.Expression)
+	for k, v := range d.script.Symbols {
+		lint.Symbols[k] = v
+	}
+	_, err = visitors.Visit(lint, all)
+	return d.diagnostics(err, SeverityWarning)
+}
+
+func (d *document) diagnostics(err error, severity int) []Diagnostic {
+	var (
+		list []Diagnostic
+		seen = make(map[Diagnostic]struct{})
+	)
+	for _, e := range flatten(err) {
+		diag := Diagnostic{
+			Severity: severity,
+			Source:   "buddy",
+			Message:  e.Error(),
+		}
+		var (
+			perr  faults.ParseError
+			ierr  visitors.IdentError
+			start token.Position
+			size  = 1
+		)
+		switch {
+		case errors.As(e, &perr):
+			start, diag.Message = perr.Position, perr.Message
+			if n := len([]rune(perr.Literal)); n > 0 {
+				size = n
+			}
+		case errors.As(e, &ierr):
+			start = ierr.Position
+			if n := len([]rune(ierr.Ident)); n > 0 {
+				size = n
+			}
+		default:
+			start = token.Position{Line: 1, Column: 1}
+		}
+		diag.Range = d.rangeOf(start, size)
+		if _, ok := seen[diag]; ok {
+			continue
+		}
+		seen[diag] = struct{}{}
+		list = append(list, diag)
+	}
+	return list
+}
+
+func (d *document) Definition(pos Position) (Location, bool) {
+	var loc Location
+	qualifier, word, _ := d.wordAt(pos)
+	if word == "" || qualifier != "" {
+		return loc, false
+	}
+	loc.URI = d.uri
+	if fn, ok := d.script.Symbols[word].(ast.Function); ok {
+		loc.Range = d.identRange(fn.Position, word)
+		return loc, true
+	}
+	for _, e := range d.script.List {
+		switch e := e.(type) {
+		case ast.Let:
+			if e.Ident == word {
+				loc.Range = d.identRange(e.Position, word)
+				return loc, true
+			}
+		case ast.Import:
+			if _, ok := d.modules[word]; ok && e.Alias == word && len(e.Symbols) == 0 {
+				loc.Range = d.identRange(e.Position, word)
+				return loc, true
+			}
+			for _, s := range e.Symbols {
+				if s.Alias == word {
+					loc.Range = d.identRange(s.Position, word)
+					return loc, true
+				}
+			}
+		}
+	}
+	return loc, false
+}
+
+func (d *document) Hover(pos Position) (Hover, bool) {
+	var (
+		hover                  Hover
+		qualifier, word, where = d.wordAt(pos)
+		str                    string
+	)
+	if word == "" {
+		return hover, false
+	}
+	if qualifier != "" {
+		mod, ok := d.module(qualifier)
+		if !ok {
+			return hover, false
+		}
+		if str, _ = describeMember(mod, word); str == "" {
+			return hover, false
+		}
+	} else if str = d.describe(word); str == "" {
+		return hover, false
+	}
+	hover.Contents = MarkupContent{
+		Kind:  "markdown",
+		Value: fmt.Sprintf("```buddy\n%s\n```", str),
+	}
+	hover.Range = &where
+	return hover, true
+}
+
+func (d *document) describe(word string) string {
+	if fn, ok := d.script.Symbols[word].(ast.Function); ok {
+		return describeFunction(fn)
+	}
+	if s, ok := d.symbols[word]; ok {
+		if mod, err := builtins.LookupModule(s.module); err == nil {
+			str, _ := describeMember(mod, s.name)
+			return str
+		}
+		return fmt.Sprintf("from %s import %s", s.module, s.name)
+	}
+	if mod, ok := d.modules[word]; ok {
+		return fmt.Sprintf("import %s", mod)
+	}
+	for _, e := range d.script.List {
+		if e, ok := e.(ast.Let); ok && e.Ident == word {
+			return fmt.Sprintf("let %s = %s", e.Ident, format.Expression(e.Right))
+		}
+	}
+	if call, err := builtins.LookupBuiltin(word); err == nil {
+		if b, ok := call.(builtins.Builtin); ok {
+			return describeBuiltin(word, b)
+		}
+	}
+	return ""
+}
+
+var member = regexp.MustCompile(`([\pL_][\pL\pN_]*)\.([\pL\pN_]*)$`)
+
+func (d *document) Complete(pos Position) []CompletionItem {
+	var (
+		line  = d.line(pos.Line)
+		col   = d.column(line, pos.Character)
+		start = col
+		list  []CompletionItem
+	)
+	if m := member.FindStringSubmatch(string(line[:col])); m != nil {
+		mod, ok := d.module(m[1])
+		if !ok {
+			return nil
+		}
+		return filterItems(moduleItems(mod), m[2])
+	}
+	for start > 0 && isIdent(line[start-1]) {
+		start--
+	}
+	word := string(line[start:col])
+	for _, kw := range keywords {
+		list = append(list, CompletionItem{Label: kw, Kind: KindKeyword})
+	}
+	for n, e := range d.script.Symbols {
+		item := CompletionItem{
+			Label: n,
+			Kind:  KindFunction,
+		}
+		if fn, ok := e.(ast.Function); ok {
+			item.Detail = describeFunction(fn)
+		}
+		list = append(list, item)
+	}
+	for _, e := range d.script.List {
+		if e, ok := e.(ast.Let); ok {
+			list = append(list, CompletionItem{Label: e.Ident, Kind: KindVariable})
+		}
+	}
+	for n, m := range d.modules {
+		list = append(list, CompletionItem{Label: n, Kind: KindModule, Detail: "import " + m})
+	}
+	for n := range d.symbols {
+		list = append(list, CompletionItem{Label: n, Kind: KindFunction, Detail: d.describe(n)})
+	}
+	if mod, err := builtins.LookupModule("builtin"); err == nil {
+		list = append(list, moduleItems(mod)...)
+	}
+	return filterItems(list, word)
+}
+
+func (d *document) Format(opts FormattingOptions) []TextEdit {
+	indent := "\t"
+	if opts.InsertSpaces && opts.TabSize > 0 {
+		indent = strings.Repeat(" ", opts.TabSize)
+	}
+	edits := []TextEdit{}
+	res, err := format.SourceIndent(d.uri, []byte(d.text), indent)
+	if err != nil || string(res) == d.text {
+		return edits
+	}
+	last := len(d.lines) - 1
+	edit := TextEdit{
+		Range: Range{
+			End: Position{
+				Line:      last,
+				Character: utf16Len(d.lines[last]),
+			},
+		},
+		NewText: string(res),
+	}
+	return append(edits, edit)
+}
+
+func (d *document) module(alias string) (builtins.Module, bool) {
+	name, ok := d.modules[alias]
+	if !ok {
+		name = alias
+	}
+	mod, err := builtins.LookupModule(name)
+	return mod, err == nil
+}
+
+func (d *document) wordAt(pos Position) (string, string, Range) {
+	var (
+		line  = d.line(pos.Line)
+		col   = d.column(line, pos.Character)
+		start = col
+		end   = col
+		rg    Range
+	)
+	for start > 0 && isIdent(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isIdent(line[end]) {
+		end++
+	}
+	if start == end {
+		return "", "", rg
+	}
+	rg.Start = Position{Line: pos.Line, Character: utf16Len(string(line[:start]))}
+	rg.End = Position{Line: pos.Line, Character: utf16Len(string(line[:end]))}
+
+	var qualifier string
+	if start > 1 && line[start-1] == '.' {
+		var (
+			stop  = start - 1
+			begin = stop
+		)
+		for begin > 0 && isIdent(line[begin-1]) {
+			begin--
+		}
+		qualifier = string(line[begin:stop])
+	}
+	return qualifier, string(line[start:end]), rg
+}
+
+func (d *document) line(n int) []rune {
+	if n < 0 || n >= len(d.lines) {
+		return nil
+	}
+	return []rune(strings.TrimSuffix(d.lines[n], "\r"))
+}
+
+func (d *document) column(line []rune, char int) int {
+	var n int
+	for i, r := range line {
+		if n >= char {
+			return i
+		}
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
+
+func (d *document) rangeOf(pos token.Position, size int) Range {
+	var (
+		line = d.line(pos.Line - 1)
+		col  = pos.Column - 1
+		rg   Range
+	)
+	if col < 0 {
+		col = 0
+	}
+	if col > len(line) {
+		col = len(line)
+	}
+	end := col + size
+	if end > len(line) {
+		end = len(line)
+	}
+	rg.Start = Position{Line: pos.Line - 1, Character: utf16Len(string(line[:col]))}
+	rg.End = Position{Line: pos.Line - 1, Character: utf16Len(string(line[:end]))}
+	return rg
+}
+
+func (d *document) identRange(pos token.Position, ident string) Range {
+	var (
+		line = d.line(pos.Line - 1)
+		word = []rune(ident)
+	)
+	for i := pos.Column - 1; i >= 0 && i+len(word) <= len(line); i++ {
+		if string(line[i:i+len(word)]) != ident {
+			continue
+		}
+		if (i > 0 && isIdent(line[i-1])) || (i+len(word) < len(line) && isIdent(line[i+len(word)])) {
+			continue
+		}
+		pos.Column = i + 1
+		break
+	}
+	return d.rangeOf(pos, len(word))
+}
+
+var keywords = []string{
+	token.KwIf,
+	token.KwElse,
+	token.KwWhile,
+	token.KwReturn,
+	token.KwDef,
+	token.KwTrue,
+	token.KwFalse,
+	token.KwBreak,
+	token.KwContinue,
+	token.KwImport,
+	token.KwFrom,
+	token.KwFor,
+	token.KwAs,
+	token.KwIn,
+	token.KwAssert,
+	token.KwLet,
+	token.KwTry,
+	token.KwCatch,
+	token.KwFinally,
+	token.KwRaise,
+	token.KwNil,
+}
+
+func moduleItems(mod builtins.Module) []CompletionItem {
+	var list []CompletionItem
+	for n := range mod.Builtins {
+		str, _ := describeMember(mod, n)
+		list = append(list, CompletionItem{Label: n, Kind: KindFunction, Detail: str})
+	}
+	for n := range mod.Values {
+		str, _ := describeMember(mod, n)
+		list = append(list, CompletionItem{Label: n, Kind: KindConstant, Detail: str})
+	}
+	for n := range mod.Attrs {
+		str, _ := describeMember(mod, n)
+		list = append(list, CompletionItem{Label: n, Kind: KindProperty, Detail: str})
+	}
+	return list
+}
+
+func filterItems(list []CompletionItem, prefix string) []CompletionItem {
+	var res []CompletionItem
+	for _, i := range list {
+		if strings.HasPrefix(i.Label, prefix) {
+			res = append(res, i)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Label < res[j].Label
+	})
+	return res
+}
+
+func describeMember(mod builtins.Module, name string) (string, int) {
+	if b, ok := mod.Builtins[name]; ok {
+		if mod.Name != "builtin" {
+			name = mod.Name + "." + name
+		}
+		return describeBuiltin(name, b), KindFunction
+	}
+	if v, ok := mod.Values[name]; ok {
+		return fmt.Sprintf("%s.%s = %s", mod.Name, name, describeValue(v)), KindConstant
+	}
+	if _, ok := mod.Attrs[name]; ok {
+		return fmt.Sprintf("%s.%s", mod.Name, name), KindProperty
+	}
+	return "", 0
+}
+
+func describeBuiltin(name string, b builtins.Builtin) string {
+	var list []string
+	for _, a := range b.Params {
+		if a.Value == nil {
+			list = append(list, a.Name)
+			continue
+		}
+		list = append(list, fmt.Sprintf("%s=%s", a.Name, describeValue(a.Value)))
+	}
+	if b.Variadic {
+		list = append(list, "...")
+	}
+	return fmt.Sprintf("def %s(%s)", name, strings.Join(list, ", "))
+}
+
+func describeFunction(fn ast.Function) string {
+	var list []string
+	for _, p := range fn.Params {
+		list = append(list, format.Expression(p))
+	}
+	return fmt.Sprintf("def %s(%s)", fn.Ident, strings.Join(list, ", "))
+}
+
+func describeValue(v types.Primitive) string {
+	switch v.(type) {
+	case types.String:
+		return strconv.Quote(v.String())
+	case types.Nil:
+		return token.KwNil
+	default:
+		return v.String()
+	}
+}
+
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	var list *faults.ErrorList
+	if !errors.As(err, &list) {
+		return []error{err}
+	}
+	var es []error
+	for _, e := range *list {
+		es = append(es, flatten(e)...)
+	}
+	return es
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func utf16Len(str string) int {
+	return len(utf16.Encode([]rune(str)))
+}
@@ -0,0 +1,128 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+)
+
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+func (r Request) IsNotification() bool {
+	return len(r.Id) == 0
+}
+
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type Notification struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+type Conn struct {
+	in  *textproto.Reader
+	out io.Writer
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{
+		in:  textproto.NewReader(bufio.NewReader(r)),
+		out: w,
+	}
+}
+
+func (c *Conn) Read() (Request, error) {
+	var req Request
+	hdr, err := c.in.ReadMIMEHeader()
+	if err != nil {
+		return req, err
+	}
+	size, err := strconv.Atoi(hdr.Get("Content-Length"))
+	if err != nil || size < 0 {
+		return req, fmt.Errorf("invalid Content-Length header")
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.in.R, body); err != nil {
+		return req, err
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return req, &Error{Code: ParseError, Message: err.Error()}
+	}
+	return req, nil
+}
+
+func (c *Conn) Reply(id json.RawMessage, res interface{}, err error) error {
+	msg := Response{
+		Version: "2.0",
+		Id:      id,
+	}
+	if len(msg.Id) == 0 {
+		msg.Id = json.RawMessage("null")
+	}
+	if err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			e = &Error{Code: InternalError, Message: err.Error()}
+		}
+		msg.Error = e
+		return c.write(msg)
+	}
+	buf, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	msg.Result = buf
+	return c.write(msg)
+}
+
+func (c *Conn) Notify(method string, params interface{}) error {
+	msg := Notification{
+		Version: "2.0",
+		Method:  method,
+		Params:  params,
+	}
+	return c.write(msg)
+}
+
+func (c *Conn) write(msg interface{}) error {
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n", len(buf)); err != nil {
+		return err
+	}
+	_, err = c.out.Write(buf)
+	return err
+}
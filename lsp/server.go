@@ -0,0 +1,213 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+var ErrNoShutdown = errors.New("exit requested before shutdown")
+
+type Server struct {
+	conn     *Conn
+	docs     map[string]*document
+	shutdown bool
+}
+
+func Serve(r io.Reader, w io.Writer) error {
+	s := Server{
+		conn: NewConn(r, w),
+		docs: make(map[string]*document),
+	}
+	return s.Run()
+}
+
+func (s *Server) Run() error {
+	for {
+		req, err := s.conn.Read()
+		if err != nil {
+			var rerr *Error
+			if errors.As(err, &rerr) {
+				s.conn.Reply(nil, nil, rerr)
+				continue
+			}
+			if errors.Is(err, io.EOF) && s.shutdown {
+				return nil
+			}
+			return err
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+		res, err := s.handle(req)
+		if req.IsNotification() {
+			continue
+		}
+		if err := s.conn.Reply(req.Id, res, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req Request) (interface{}, error) {
+	if s.shutdown {
+		return nil, &Error{Code: InvalidRequest, Message: "server is shutting down"}
+	}
+	switch req.Method {
+	case "initialize":
+		return s.initialize()
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		return s.didOpen(req.Params)
+	case "textDocument/didChange":
+		return s.didChange(req.Params)
+	case "textDocument/didClose":
+		return s.didClose(req.Params)
+	case "textDocument/definition":
+		return s.definition(req.Params)
+	case "textDocument/hover":
+		return s.hover(req.Params)
+	case "textDocument/completion":
+		return s.completion(req.Params)
+	case "textDocument/formatting":
+		return s.formatting(req.Params)
+	default:
+		return nil, &Error{Code: MethodNotFound, Message: req.Method + ": method not supported"}
+	}
+}
+
+func (s *Server) initialize() (interface{}, error) {
+	res := InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:   SyncFull,
+			HoverProvider:      true,
+			DefinitionProvider: true,
+			CompletionProvider: CompletionOptions{
+				TriggerCharacters: []string{"."},
+			},
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: ServerInfo{
+			Name: "buddy-lsp",
+		},
+	}
+	return res, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc := createDocument(p.TextDocument.URI)
+	s.docs[doc.uri] = doc
+	return nil, s.update(doc, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok || len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	return nil, s.update(doc, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return nil, s.publish(p.TextDocument.URI, nil)
+}
+
+func (s *Server) update(doc *document, text string) error {
+	return s.publish(doc.uri, doc.Update(text))
+}
+
+func (s *Server) publish(uri string, list []Diagnostic) error {
+	if list == nil {
+		list = []Diagnostic{}
+	}
+	params := PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: list,
+	}
+	return s.conn.Notify("textDocument/publishDiagnostics", params)
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.position(params)
+	if doc == nil || err != nil {
+		return nil, err
+	}
+	loc, ok := doc.Definition(pos)
+	if !ok {
+		return nil, nil
+	}
+	return loc, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.position(params)
+	if doc == nil || err != nil {
+		return nil, err
+	}
+	hover, ok := doc.Hover(pos)
+	if !ok {
+		return nil, nil
+	}
+	return hover, nil
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.position(params)
+	if doc == nil || err != nil {
+		return nil, err
+	}
+	list := CompletionList{
+		Items: doc.Complete(pos),
+	}
+	if list.Items == nil {
+		list.Items = []CompletionItem{}
+	}
+	return list, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentFormattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	return doc.Format(p.Options), nil
+}
+
+func (s *Server) position(params json.RawMessage) (*document, Position, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, p.Position, err
+	}
+	return s.docs[p.TextDocument.URI], p.Position, nil
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: InvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const sample = `import strings
+
+def greet(name) {
+	return "hello " + name
+}
+
+let who = strings.upper("world")
+greet(who)
+`
+
+type message struct {
+	Id     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+type session struct {
+	buf bytes.Buffer
+	id  int
+}
+
+func (s *session) request(method string, params interface{}) int {
+	s.id++
+	s.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      s.id,
+		"method":  method,
+		"params":  params,
+	})
+	return s.id
+}
+
+func (s *session) notify(method string, params interface{}) {
+	s.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+func (s *session) write(msg interface{}) {
+	buf, _ := json.Marshal(msg)
+	fmt.Fprintf(&s.buf, "Content-Length: %d\r\n\r\n", len(buf))
+	s.buf.Write(buf)
+}
+
+func (s *session) open(uri, text string) {
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":     uri,
+			"text":    text,
+			"version": 1,
+		},
+	})
+}
+
+func (s *session) position(method, uri string, line, char int) int {
+	return s.request(method, map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": char},
+	})
+}
+
+func (s *session) run(t *testing.T) []message {
+	t.Helper()
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var out bytes.Buffer
+	if err := Serve(&s.buf, &out); err != nil {
+		t.Fatalf("serve: %s", err)
+	}
+	var (
+		list []message
+		rs   = textproto.NewReader(bufio.NewReader(&out))
+	)
+	for {
+		hdr, err := rs.ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read header: %s", err)
+		}
+		size, _ := strconv.Atoi(hdr.Get("Content-Length"))
+		body := make([]byte, size)
+		if _, err := io.ReadFull(rs.R, body); err != nil {
+			t.Fatalf("read body: %s", err)
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("decode %s: %s", body, err)
+		}
+		list = append(list, msg)
+	}
+	return list
+}
+
+func response(t *testing.T, list []message, id int, v interface{}) {
+	t.Helper()
+	for _, m := range list {
+		if m.Id == nil || *m.Id != id {
+			continue
+		}
+		if m.Error != nil {
+			t.Fatalf("request %d: unexpected error: %s", id, m.Error)
+		}
+		if err := json.Unmarshal(m.Result, v); err != nil {
+			t.Fatalf("request %d: decode result: %s", id, err)
+		}
+		return
+	}
+	t.Fatalf("request %d: no response", id)
+}
+
+func diagnostics(t *testing.T, list []message, uri string) [][]Diagnostic {
+	t.Helper()
+	var all [][]Diagnostic
+	for _, m := range list {
+		if m.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p PublishDiagnosticsParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			t.Fatalf("decode diagnostics: %s", err)
+		}
+		if p.URI == uri {
+			all = append(all, p.Diagnostics)
+		}
+	}
+	return all
+}
+
+func TestInitialize(t *testing.T) {
+	var s session
+	id := s.request("initialize", map[string]interface{}{})
+	s.notify("initialized", map[string]interface{}{})
+
+	var res InitializeResult
+	response(t, s.run(t), id, &res)
+	if res.ServerInfo.Name != "buddy-lsp" {
+		t.Errorf("server name: want buddy-lsp, got %q", res.ServerInfo.Name)
+	}
+	caps := res.Capabilities
+	if caps.TextDocumentSync != SyncFull {
+		t.Errorf("sync: want %d, got %d", SyncFull, caps.TextDocumentSync)
+	}
+	if !caps.HoverProvider || !caps.DefinitionProvider || !caps.DocumentFormattingProvider {
+		t.Errorf("missing capabilities: %+v", caps)
+	}
+	if len(caps.CompletionProvider.TriggerCharacters) == 0 {
+		t.Errorf("no completion trigger characters")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	var s session
+	s.open("file:///valid.bud", sample)
+	s.open("file:///broken.bud", "let x = (1 + \nlet y = 2\n")
+	s.open("file:///empty.bud", "")
+	id := s.position("textDocument/hover", "file:///empty.bud", 0, 0)
+
+	list := s.run(t)
+	if all := diagnostics(t, list, "file:///valid.bud"); len(all) != 1 || len(all[0]) != 0 {
+		t.Errorf("valid document: unexpected diagnostics %+v", all)
+	}
+	all := diagnostics(t, list, "file:///broken.bud")
+	if len(all) != 1 || len(all[0]) == 0 {
+		t.Fatalf("broken document: expected diagnostics, got %+v", all)
+	}
+	if d := all[0][0]; d.Severity != SeverityError || d.Range.Start.Line != 0 {
+		t.Errorf("broken document: unexpected diagnostic %+v", d)
+	}
+	if all := diagnostics(t, list, "file:///empty.bud"); len(all) != 1 || len(all[0]) != 0 {
+		t.Errorf("empty document: unexpected diagnostics %+v", all)
+	}
+	var hover *Hover
+	response(t, list, id, &hover)
+	if hover != nil {
+		t.Errorf("empty document: unexpected hover %+v", hover)
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		Line int
+		Char int
+		Want string
+	}{
+		{Line: 7, Char: 1, Want: "greet(name)"},
+		{Line: 7, Char: 7, Want: `let who = strings.upper("world")`},
+		{Line: 6, Char: 19, Want: "upper"},
+	}
+	var (
+		s   session
+		ids []int
+	)
+	s.open("file:///hover.bud", sample)
+	for _, tt := range tests {
+		ids = append(ids, s.position("textDocument/hover", "file:///hover.bud", tt.Line, tt.Char))
+	}
+	list := s.run(t)
+	for i, tt := range tests {
+		var hover Hover
+		response(t, list, ids[i], &hover)
+		if hover.Contents.Kind != "markdown" || !strings.Contains(hover.Contents.Value, tt.Want) {
+			t.Errorf("hover at %d:%d: want %q, got %q", tt.Line, tt.Char, tt.Want, hover.Contents.Value)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	var s session
+	s.open("file:///def.bud", sample)
+	fn := s.position("textDocument/definition", "file:///def.bud", 7, 2)
+	mod := s.position("textDocument/definition", "file:///def.bud", 6, 12)
+
+	list := s.run(t)
+	var loc Location
+	response(t, list, fn, &loc)
+	if loc.URI != "file:///def.bud" || loc.Range.Start.Line != 2 {
+		t.Errorf("function definition: unexpected location %+v", loc)
+	}
+	response(t, list, mod, &loc)
+	if loc.Range.Start.Line != 0 {
+		t.Errorf("import definition: unexpected location %+v", loc)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	var s session
+	s.open("file:///complete.bud", sample+"strings.tr\ngr\n")
+	member := s.position("textDocument/completion", "file:///complete.bud", 8, 10)
+	global := s.position("textDocument/completion", "file:///complete.bud", 9, 2)
+
+	list := s.run(t)
+	var res CompletionList
+	response(t, list, member, &res)
+	if !hasItem(res.Items, "trim") || hasItem(res.Items, "upper") {
+		t.Errorf("member completion: unexpected items %+v", res.Items)
+	}
+	response(t, list, global, &res)
+	if !hasItem(res.Items, "greet") {
+		t.Errorf("global completion: greet not found in %+v", res.Items)
+	}
+}
+
+func hasItem(list []CompletionItem, label string) bool {
+	for _, i := range list {
+		if i.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFormatting(t *testing.T) {
+	const src = "def f(x) {\nreturn x+1\n}\n"
+	tests := []struct {
+		Options FormattingOptions
+		Want    string
+	}{
+		{Want: "def f(x) {\n\treturn x + 1\n}\n"},
+		{Options: FormattingOptions{TabSize: 4}, Want: "def f(x) {\n\treturn x + 1\n}\n"},
+		{Options: FormattingOptions{TabSize: 2, InsertSpaces: true}, Want: "def f(x) {\n  return x + 1\n}\n"},
+	}
+	var (
+		s   session
+		ids []int
+	)
+	s.open("file:///fmt.bud", src)
+	for _, tt := range tests {
+		id := s.request("textDocument/formatting", map[string]interface{}{
+			"textDocument": map[string]string{"uri": "file:///fmt.bud"},
+			"options":      tt.Options,
+		})
+		ids = append(ids, id)
+	}
+	list := s.run(t)
+	for i, tt := range tests {
+		var edits []TextEdit
+		response(t, list, ids[i], &edits)
+		if len(edits) != 1 {
+			t.Errorf("options %+v: want 1 edit, got %d", tt.Options, len(edits))
+			continue
+		}
+		if edits[0].NewText != tt.Want {
+			t.Errorf("options %+v: want %q, got %q", tt.Options, tt.Want, edits[0].NewText)
+		}
+	}
+}
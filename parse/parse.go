@@ -3,31 +3,18 @@ package parse
 import (
 	"fmt"
 	"io"
-	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/faults"
 	"github.com/midbel/buddy/scan"
 	"github.com/midbel/buddy/token"
 )
 
 const MaxArity = 255
 
-type ParseError struct {
-	token.Token
-	File    string
-	Line    string
-	Message string
-}
-
-func (e ParseError) Error() string {
-	if e.File == "" {
-		e.File = "<input>"
-	} else {
-		e.File = filepath.Base(e.File)
-	}
-	return fmt.Sprintf("%s %s: %s", e.File, e.Position, e.Message)
-}
+type ParseError = faults.ParseError
 
 type Parser struct {
 	file string
@@ -35,6 +22,8 @@ type Parser struct {
 	curr token.Token
 	peek token.Token
 
+	errors faults.ErrorList
+
 	prefix map[rune]func() (ast.Expression, error)
 	infix  map[rune]func(ast.Expression) (ast.Expression, error)
 }
@@ -44,14 +33,20 @@ func Parse(r io.Reader) (ast.Expression, error) {
 }
 
 func New(r io.Reader) *Parser {
+	var file string
+	if n, ok := r.(interface{ Name() string }); ok {
+		file = n.Name()
+	}
+	return create(scan.Scan(r), file)
+}
+
+func create(scanner *scan.Scanner, file string) *Parser {
 	p := Parser{
-		scan:   scan.Scan(r),
+		file:   file,
+		scan:   scanner,
 		prefix: make(map[rune]func() (ast.Expression, error)),
 		infix:  make(map[rune]func(ast.Expression) (ast.Expression, error)),
 	}
-	if n, ok := r.(interface{ Name() string }); ok {
-		p.file = n.Name()
-	}
 
 	p.registerPrefix(token.BinNot, p.parseUnary)
 	p.registerPrefix(token.Sub, p.parseUnary)
@@ -60,6 +55,7 @@ func New(r io.Reader) *Parser {
 	p.registerPrefix(token.Integer, p.parseInteger)
 	p.registerPrefix(token.Boolean, p.parseBoolean)
 	p.registerPrefix(token.Literal, p.parseLiteral)
+	p.registerPrefix(token.Template, p.parseTemplate)
 	p.registerPrefix(token.Ident, p.parseIdentifier)
 	p.registerPrefix(token.Lparen, p.parseGroup)
 	p.registerPrefix(token.Lsquare, p.parseArray)
@@ -100,6 +96,8 @@ func New(r io.Reader) *Parser {
 	p.registerInfix(token.Ge, p.parseInfix)
 	p.registerInfix(token.And, p.parseInfix)
 	p.registerInfix(token.Or, p.parseInfix)
+	p.registerInfix(token.Nullish, p.parseInfix)
+	p.registerInfix(token.OptChain, p.parseChain)
 
 	p.next()
 	p.next()
@@ -109,28 +107,66 @@ func New(r io.Reader) *Parser {
 func (p *Parser) Parse() (ast.Expression, error) {
 	s := ast.CreateScript(p.curr)
 	for !p.done() {
-		if p.is(token.Comment) {
+		if p.is(token.EOL) {
 			p.next()
 			continue
 		}
 		if ok, err := p.parseSpecial(&s); ok {
-			if err != nil {
-				return nil, err
+			if err != nil && !p.recover(err, false) {
+				break
 			}
 			continue
 		}
 		e, err := p.parse(powLowest)
-		if err != nil {
-			return nil, err
+		if err == nil {
+			s.List = append(s.List, e)
+			err = p.eol()
 		}
-		s.List = append(s.List, e)
-		if err := p.eol(); err != nil {
-			return nil, err
+		if err != nil && !p.recover(err, false) {
+			break
 		}
 	}
+	if p.errors.Size() > 0 {
+		return nil, &p.errors
+	}
 	return s, nil
 }
 
+func (p *Parser) recover(err error, block bool) bool {
+	if p.errors.Size() >= faults.MaxErrorCount {
+		return false
+	}
+	p.errors.Append(err)
+	if p.errors.Size() >= faults.MaxErrorCount {
+		return false
+	}
+	var depth int
+	for !p.done() {
+		switch p.curr.Type {
+		case token.Lcurly:
+			depth++
+		case token.Rcurly:
+			if depth == 0 && block {
+				return true
+			}
+			if depth > 0 {
+				depth--
+			}
+		case token.EOL:
+			if depth == 0 {
+				p.next()
+				p.skip(token.EOL)
+				return true
+			}
+		}
+		prev := p.curr
+		if p.next(); p.curr.Type == prev.Type && p.curr.Position == prev.Position {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *Parser) parse(pow int) (ast.Expression, error) {
 	left, err := p.getPrefixExpr()
 	if err != nil {
@@ -146,7 +182,7 @@ func (p *Parser) parse(pow int) (ast.Expression, error) {
 }
 
 func (p *Parser) parseSpecial(s *ast.Script) (bool, error) {
-	if err := p.expectKW(token.KwDef, ""); err != nil {
+	if err := p.expectKW(token.KwDef, ""); err != nil || !p.peekIs(token.Ident) {
 		return false, nil
 	}
 	var (
@@ -182,6 +218,14 @@ func (p *Parser) parseKeyword() (ast.Expression, error) {
 		return p.parseAssert()
 	case token.KwLet:
 		return p.parseLet()
+	case token.KwDef:
+		return p.parseFunction()
+	case token.KwTry:
+		return p.parseTry()
+	case token.KwRaise:
+		return p.parseRaise()
+	case token.KwNil:
+		return p.parseNil()
 	default:
 		return nil, p.parseError("keyword not recognized")
 	}
@@ -424,7 +468,7 @@ func (p *Parser) parseParameters() ([]ast.Expression, error) {
 	if len(list) > MaxArity {
 		return nil, p.parseError("too many parameters given to function")
 	}
-	if err := p.expect(token.Rparen, "expected ')"); err != nil {
+	if err := p.expect(token.Rparen, "expected ')'"); err != nil {
 		return nil, err
 	}
 	p.next()
@@ -433,12 +477,14 @@ func (p *Parser) parseParameters() ([]ast.Expression, error) {
 
 func (p *Parser) parseFunction() (ast.Expression, error) {
 	var (
-		tok = p.curr
-		fn  = ast.CreateFunction(tok, p.peek.Literal)
+		fn  = ast.CreateFunction(p.curr, "")
 		err error
 	)
 	p.next()
-	p.next()
+	if p.is(token.Ident) {
+		fn.Ident = p.curr.Literal
+		p.next()
+	}
 	if fn.Params, err = p.parseParameters(); err != nil {
 		return nil, err
 	}
@@ -453,23 +499,30 @@ func (p *Parser) parseBlock() (ast.Expression, error) {
 		tok  = p.curr
 		list []ast.Expression
 	)
-	if err := p.expect(token.Lcurly, "expected '{"); err != nil {
+	if err := p.expect(token.Lcurly, "expected '{'"); err != nil {
 		return nil, err
 	}
 	p.next()
 	p.skip(token.EOL)
 	for !p.is(token.Rcurly) && !p.done() {
 		e, err := p.parse(powLowest)
-		if err != nil {
-			return nil, err
+		if err == nil {
+			list = append(list, e)
+			if p.is(token.Rcurly) {
+				break
+			}
+			err = p.expect(token.EOL, "expected newline or ';'")
 		}
-		list = append(list, e)
-		if err := p.expect(token.EOL, "expected newline or ';'"); err != nil {
-			return nil, err
+		if err != nil {
+			if !p.recover(err, true) {
+				return nil, err
+			}
+			continue
 		}
 		p.next()
+		p.skip(token.EOL)
 	}
-	if err := p.expect(token.Rcurly, "expected '}"); err != nil {
+	if err := p.expect(token.Rcurly, "expected '}'"); err != nil {
 		return nil, err
 	}
 	p.next()
@@ -493,7 +546,7 @@ func (p *Parser) parseIf() (ast.Expression, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := p.expectKW(token.KwElse, ""); err == nil {
+	if e := p.expectKW(token.KwElse, ""); e == nil {
 		p.next()
 		switch p.curr.Type {
 		case token.Lcurly:
@@ -502,6 +555,9 @@ func (p *Parser) parseIf() (ast.Expression, error) {
 			expr.Alt, err = p.parseKeyword()
 		default:
 		}
+		if err != nil {
+			return nil, err
+		}
 	}
 	if !p.is(token.EOL) && !p.is(token.EOF) {
 		return nil, p.parseError("expected newline or ';'")
@@ -545,6 +601,62 @@ func (p *Parser) parseReturn() (ast.Expression, error) {
 	return ast.CreateReturn(tok, right), nil
 }
 
+func (p *Parser) parseTry() (ast.Expression, error) {
+	var (
+		expr ast.Try
+		err  error
+	)
+	expr.Token = p.curr
+	p.next()
+	if expr.Body, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
+	if err := p.expectKW(token.KwCatch, ""); err == nil {
+		p.next()
+		if p.is(token.Lparen) {
+			p.next()
+			if err := p.expect(token.Ident, "expected identifier"); err != nil {
+				return nil, err
+			}
+			expr.Ident = p.curr.Literal
+			p.next()
+			if err := p.expect(token.Rparen, "expected ')'"); err != nil {
+				return nil, err
+			}
+			p.next()
+		}
+		if expr.Catch, err = p.parseBlock(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKW(token.KwFinally, ""); err == nil {
+		p.next()
+		if expr.Finally, err = p.parseBlock(); err != nil {
+			return nil, err
+		}
+	}
+	if expr.Catch == nil && expr.Finally == nil {
+		return nil, p.parseError("expected 'catch' or 'finally' keyword")
+	}
+	if !p.is(token.EOL) && !p.is(token.EOF) {
+		return nil, p.parseError("expected newline or ';'")
+	}
+	return expr, nil
+}
+
+func (p *Parser) parseRaise() (ast.Expression, error) {
+	tok := p.curr
+	p.next()
+	if p.is(token.EOL) || p.is(token.EOF) {
+		return nil, p.parseError("expected expression after 'raise'")
+	}
+	right, err := p.parse(powLowest)
+	if err != nil {
+		return nil, err
+	}
+	return ast.CreateRaise(tok, right), nil
+}
+
 func (p *Parser) parseBreak() (ast.Expression, error) {
 	defer p.next()
 	return ast.Break{Token: p.curr}, nil
@@ -583,13 +695,40 @@ func (p *Parser) parsePath(left ast.Expression) (ast.Expression, error) {
 		return nil, p.parseError("unexpected path operator")
 	}
 	p.next()
-	right, err := p.parse(powLowest)
+	right, err := p.parse(powPrefix)
 	if err != nil {
 		return nil, err
 	}
 	return ast.CreatePath(tok, v.Ident, right), nil
 }
 
+func (p *Parser) parseChain(left ast.Expression) (ast.Expression, error) {
+	expr := ast.Chain{
+		Token: p.curr,
+		Left:  left,
+	}
+	p.next()
+	switch p.curr.Type {
+	case token.Ident:
+		expr.Key = ast.CreateLiteral(p.curr, p.curr.Literal)
+		p.next()
+	case token.Lsquare:
+		p.next()
+		key, err := p.parse(powLowest)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(token.Rsquare, "expected ']'"); err != nil {
+			return nil, err
+		}
+		p.next()
+		expr.Key = key
+	default:
+		return nil, p.parseError("expected identifier or '[' after '?.'")
+	}
+	return expr, nil
+}
+
 func (p *Parser) parseAssign(left ast.Expression) (ast.Expression, error) {
 	var (
 		tok = p.curr
@@ -658,7 +797,7 @@ func (p *Parser) parseSlice(left ast.Expression) (ast.Expression, error) {
 		err  error
 	)
 	p.next()
-	if !p.is(token.Colon) {
+	if !p.is(token.Colon) && !p.endSlice() {
 		expr.End, err = p.parse(powLowest)
 		if err != nil {
 			return nil, err
@@ -666,11 +805,17 @@ func (p *Parser) parseSlice(left ast.Expression) (ast.Expression, error) {
 	}
 	if p.is(token.Colon) {
 		p.next()
-		expr.Step, err = p.parse(powLowest)
+		if !p.endSlice() {
+			expr.Step, err = p.parse(powLowest)
+		}
 	}
 	return expr, err
 }
 
+func (p *Parser) endSlice() bool {
+	return p.is(token.Rsquare) || p.is(token.Comma)
+}
+
 func (p *Parser) parseIndex(left ast.Expression) (ast.Expression, error) {
 	var (
 		tok = p.curr
@@ -704,7 +849,7 @@ func (p *Parser) parseIndex(left ast.Expression) (ast.Expression, error) {
 			p.next()
 		case token.Rsquare:
 		default:
-			return nil, p.parseError("expected ',' or ']")
+			return nil, p.parseError("expected ',' or ']'")
 		}
 		ix.List = append(ix.List, expr)
 	}
@@ -738,6 +883,7 @@ func (p *Parser) parseCompitem(until rune) ([]ast.CompItem, error) {
 			return nil, err
 		}
 		item.Iter = expr
+		p.skip(token.EOL)
 		for p.is(token.Keyword) && p.curr.Literal == token.KwIf {
 			p.next()
 			expr, err := p.parse(powLowest)
@@ -745,6 +891,7 @@ func (p *Parser) parseCompitem(until rune) ([]ast.CompItem, error) {
 				return nil, err
 			}
 			item.Cdt = append(item.Cdt, expr)
+			p.skip(token.EOL)
 		}
 		switch p.curr.Type {
 		case token.Keyword:
@@ -784,11 +931,13 @@ func (p *Parser) parseArray() (ast.Expression, error) {
 	)
 	arr.Token = tok
 	p.next()
+	p.skip(token.EOL)
 	for !p.is(token.Rsquare) && !p.done() {
 		e, err := p.parse(powLowest)
 		if err != nil {
 			return nil, err
 		}
+		p.skip(token.EOL)
 		if err := p.expectKW(token.KwFor, ""); len(arr.List) == 0 && err == nil {
 			return p.parseListcomp(e)
 		}
@@ -799,7 +948,7 @@ func (p *Parser) parseArray() (ast.Expression, error) {
 			p.skip(token.EOL)
 		case token.Rsquare:
 		default:
-			return nil, p.parseError("expected ',' or ']")
+			return nil, p.parseError("expected ',' or ']'")
 		}
 	}
 	if err := p.expect(token.Rsquare, "expected ']'"); err != nil {
@@ -830,6 +979,7 @@ func (p *Parser) parseDict() (ast.Expression, error) {
 	d.Token = tok
 	p.next()
 	d.List = make(map[ast.Expression]ast.Expression)
+	p.skip(token.EOL)
 	for !p.is(token.Rcurly) && !p.done() {
 		k, err := p.parse(powLowest)
 		if err != nil {
@@ -843,6 +993,7 @@ func (p *Parser) parseDict() (ast.Expression, error) {
 		if err != nil {
 			return nil, err
 		}
+		p.skip(token.EOL)
 		if err := p.expectKW(token.KwFor, ""); len(d.List) == 0 && err == nil {
 			return p.parseDictcomp(k, v)
 		}
@@ -853,7 +1004,7 @@ func (p *Parser) parseDict() (ast.Expression, error) {
 			p.skip(token.EOL)
 		case token.Rcurly:
 		default:
-			return nil, p.parseError("expected ',' or '}")
+			return nil, p.parseError("expected ',' or '}'")
 		}
 	}
 	if err := p.expect(token.Rcurly, "expected '}'"); err != nil {
@@ -870,7 +1021,7 @@ func (p *Parser) parseUnary() (ast.Expression, error) {
 	)
 	p.next()
 
-	right, err := p.parse(powLowest)
+	right, err := p.parse(powAdd)
 	if err != nil {
 		return nil, err
 	}
@@ -887,6 +1038,99 @@ func (p *Parser) parseLiteral() (ast.Expression, error) {
 	return ast.CreateLiteral(p.curr, p.curr.Literal), nil
 }
 
+func (p *Parser) parseTemplate() (ast.Expression, error) {
+	var (
+		tpl   = ast.Template{Token: p.curr}
+		str   = p.curr.Literal[1:]
+		pos   = p.curr.Position
+		quote = str[:1]
+		size  = 1
+		buf   strings.Builder
+	)
+	if len(str) >= 6 && strings.HasPrefix(str, strings.Repeat(quote, 3)) {
+		size = 3
+	}
+	str = str[size : len(str)-size]
+	pos.Column += 1 + size
+
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		lit, err := scan.Unescape(buf.String())
+		if err != nil {
+			return p.parseError(err.Error())
+		}
+		tpl.List = append(tpl.List, ast.CreateLiteral(p.curr, lit))
+		buf.Reset()
+		return nil
+	}
+	for i := 0; i < len(str); {
+		switch c := str[i]; {
+		case c == '{' && strings.HasPrefix(str[i:], "{{"):
+			buf.WriteByte(c)
+			i += 2
+		case c == '}' && strings.HasPrefix(str[i:], "}}"):
+			buf.WriteByte(c)
+			i += 2
+		case c == '}':
+			return nil, p.parseError("single '}' is not allowed in template")
+		case c == '\\' && i+1 < len(str):
+			buf.WriteString(str[i : i+2])
+			i += 2
+		case c == '{':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			end := placeholderEnd(str, i+1)
+			if end < 0 {
+				return nil, p.parseError("unterminated placeholder in template")
+			}
+			var (
+				tok        = token.Token{Position: advance(pos, str[:i+1])}
+				expr, spec = splitPlaceholder(str[i+1 : end])
+			)
+			if strings.TrimSpace(expr) == "" {
+				return nil, p.parseError("empty placeholder in template")
+			}
+			e, err := p.parseEmbedded(expr, tok.Position)
+			if err != nil {
+				return nil, err
+			}
+			tpl.List = append(tpl.List, ast.Placeholder{
+				Token: tok,
+				Expr:  e,
+				Spec:  spec,
+			})
+			i = end + 1
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	p.next()
+	return tpl, nil
+}
+
+func (p *Parser) parseEmbedded(str string, pos token.Position) (ast.Expression, error) {
+	sub := create(scan.ScanFrom(strings.NewReader(str), pos), p.file)
+	expr, err := sub.parse(powLowest)
+	if err == nil && sub.errors.Size() > 0 {
+		err = sub.errors[0]
+	}
+	if err == nil && !sub.done() {
+		err = sub.parseError("unexpected token in placeholder")
+	}
+	if perr, ok := err.(ParseError); ok {
+		perr.Line = p.scan.CurrentLine(perr.Position)
+		err = perr
+	}
+	return expr, err
+}
+
 func (p *Parser) parseInteger() (ast.Expression, error) {
 	n, err := strconv.ParseInt(p.curr.Literal, 0, 64)
 	if err != nil {
@@ -914,20 +1158,31 @@ func (p *Parser) parseBoolean() (ast.Expression, error) {
 	return ast.CreateBoolean(p.curr, b), nil
 }
 
+func (p *Parser) parseNil() (ast.Expression, error) {
+	defer p.next()
+	return ast.CreateNil(p.curr), nil
+}
+
 func (p *Parser) parseIdentifier() (ast.Expression, error) {
 	defer p.next()
 	return ast.CreateVariable(p.curr, p.curr.Literal), nil
 }
 
 func (p *Parser) parseCall(left ast.Expression) (ast.Expression, error) {
-	v, ok := left.(ast.Variable)
-	if !ok {
+	expr := ast.Call{
+		Token: p.curr,
+	}
+	switch e := left.(type) {
+	case ast.Variable:
+		expr.Token = e.Token
+		expr.Ident = e.Ident
+	case ast.Path, ast.Literal, ast.Integer, ast.Double, ast.Boolean:
 		return nil, p.parseError("unexpected call operator")
+	default:
+		expr.Expr = left
 	}
 	p.next()
-	expr := ast.Call{
-		Ident: v.Ident,
-	}
+	p.skip(token.EOL)
 	for !p.is(token.Rparen) && !p.done() {
 		if p.peekIs(token.Assign) {
 			break
@@ -937,12 +1192,14 @@ func (p *Parser) parseCall(left ast.Expression) (ast.Expression, error) {
 			return nil, err
 		}
 		expr.Args = append(expr.Args, e)
+		p.skip(token.EOL)
 		switch p.curr.Type {
 		case token.Comma:
 			if p.peekIs(token.Rparen) {
 				return nil, p.parseError("unexpected ',' before ')")
 			}
 			p.next()
+			p.skip(token.EOL)
 		case token.Rparen:
 		default:
 			return nil, p.parseError("expected ','")
@@ -964,12 +1221,14 @@ func (p *Parser) parseCall(left ast.Expression) (ast.Expression, error) {
 		}
 		a.Expr = val
 		expr.Args = append(expr.Args, a)
+		p.skip(token.EOL)
 		switch p.curr.Type {
 		case token.Comma:
 			if p.peekIs(token.Rparen) {
 				return nil, p.parseError("unexpected ',' before ')")
 			}
 			p.next()
+			p.skip(token.EOL)
 		case token.Rparen:
 		default:
 			return nil, p.parseError("expected ','")
@@ -1068,13 +1327,88 @@ func (p *Parser) done() bool {
 func (p *Parser) next() {
 	p.curr = p.peek
 	p.peek = p.scan.Scan()
+	for p.peek.Type == token.Comment {
+		p.peek = p.scan.Scan()
+	}
 }
 
 func (p *Parser) parseError(message string) error {
+	tok := p.curr
+	if tok.Type == token.Error {
+		message, tok.Literal = tok.Literal, ""
+	}
 	return ParseError{
-		Token:   p.curr,
+		Token:   tok,
 		File:    p.file,
 		Line:    p.scan.CurrentLine(p.curr.Position),
 		Message: message,
 	}
 }
+
+func placeholderEnd(str string, pos int) int {
+	var depth int
+	for i := pos; i < len(str); i++ {
+		switch c := str[i]; c {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case '"', '\'', '`':
+			if i = quotedEnd(str, i); i < 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func splitPlaceholder(str string) (string, string) {
+	var depth int
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '"', '\'', '`':
+			if i = quotedEnd(str, i); i < 0 {
+				return str, ""
+			}
+		case ':':
+			if depth == 0 {
+				return str[:i], str[i+1:]
+			}
+		}
+	}
+	return str, ""
+}
+
+func quotedEnd(str string, pos int) int {
+	quote := str[pos]
+	for i := pos + 1; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func advance(pos token.Position, str string) token.Position {
+	for _, c := range str {
+		if c == '\n' {
+			pos.Line++
+			pos.Column = 1
+			continue
+		}
+		pos.Column++
+	}
+	return pos
+}
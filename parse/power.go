@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"github.com/midbel/buddy/ast"
 	"github.com/midbel/buddy/token"
 )
 
@@ -8,6 +9,7 @@ const (
 	powLowest   = iota
 	powAssign   // =
 	powTernary  // ?:
+	powNullish  // ??
 	powBinary   // &, |, ^, ~
 	powRelation // &&, ||
 	powShift    // <<, >>
@@ -56,6 +58,8 @@ var powers = powerMap{
 	token.BinXorAssign: powAssign,
 	token.Lparen:       powCall,
 	token.Ternary:      powTernary,
+	token.Nullish:      powNullish,
+	token.OptChain:     powDot,
 	token.And:          powRelation,
 	token.Or:           powRelation,
 	token.Eq:           powEqual,
@@ -67,3 +71,23 @@ var powers = powerMap{
 	token.Lsquare:      powIndex,
 	token.Dot:          powDot,
 }
+
+func Power(expr ast.Expression) int {
+	switch e := expr.(type) {
+	case ast.Binary:
+		return powers.Get(e.Op)
+	case ast.Assign:
+		return powAssign
+	case ast.Test:
+		if e.Type == token.Ternary {
+			return powTernary
+		}
+	case ast.Unary:
+		return powAdd
+	case ast.Index:
+		return powIndex
+	case ast.Call:
+		return powCall
+	}
+	return powDot
+}
@@ -2,7 +2,10 @@ package scan
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/midbel/buddy/token"
@@ -28,6 +31,13 @@ func Scan(r io.Reader) *Scanner {
 	return &x
 }
 
+func ScanFrom(r io.Reader, pos token.Position) *Scanner {
+	x := Scan(r)
+	x.Line = pos.Line
+	x.Column = pos.Column - 1
+	return x
+}
+
 func (s *Scanner) CurrentLine(pos token.Position) string {
 	var start int
 	for i := 0; i < pos.Line-1; i++ {
@@ -64,6 +74,8 @@ func (s *Scanner) Scan() token.Token {
 		s.scanNumber(&tok)
 	case isOperator(s.char):
 		s.scanOperator(&tok)
+	case s.char == 'f' && isTemplateQuote(s.peek()):
+		s.scanTemplate(&tok)
 	case isLetter(s.char):
 		s.scanIdent(&tok)
 	case isQuote(s.char):
@@ -89,7 +101,7 @@ func (s *Scanner) scanComment(tok *token.Token) {
 	s.read()
 	s.skipBlank()
 	pos := s.curr
-	for !accept() {
+	for !accept() && !s.done() {
 		s.read()
 	}
 	tok.Type = token.Comment
@@ -98,20 +110,212 @@ func (s *Scanner) scanComment(tok *token.Token) {
 		s.read()
 		s.read()
 	}
-	s.skipNL()
+	if !s.done() {
+		s.unread()
+	}
 }
 
 func (s *Scanner) scanLiteral(tok *token.Token) {
 	quote := s.char
+	if quote == backtick {
+		s.scanRaw(tok)
+		return
+	}
+	var (
+		str   strings.Builder
+		multi = s.peek() == quote && s.peekAt(1) == quote
+		err   *token.Token
+	)
+	if multi {
+		s.read()
+		s.read()
+		if s.peek() == nl {
+			s.read()
+		}
+	}
+	s.read()
+	for !s.done() {
+		if s.char == quote && (!multi || s.peek() == quote && s.peekAt(1) == quote) {
+			break
+		}
+		if isNL(s.char) && !multi {
+			break
+		}
+		if s.char != backslash {
+			str.WriteRune(s.char)
+			s.read()
+			continue
+		}
+		if e := s.scanEscape(&str); e != nil && err == nil {
+			err = e
+		}
+	}
+	if s.char != quote {
+		tok.Type = token.Error
+		tok.Literal = "unterminated string"
+		return
+	}
+	if multi {
+		s.read()
+		s.read()
+	}
+	if err != nil {
+		*tok = *err
+		return
+	}
+	tok.Type = token.Literal
+	tok.Literal = str.String()
+}
+
+func (s *Scanner) scanTemplate(tok *token.Token) {
+	var (
+		pos   = s.curr
+		quote = s.peek()
+		multi = s.peekAt(1) == quote && s.peekAt(2) == quote
+		depth int
+	)
+	s.read()
+	if multi {
+		s.read()
+		s.read()
+	}
+	s.read()
+	for !s.done() {
+		if depth == 0 && s.char == quote && (!multi || s.peek() == quote && s.peekAt(1) == quote) {
+			break
+		}
+		if isNL(s.char) && !multi {
+			break
+		}
+		switch {
+		case s.char == backslash:
+			s.read()
+		case s.char == lcurly && depth == 0 && s.peek() == lcurly:
+			s.read()
+		case s.char == lcurly:
+			depth++
+		case s.char == rcurly && depth > 0:
+			depth--
+		case depth > 0 && isQuote(s.char):
+			s.skipQuoted(multi)
+		}
+		s.read()
+	}
+	if s.char != quote {
+		tok.Type = token.Error
+		tok.Literal = "unterminated string"
+		return
+	}
+	if multi {
+		s.read()
+		s.read()
+	}
+	tok.Type = token.Template
+	tok.Literal = string(s.input[pos:s.next])
+}
+
+func (s *Scanner) skipQuoted(multi bool) {
+	quote := s.char
+	for s.read(); s.char != quote && !s.done(); s.read() {
+		if isNL(s.char) && !multi {
+			return
+		}
+		if s.char == backslash && quote != backtick {
+			s.read()
+		}
+	}
+}
+
+func (s *Scanner) scanRaw(tok *token.Token) {
 	s.read()
 	pos := s.curr
-	for s.char != quote && !s.done() {
+	for s.char != backtick && !s.done() {
 		s.read()
 	}
+	if s.done() {
+		tok.Type = token.Error
+		tok.Literal = "unterminated string"
+		return
+	}
 	tok.Type = token.Literal
 	tok.Literal = string(s.input[pos:s.curr])
 }
 
+func (s *Scanner) scanEscape(str *strings.Builder) *token.Token {
+	var (
+		pos  = s.Position
+		size int
+	)
+	s.read()
+	switch s.char {
+	case 'n':
+		str.WriteRune(nl)
+	case 't':
+		str.WriteRune(tab)
+	case 'r':
+		str.WriteRune(cr)
+	case 'a':
+		str.WriteRune('\a')
+	case 'b':
+		str.WriteRune('\b')
+	case 'f':
+		str.WriteRune('\f')
+	case 'v':
+		str.WriteRune('\v')
+	case '0':
+		str.WriteRune(0)
+	case backslash, squote, dquote, nl:
+		if s.char != nl {
+			str.WriteRune(s.char)
+		}
+	case 'x':
+		size = 2
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		tok := token.Token{
+			Type:     token.Error,
+			Literal:  fmt.Sprintf("invalid escape sequence \\%c", s.char),
+			Position: pos,
+		}
+		if !s.done() {
+			s.read()
+		}
+		return &tok
+	}
+	s.read()
+	if size == 0 {
+		return nil
+	}
+	var char rune
+	for i := 0; i < size; i++ {
+		if !isHex(s.char) {
+			return &token.Token{
+				Type:     token.Error,
+				Literal:  fmt.Sprintf("invalid escape sequence: expected %d hexadecimal digits", size),
+				Position: pos,
+			}
+		}
+		char = char<<4 | hexValue(s.char)
+		s.read()
+	}
+	if size > 2 && !utf8.ValidRune(char) {
+		return &token.Token{
+			Type:     token.Error,
+			Literal:  fmt.Sprintf("invalid escape sequence: %U is not a valid code point", char),
+			Position: pos,
+		}
+	}
+	if size == 2 {
+		str.WriteByte(byte(char))
+	} else {
+		str.WriteRune(char)
+	}
+	return nil
+}
+
 func (s *Scanner) scanIdent(tok *token.Token) {
 	defer s.unread()
 	pos := s.curr
@@ -293,6 +497,13 @@ func (s *Scanner) scanOperator(tok *token.Token) {
 		tok.Type = token.EOL
 	case question:
 		tok.Type = token.Ternary
+		if k := s.peek(); k == question {
+			tok.Type = token.Nullish
+			s.read()
+		} else if k == dot {
+			tok.Type = token.OptChain
+			s.read()
+		}
 	case colon:
 		tok.Type = token.Colon
 	default:
@@ -309,8 +520,18 @@ func (s *Scanner) peek() rune {
 	return r
 }
 
+func (s *Scanner) peekAt(n int) rune {
+	ptr := s.next
+	for ; n > 0 && ptr < len(s.input); n-- {
+		_, size := utf8.DecodeRune(s.input[ptr:])
+		ptr += size
+	}
+	r, _ := utf8.DecodeRune(s.input[ptr:])
+	return r
+}
+
 func (s *Scanner) read() {
-	if s.curr >= len(s.input) || s.char == utf8.RuneError {
+	if s.done() {
 		return
 	}
 	if s.char == nl {
@@ -378,6 +599,8 @@ const (
 	dot             = '.'
 	squote          = '\''
 	dquote          = '"'
+	backtick        = '`'
+	backslash       = '\\'
 	underscore      = '_'
 	question        = '?'
 	bang            = '!'
@@ -434,6 +657,10 @@ func isAlpha(r rune) bool {
 }
 
 func isQuote(r rune) bool {
+	return r == squote || r == dquote || r == backtick
+}
+
+func isTemplateQuote(r rune) bool {
 	return r == squote || r == dquote
 }
 
@@ -468,3 +695,38 @@ func isOctal(r rune) bool {
 func isHex(r rune) bool {
 	return isDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
 }
+
+func hexValue(r rune) rune {
+	switch {
+	case isDigit(r):
+		return r - '0'
+	case r >= 'a' && r <= 'f':
+		return r - 'a' + 10
+	default:
+		return r - 'A' + 10
+	}
+}
+
+func Unescape(str string) (string, error) {
+	if str == "" {
+		return str, nil
+	}
+	var (
+		in = Scanner{
+			input: []byte(str),
+		}
+		buf strings.Builder
+	)
+	in.read()
+	for !in.done() {
+		if in.char != backslash {
+			buf.WriteRune(in.char)
+			in.read()
+			continue
+		}
+		if tok := in.scanEscape(&buf); tok != nil {
+			return "", errors.New(tok.Literal)
+		}
+	}
+	return buf.String(), nil
+}
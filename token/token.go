@@ -21,6 +21,11 @@ const (
 	KwIn       = "in"
 	KwAssert   = "assert"
 	KwLet      = "let"
+	KwTry      = "try"
+	KwCatch    = "catch"
+	KwFinally  = "finally"
+	KwRaise    = "raise"
+	KwNil      = "nil"
 )
 
 func IsKeyword(str string) bool {
@@ -39,6 +44,11 @@ func IsKeyword(str string) bool {
 	case KwIn:
 	case KwAssert:
 	case KwLet:
+	case KwTry:
+	case KwCatch:
+	case KwFinally:
+	case KwRaise:
+	case KwNil:
 	default:
 		return false
 	}
@@ -47,8 +57,10 @@ func IsKeyword(str string) bool {
 
 const (
 	Invalid rune = -(iota + 1)
+	Error
 	Keyword
 	Literal
+	Template
 	Ident
 	Boolean
 	Variable
@@ -94,6 +106,8 @@ const (
 	Ne
 	Assign
 	Ternary
+	Nullish
+	OptChain
 	Not
 	And
 	Or
@@ -116,6 +130,10 @@ type Token struct {
 	Position
 }
 
+func (t Token) Pos() Position {
+	return t.Position
+}
+
 func (t Token) String() string {
 	var prefix string
 	switch t.Type {
@@ -123,8 +141,12 @@ func (t Token) String() string {
 		prefix = "unknown"
 	case Invalid:
 		prefix = "invalid"
+	case Error:
+		prefix = "error"
 	case Literal:
 		prefix = "literal"
+	case Template:
+		prefix = "template"
 	case Integer:
 		prefix = "integer"
 	case Double:
@@ -223,6 +245,10 @@ func (t Token) String() string {
 		return "<assign>"
 	case Ternary:
 		return "<ternary>"
+	case Nullish:
+		return "<nullish>"
+	case OptChain:
+		return "<optional-chain>"
 	case Not:
 		return "<not>"
 	}
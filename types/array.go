@@ -163,21 +163,66 @@ func (a Array) Get(ix Primitive) (Primitive, error) {
 	return a.values[x], nil
 }
 
+func (a Array) Slice(start, end, step Primitive) (Primitive, error) {
+	beg, fin, inc, err := sliceBounds(len(a.values), start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	var list []Primitive
+	for _, x := range sliceRange(beg, fin, inc) {
+		list = append(list, a.values[x])
+	}
+	return CreateArray(list), nil
+}
+
+func (a Array) SetSlice(start, end, step, value Primitive) (Primitive, error) {
+	beg, fin, inc, err := sliceBounds(len(a.values), start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	it, ok := value.(Iterable)
+	if !ok {
+		return nil, IterationError(value)
+	}
+	var list []Primitive
+	it.Iter(func(p Primitive) error {
+		list = append(list, p)
+		return nil
+	})
+	if inc == 1 {
+		if fin < beg {
+			fin = beg
+		}
+		vs := make([]Primitive, 0, len(a.values)-(fin-beg)+len(list))
+		vs = append(vs, a.values[:beg]...)
+		vs = append(vs, list...)
+		vs = append(vs, a.values[fin:]...)
+		a.values = vs
+		return a, nil
+	}
+	ixs := sliceRange(beg, fin, inc)
+	if len(ixs) != len(list) {
+		return nil, fmt.Errorf("can not assign %d values to slice of size %d", len(list), len(ixs))
+	}
+	vs := make([]Primitive, len(a.values))
+	copy(vs, a.values)
+	for i, x := range ixs {
+		vs[x] = list[i]
+	}
+	a.values = vs
+	return a, nil
+}
+
 func (a Array) getIndex(ix Primitive) (int, error) {
-	var x int
-	switch p := ix.(type) {
-	case Int:
-		x = int(p.value)
-	case Float:
-		x = int(p.value)
-	default:
-		return x, fmt.Errorf("%T can not be used as index", ix)
+	x, err := toIndex(ix)
+	if err != nil {
+		return x, err
 	}
 	if x < 0 {
 		x = len(a.values) + x
 	}
 	if x < 0 || x >= len(a.values) {
-		return x, fmt.Errorf("index out of range")
+		return x, ErrIndex
 	}
 	return x, nil
 }
@@ -112,7 +112,7 @@ func (d Dict) Set(ix, value Primitive) (Primitive, error) {
 func (d Dict) Get(ix Primitive) (Primitive, error) {
 	p, ok := d.values[ix]
 	if !ok {
-		return nil, fmt.Errorf("%s: key not found", ix)
+		return nil, fmt.Errorf("%s: %w", ix, ErrKey)
 	}
 	return p, nil
 }
@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"sort"
 )
 
 type Context interface {
@@ -52,22 +53,30 @@ func EmptyEnv() *Environ {
 func EnclosedEnv(parent *Environ) *Environ {
 	return &Environ{
 		parent: parent,
-		values: make(map[string]value),
 	}
 }
 
 func (e *Environ) Resolve(name string) (Primitive, error) {
-	v, ok := e.values[name]
+	v, ok := e.Value(name)
 	if !ok {
-		if e.parent == nil {
-			return nil, fmt.Errorf("%s undefined variable", name)
+		return nil, fmt.Errorf("%s undefined variable", name)
+	}
+	return v, nil
+}
+
+func (e *Environ) Value(name string) (Primitive, bool) {
+	for ; e != nil; e = e.parent {
+		if v, ok := e.values[name]; ok {
+			return v.value, true
 		}
-		return e.parent.Resolve(name)
 	}
-	return v.value, nil
+	return nil, false
 }
 
 func (e *Environ) Assign(ident string, value Primitive) error {
+	if value == nil {
+		value = CreateNil()
+	}
 	v, ok := e.values[ident]
 	if !ok {
 		if e.parent != nil {
@@ -84,10 +93,16 @@ func (e *Environ) Assign(ident string, value Primitive) error {
 }
 
 func (e *Environ) Define(ident string, value Primitive) error {
+	if value == nil {
+		value = CreateNil()
+	}
 	v, ok := e.values[ident]
 	if ok {
 		return fmt.Errorf("%s: variable already defined", ident)
 	}
+	if e.values == nil {
+		e.values = emptyValues()
+	}
 	v.value = value
 	e.values[ident] = v
 	return nil
@@ -98,6 +113,24 @@ func (e *Environ) Exists(ident string) bool {
 	return ok && v.value != nil
 }
 
+func (e *Environ) Names() []string {
+	var (
+		list []string
+		seen = make(map[string]struct{})
+	)
+	for ; e != nil; e = e.parent {
+		for n := range e.values {
+			if _, ok := seen[n]; ok {
+				continue
+			}
+			seen[n] = struct{}{}
+			list = append(list, n)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
 func (e *Environ) Wrap() *Environ {
 	return EnclosedEnv(e)
 }
@@ -109,6 +142,10 @@ func (e *Environ) Unwrap() *Environ {
 	return e.parent
 }
 
+func emptyValues() map[string]value {
+	return make(map[string]value)
+}
+
 type value struct {
 	value    Primitive
 	readonly bool
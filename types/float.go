@@ -83,6 +83,8 @@ func (f Float) Mul(other Primitive) (Primitive, error) {
 		f.value *= float64(x.value)
 	case Float:
 		f.value *= x.value
+	case Duration:
+		return x.Mul(f)
 	default:
 		return nil, incompatibleType("multiply", f, other)
 	}
@@ -0,0 +1,48 @@
+package types
+
+type Function struct {
+	name string
+	call Callable
+}
+
+func CreateFunction(name string, call Callable) Primitive {
+	if name == "" {
+		name = "lambda"
+	}
+	return Function{
+		name: name,
+		call: call,
+	}
+}
+
+func (f Function) Name() string {
+	return f.name
+}
+
+func (f Function) Raw() any {
+	return f.call
+}
+
+func (f Function) String() string {
+	return "<function " + f.name + ">"
+}
+
+func (f Function) True() bool {
+	return true
+}
+
+func (f Function) Not() (Primitive, error) {
+	return CreateBool(false), nil
+}
+
+func (f Function) Rev() (Primitive, error) {
+	return nil, unsupportedOp("reverse", f)
+}
+
+func (f Function) Call(ctx Context, args []Argument) (Primitive, error) {
+	return f.call.Call(ctx, args)
+}
+
+func (f Function) Arity() int {
+	return f.call.Arity()
+}
@@ -87,6 +87,8 @@ func (i Int) Mul(other Primitive) (Primitive, error) {
 	case Float:
 		f := float64(i.value) * x.value
 		return Float{value: f}, nil
+	case Duration:
+		return x.Mul(i)
 	default:
 		return nil, incompatibleType("multiply", i, other)
 	}
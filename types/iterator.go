@@ -0,0 +1,49 @@
+package types
+
+type NextFunc func() (Primitive, bool, error)
+
+type Iterator struct {
+	next NextFunc
+}
+
+func CreateIterator(next NextFunc) Primitive {
+	return Iterator{
+		next: next,
+	}
+}
+
+func (i Iterator) Next() (Primitive, bool, error) {
+	return i.next()
+}
+
+func (i Iterator) Iter(do func(Primitive) error) error {
+	for {
+		p, ok, err := i.next()
+		if err != nil || !ok {
+			return err
+		}
+		if err := do(p); err != nil {
+			return err
+		}
+	}
+}
+
+func (i Iterator) Raw() any {
+	return nil
+}
+
+func (i Iterator) String() string {
+	return "<iterator>"
+}
+
+func (i Iterator) True() bool {
+	return true
+}
+
+func (i Iterator) Not() (Primitive, error) {
+	return CreateBool(false), nil
+}
+
+func (i Iterator) Rev() (Primitive, error) {
+	return nil, unsupportedOp("reverse", i)
+}
@@ -0,0 +1,43 @@
+package types
+
+type Nil struct{}
+
+func CreateNil() Primitive {
+	return Nil{}
+}
+
+func IsNil(p Primitive) bool {
+	if p == nil {
+		return true
+	}
+	_, ok := p.(Nil)
+	return ok
+}
+
+func (n Nil) Raw() any {
+	return nil
+}
+
+func (n Nil) String() string {
+	return "nil"
+}
+
+func (n Nil) True() bool {
+	return false
+}
+
+func (n Nil) Not() (Primitive, error) {
+	return CreateBool(true), nil
+}
+
+func (n Nil) Rev() (Primitive, error) {
+	return nil, unsupportedOp("reverse", n)
+}
+
+func (n Nil) Eq(other Primitive) (Primitive, error) {
+	return CreateBool(IsNil(other)), nil
+}
+
+func (n Nil) Ne(other Primitive) (Primitive, error) {
+	return CreateBool(!IsNil(other)), nil
+}
@@ -0,0 +1,330 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	primitiveType = reflect.TypeOf((*Primitive)(nil)).Elem()
+	callableType  = reflect.TypeOf((*Callable)(nil)).Elem()
+)
+
+const tagName = "buddy"
+
+func ValueOf(value any) (Primitive, error) {
+	if value == nil {
+		return CreateNil(), nil
+	}
+	return valueOf(reflect.ValueOf(value))
+}
+
+func valueOf(v reflect.Value) (Primitive, error) {
+	if !v.IsValid() {
+		return CreateNil(), nil
+	}
+	if v.Type().Implements(primitiveType) {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			return CreateNil(), nil
+		}
+		return v.Interface().(Primitive), nil
+	}
+	switch v.Type() {
+	case timeType:
+		return CreateTime(v.Interface().(time.Time)), nil
+	case durationType:
+		return CreateDuration(time.Duration(v.Int())), nil
+	}
+	if v.Type().Implements(callableType) && v.Kind() != reflect.Interface {
+		return CreateFunction("", v.Interface().(Callable)), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return CreateNil(), nil
+		}
+		return valueOf(v.Elem())
+	case reflect.String:
+		return CreateString(v.String()), nil
+	case reflect.Bool:
+		return CreateBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return CreateInt(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return CreateInt(int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return CreateFloat(v.Float()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return CreateNil(), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return CreateString(string(v.Bytes())), nil
+		}
+		return arrayOf(v)
+	case reflect.Array:
+		return arrayOf(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return CreateNil(), nil
+		}
+		return dictOf(v)
+	case reflect.Struct:
+		return structOf(v)
+	default:
+		return nil, fmt.Errorf("%w: %s can not be converted to primitive", ErrIncompatible, v.Type())
+	}
+}
+
+func arrayOf(v reflect.Value) (Primitive, error) {
+	list := make([]Primitive, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		p, err := valueOf(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		list[i] = p
+	}
+	return CreateArray(list), nil
+}
+
+func dictOf(v reflect.Value) (Primitive, error) {
+	dict := Dict{
+		values: make(map[Primitive]Primitive),
+	}
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := valueOf(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		val, err := valueOf(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		dict.values[key] = val
+	}
+	return dict, nil
+}
+
+func structOf(v reflect.Value) (Primitive, error) {
+	dict := Dict{
+		values: make(map[Primitive]Primitive),
+	}
+	for _, f := range reflect.VisibleFields(v.Type()) {
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		val, err := valueOf(v.FieldByIndex(f.Index))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		dict.values[CreateString(name)] = val
+	}
+	return dict, nil
+}
+
+func fieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() || f.Anonymous {
+		return "", false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get(tagName), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	default:
+		return name, true
+	}
+}
+
+func Decode(p Primitive, value any) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("decode: non nil pointer expected")
+	}
+	return DecodeValue(p, v.Elem())
+}
+
+func DecodeValue(p Primitive, v reflect.Value) error {
+	if p == nil {
+		p = CreateNil()
+	}
+	if v.Type().Implements(primitiveType) && v.Kind() == reflect.Interface {
+		v.Set(reflect.ValueOf(p))
+		return nil
+	}
+	switch v.Type() {
+	case timeType:
+		return decodeTime(p, v)
+	case durationType:
+		if d, ok := p.(Duration); ok {
+			v.SetInt(int64(d.value))
+			return nil
+		}
+	}
+	if IsNil(p) {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return DecodeValue(p, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			break
+		}
+		v.Set(reflect.ValueOf(natural(p)))
+		return nil
+	case reflect.String:
+		if s, ok := p.(String); ok {
+			v.SetString(s.str)
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := p.(Bool); ok {
+			v.SetBool(b.value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := p.(Int); ok && !v.OverflowInt(i.value) {
+			v.SetInt(i.value)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := p.(Int); ok && i.value >= 0 && !v.OverflowUint(uint64(i.value)) {
+			v.SetUint(uint64(i.value))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := p.(type) {
+		case Int:
+			v.SetFloat(float64(n.value))
+			return nil
+		case Float:
+			v.SetFloat(n.value)
+			return nil
+		}
+	case reflect.Slice:
+		if s, ok := p.(String); ok && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s.str))
+			return nil
+		}
+		if a, ok := p.(Array); ok {
+			list := reflect.MakeSlice(v.Type(), len(a.values), len(a.values))
+			for i := range a.values {
+				if err := DecodeValue(a.values[i], list.Index(i)); err != nil {
+					return err
+				}
+			}
+			v.Set(list)
+			return nil
+		}
+	case reflect.Array:
+		if a, ok := p.(Array); ok && len(a.values) <= v.Len() {
+			for i := range a.values {
+				if err := DecodeValue(a.values[i], v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if d, ok := p.(Dict); ok {
+			return decodeMap(d, v)
+		}
+	case reflect.Struct:
+		if d, ok := p.(Dict); ok {
+			return decodeStruct(d, v)
+		}
+	}
+	return fmt.Errorf("%w: %s can not be converted to %s", ErrIncompatible, typeName(p), v.Type())
+}
+
+func decodeTime(p Primitive, v reflect.Value) error {
+	var t time.Time
+	switch x := p.(type) {
+	case Time:
+		t = x.value
+	case String:
+		w, err := time.Parse(time.RFC3339Nano, x.str)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrIncompatible, err)
+		}
+		t = w
+	case Int:
+		t = time.Unix(x.value, 0)
+	default:
+		return fmt.Errorf("%w: %s can not be converted to %s", ErrIncompatible, typeName(p), v.Type())
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+func decodeMap(d Dict, v reflect.Value) error {
+	var (
+		typ = v.Type()
+		set = reflect.MakeMapWithSize(typ, len(d.values))
+	)
+	for k, e := range d.values {
+		key := reflect.New(typ.Key()).Elem()
+		if err := DecodeValue(k, key); err != nil {
+			return err
+		}
+		val := reflect.New(typ.Elem()).Elem()
+		if err := DecodeValue(e, val); err != nil {
+			return err
+		}
+		set.SetMapIndex(key, val)
+	}
+	v.Set(set)
+	return nil
+}
+
+func decodeStruct(d Dict, v reflect.Value) error {
+	for _, f := range reflect.VisibleFields(v.Type()) {
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		val, ok := d.values[CreateString(name)]
+		if !ok {
+			continue
+		}
+		if err := DecodeValue(val, v.FieldByIndex(f.Index)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func natural(p Primitive) any {
+	switch p := p.(type) {
+	case Array:
+		list := make([]any, len(p.values))
+		for i := range p.values {
+			list[i] = natural(p.values[i])
+		}
+		return list
+	case Dict:
+		set := make(map[string]any)
+		for k, v := range p.values {
+			set[k.String()] = natural(v)
+		}
+		return set
+	default:
+		return p.Raw()
+	}
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -18,6 +19,16 @@ func (s String) Len() int {
 	return len(s.str)
 }
 
+func (s String) Iter(do func(Primitive) error) error {
+	var err error
+	for _, r := range s.str {
+		if err = do(CreateString(string(r))); err != nil {
+			break
+		}
+	}
+	return err
+}
+
 func (s String) Raw() any {
 	return s.str
 }
@@ -104,6 +115,55 @@ func (s String) Mul(other Primitive) (Primitive, error) {
 	return s, nil
 }
 
+func (s String) Slice(start, end, step Primitive) (Primitive, error) {
+	var (
+		rs                 = []rune(s.str)
+		beg, fin, inc, err = sliceBounds(len(rs), start, end, step)
+	)
+	if err != nil {
+		return nil, err
+	}
+	var str strings.Builder
+	for _, x := range sliceRange(beg, fin, inc) {
+		str.WriteRune(rs[x])
+	}
+	s.str = str.String()
+	return s, nil
+}
+
+func (s String) SetSlice(start, end, step, value Primitive) (Primitive, error) {
+	var (
+		rs                 = []rune(s.str)
+		beg, fin, inc, err = sliceBounds(len(rs), start, end, step)
+	)
+	if err != nil {
+		return nil, err
+	}
+	x, ok := value.(String)
+	if !ok {
+		return nil, incompatibleType("slice", s, value)
+	}
+	if inc == 1 {
+		if fin < beg {
+			fin = beg
+		}
+		s.str = string(rs[:beg]) + x.str + string(rs[fin:])
+		return s, nil
+	}
+	var (
+		ixs  = sliceRange(beg, fin, inc)
+		list = []rune(x.str)
+	)
+	if len(ixs) != len(list) {
+		return nil, fmt.Errorf("can not assign %d characters to slice of size %d", len(list), len(ixs))
+	}
+	for i, x := range ixs {
+		rs[x] = list[i]
+	}
+	s.str = string(rs)
+	return s, nil
+}
+
 func (s String) Mod(_ Primitive) (Primitive, error) {
 	return nil, unsupportedOp("modulo", s)
 }
@@ -0,0 +1,259 @@
+package types
+
+import (
+	"time"
+)
+
+type Time struct {
+	value time.Time
+}
+
+func CreateTime(t time.Time) Primitive {
+	return Time{
+		value: t,
+	}
+}
+
+func (t Time) Time() time.Time {
+	return t.value
+}
+
+func (t Time) Raw() any {
+	return t.value
+}
+
+func (t Time) String() string {
+	return t.value.Format(time.RFC3339Nano)
+}
+
+func (t Time) True() bool {
+	return !t.value.IsZero()
+}
+
+func (t Time) Not() (Primitive, error) {
+	return CreateBool(!t.True()), nil
+}
+
+func (t Time) Rev() (Primitive, error) {
+	return nil, unsupportedOp("reverse", t)
+}
+
+func (t Time) Add(other Primitive) (Primitive, error) {
+	x, ok := other.(Duration)
+	if !ok {
+		return nil, incompatibleType("addition", t, other)
+	}
+	t.value = t.value.Add(x.value)
+	return t, nil
+}
+
+func (t Time) Sub(other Primitive) (Primitive, error) {
+	switch x := other.(type) {
+	case Duration:
+		t.value = t.value.Add(-x.value)
+		return t, nil
+	case Time:
+		return CreateDuration(t.value.Sub(x.value)), nil
+	default:
+		return nil, incompatibleType("subtraction", t, other)
+	}
+}
+
+func (t Time) Eq(other Primitive) (Primitive, error) {
+	x, ok := other.(Time)
+	if !ok {
+		return nil, incompatibleType("eq", t, other)
+	}
+	return CreateBool(t.value.Equal(x.value)), nil
+}
+
+func (t Time) Ne(other Primitive) (Primitive, error) {
+	x, ok := other.(Time)
+	if !ok {
+		return nil, incompatibleType("ne", t, other)
+	}
+	return CreateBool(!t.value.Equal(x.value)), nil
+}
+
+func (t Time) Lt(other Primitive) (Primitive, error) {
+	x, ok := other.(Time)
+	if !ok {
+		return nil, incompatibleType("lt", t, other)
+	}
+	return CreateBool(t.value.Before(x.value)), nil
+}
+
+func (t Time) Le(other Primitive) (Primitive, error) {
+	x, ok := other.(Time)
+	if !ok {
+		return nil, incompatibleType("le", t, other)
+	}
+	return CreateBool(!t.value.After(x.value)), nil
+}
+
+func (t Time) Gt(other Primitive) (Primitive, error) {
+	x, ok := other.(Time)
+	if !ok {
+		return nil, incompatibleType("gt", t, other)
+	}
+	return CreateBool(t.value.After(x.value)), nil
+}
+
+func (t Time) Ge(other Primitive) (Primitive, error) {
+	x, ok := other.(Time)
+	if !ok {
+		return nil, incompatibleType("ge", t, other)
+	}
+	return CreateBool(!t.value.Before(x.value)), nil
+}
+
+type Duration struct {
+	value time.Duration
+}
+
+func CreateDuration(d time.Duration) Primitive {
+	return Duration{
+		value: d,
+	}
+}
+
+func (d Duration) Duration() time.Duration {
+	return d.value
+}
+
+func (d Duration) Raw() any {
+	return d.value
+}
+
+func (d Duration) String() string {
+	return d.value.String()
+}
+
+func (d Duration) True() bool {
+	return d.value != 0
+}
+
+func (d Duration) Not() (Primitive, error) {
+	return CreateBool(!d.True()), nil
+}
+
+func (d Duration) Rev() (Primitive, error) {
+	d.value = -d.value
+	return d, nil
+}
+
+func (d Duration) Add(other Primitive) (Primitive, error) {
+	switch x := other.(type) {
+	case Duration:
+		d.value += x.value
+		return d, nil
+	case Time:
+		return x.Add(d)
+	default:
+		return nil, incompatibleType("addition", d, other)
+	}
+}
+
+func (d Duration) Sub(other Primitive) (Primitive, error) {
+	x, ok := other.(Duration)
+	if !ok {
+		return nil, incompatibleType("subtraction", d, other)
+	}
+	d.value -= x.value
+	return d, nil
+}
+
+func (d Duration) Mul(other Primitive) (Primitive, error) {
+	switch x := other.(type) {
+	case Int:
+		d.value *= time.Duration(x.value)
+	case Float:
+		d.value = time.Duration(float64(d.value) * x.value)
+	default:
+		return nil, incompatibleType("multiply", d, other)
+	}
+	return d, nil
+}
+
+func (d Duration) Div(other Primitive) (Primitive, error) {
+	switch x := other.(type) {
+	case Int:
+		if x.value == 0 {
+			return nil, ErrZero
+		}
+		d.value /= time.Duration(x.value)
+	case Float:
+		if x.value == 0 {
+			return nil, ErrZero
+		}
+		d.value = time.Duration(float64(d.value) / x.value)
+	case Duration:
+		if x.value == 0 {
+			return nil, ErrZero
+		}
+		return CreateFloat(float64(d.value) / float64(x.value)), nil
+	default:
+		return nil, incompatibleType("division", d, other)
+	}
+	return d, nil
+}
+
+func (d Duration) Mod(other Primitive) (Primitive, error) {
+	x, ok := other.(Duration)
+	if !ok {
+		return nil, incompatibleType("modulo", d, other)
+	}
+	if x.value == 0 {
+		return nil, ErrZero
+	}
+	d.value %= x.value
+	return d, nil
+}
+
+func (d Duration) Eq(other Primitive) (Primitive, error) {
+	x, ok := other.(Duration)
+	if !ok {
+		return nil, incompatibleType("eq", d, other)
+	}
+	return CreateBool(d.value == x.value), nil
+}
+
+func (d Duration) Ne(other Primitive) (Primitive, error) {
+	x, ok := other.(Duration)
+	if !ok {
+		return nil, incompatibleType("ne", d, other)
+	}
+	return CreateBool(d.value != x.value), nil
+}
+
+func (d Duration) Lt(other Primitive) (Primitive, error) {
+	x, ok := other.(Duration)
+	if !ok {
+		return nil, incompatibleType("lt", d, other)
+	}
+	return CreateBool(d.value < x.value), nil
+}
+
+func (d Duration) Le(other Primitive) (Primitive, error) {
+	x, ok := other.(Duration)
+	if !ok {
+		return nil, incompatibleType("le", d, other)
+	}
+	return CreateBool(d.value <= x.value), nil
+}
+
+func (d Duration) Gt(other Primitive) (Primitive, error) {
+	x, ok := other.(Duration)
+	if !ok {
+		return nil, incompatibleType("gt", d, other)
+	}
+	return CreateBool(d.value > x.value), nil
+}
+
+func (d Duration) Ge(other Primitive) (Primitive, error) {
+	x, ok := other.(Duration)
+	if !ok {
+		return nil, incompatibleType("ge", d, other)
+	}
+	return CreateBool(d.value >= x.value), nil
+}
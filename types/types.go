@@ -10,6 +10,8 @@ var (
 	ErrOperation    = errors.New("unsupported operation")
 	ErrZero         = errors.New("division by zero")
 	ErrAssert       = errors.New("assertion failed")
+	ErrIndex        = errors.New("index out of range")
+	ErrKey          = errors.New("key not found")
 )
 
 type Sizeable interface {
@@ -25,6 +27,11 @@ type Container interface {
 	Get(Primitive) (Primitive, error)
 }
 
+type Sliceable interface {
+	Slice(Primitive, Primitive, Primitive) (Primitive, error)
+	SetSlice(Primitive, Primitive, Primitive, Primitive) (Primitive, error)
+}
+
 type Primitive interface {
 	fmt.Stringer
 	Raw() any
@@ -35,6 +42,8 @@ type Primitive interface {
 
 func CreatePrimitive(value any) (Primitive, error) {
 	switch v := value.(type) {
+	case nil:
+		return CreateNil(), nil
 	case string:
 		return CreateString(v), nil
 	case int64:
@@ -44,7 +53,7 @@ func CreatePrimitive(value any) (Primitive, error) {
 	case bool:
 		return CreateBool(v), nil
 	default:
-		return nil, fmt.Errorf("%s can not be transformed to Primitive")
+		return ValueOf(value)
 	}
 }
 
@@ -66,14 +75,108 @@ func ContainerError(val Primitive) error {
 	return fmt.Errorf("%w: %s can not be used as a container", ErrOperation, typeName(val))
 }
 
+func CallableError(val Primitive) error {
+	return fmt.Errorf("%w: %s is not callable", ErrOperation, typeName(val))
+}
+
+const (
+	SizeChar  = 1
+	SizeItem  = 16
+	SizeEntry = 48
+)
+
+func SizeOf(val Primitive) int {
+	switch v := val.(type) {
+	case String:
+		return v.Len() * SizeChar
+	case Array:
+		return v.Len() * SizeItem
+	case Dict:
+		return v.Len() * SizeEntry
+	default:
+		return 0
+	}
+}
+
+func SliceError(val Primitive) error {
+	return fmt.Errorf("%w: %s can not be sliced", ErrOperation, typeName(val))
+}
+
 func unsupportedOp(op string, val Primitive) error {
-	return fmt.Errorf("%s: %w for type %s", op, typeName(val))
+	return fmt.Errorf("%s: %w for type %s", op, ErrOperation, typeName(val))
 }
 
 func incompatibleType(op string, left, right Primitive) error {
 	return fmt.Errorf("%s: %w %s/%s", op, ErrIncompatible, typeName(left), typeName(right))
 }
 
+func toIndex(ix Primitive) (int, error) {
+	switch p := ix.(type) {
+	case Int:
+		return int(p.value), nil
+	case Float:
+		return int(p.value), nil
+	default:
+		return 0, fmt.Errorf("%T can not be used as index", ix)
+	}
+}
+
+func sliceIndex(ix Primitive, size int) (int, error) {
+	x, err := toIndex(ix)
+	if err != nil {
+		return x, err
+	}
+	if x < 0 {
+		x = size + x
+	}
+	if x < 0 || x > size {
+		return x, ErrIndex
+	}
+	return x, nil
+}
+
+func sliceBounds(size int, start, end, step Primitive) (int, int, int, error) {
+	var (
+		beg = 0
+		fin = size
+		inc = 1
+		err error
+	)
+	if step != nil {
+		if inc, err = toIndex(step); err != nil {
+			return beg, fin, inc, err
+		}
+		if inc == 0 {
+			return beg, fin, inc, fmt.Errorf("slice step can not be zero")
+		}
+	}
+	if inc < 0 {
+		beg, fin = size-1, -1
+	}
+	if start != nil {
+		if beg, err = sliceIndex(start, size); err != nil {
+			return beg, fin, inc, err
+		}
+		if inc < 0 && beg == size {
+			return beg, fin, inc, ErrIndex
+		}
+	}
+	if end != nil {
+		if fin, err = sliceIndex(end, size); err != nil {
+			return beg, fin, inc, err
+		}
+	}
+	return beg, fin, inc, nil
+}
+
+func sliceRange(beg, fin, inc int) []int {
+	var list []int
+	for i := beg; (inc > 0 && i < fin) || (inc < 0 && i > fin); i += inc {
+		list = append(list, i)
+	}
+	return list
+}
+
 func Type(val Primitive) (string, error) {
 	name := typeName(val)
 	if name == "" {
@@ -96,6 +199,16 @@ func typeName(val Primitive) string {
 		return "array"
 	case Dict:
 		return "dict"
+	case Function:
+		return "function"
+	case Nil:
+		return "nil"
+	case Iterator:
+		return "iterator"
+	case Time:
+		return "time"
+	case Duration:
+		return "duration"
 	default:
 		return "?"
 	}
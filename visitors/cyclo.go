@@ -26,6 +26,21 @@ func (c *Cyclo) Count(expr ast.Expression) (int, error) {
 	case ast.Double:
 	case ast.Integer:
 	case ast.Boolean:
+	case ast.Nil:
+	case ast.Template:
+		for i := range e.List {
+			if _, err := c.Count(e.List[i]); err != nil {
+				return c.count, err
+			}
+		}
+	case ast.Placeholder:
+		return c.Count(e.Expr)
+	case ast.Chain:
+		c.count++
+		if _, err := c.Count(e.Left); err != nil {
+			return c.count, err
+		}
+		return c.Count(e.Key)
 	case ast.Variable:
 	case ast.Array:
 		for i := range e.List {
@@ -61,6 +76,9 @@ func (c *Cyclo) Count(expr ast.Expression) (int, error) {
 	case ast.Path:
 		return c.Count(e.Right)
 	case ast.Call:
+		if _, err := c.Count(e.Expr); err != nil {
+			return c.count, err
+		}
 		for i := range e.Args {
 			if _, err := c.Count(e.Args[i]); err != nil {
 				return c.count, err
@@ -86,7 +104,7 @@ func (c *Cyclo) Count(expr ast.Expression) (int, error) {
 		if _, err := c.Count(e.Right); err != nil {
 			return c.count, err
 		}
-		if e.Op == token.Or || e.Op == token.And {
+		if e.Op == token.Or || e.Op == token.And || e.Op == token.Nullish {
 			c.count++
 		}
 	case ast.ListComp:
@@ -166,6 +184,19 @@ func (c *Cyclo) Count(expr ast.Expression) (int, error) {
 		return c.Count(e.Body)
 	case ast.Return:
 		return c.Count(e.Right)
+	case ast.Try:
+		if _, err := c.Count(e.Body); err != nil {
+			return c.count, err
+		}
+		if e.Catch != nil {
+			c.count++
+			if _, err := c.Count(e.Catch); err != nil {
+				return c.count, err
+			}
+		}
+		return c.Count(e.Finally)
+	case ast.Raise:
+		return c.Count(e.Right)
 	case ast.Break:
 	case ast.Continue:
 	default:
@@ -42,6 +42,25 @@ func (v *importVisitor) visit(expr ast.Expression) error {
 		// PASS: to be removed later
 	case ast.Boolean:
 		// PASS: to be removed later
+	case ast.Nil:
+		// PASS: to be removed later
+	case ast.Template:
+		for i := range e.List {
+			if err = v.visit(e.List[i]); err != nil {
+				v.list.Append(err)
+			}
+		}
+	case ast.Placeholder:
+		if err = v.visit(e.Expr); err != nil {
+			v.list.Append(err)
+		}
+	case ast.Chain:
+		if err = v.visit(e.Left); err != nil {
+			v.list.Append(err)
+		}
+		if err = v.visit(e.Key); err != nil {
+			v.list.Append(err)
+		}
 	case ast.Variable:
 		// PASS: to be removed later
 	case ast.Array:
@@ -85,6 +104,9 @@ func (v *importVisitor) visit(expr ast.Expression) error {
 			v.list.Append(err)
 		}
 	case ast.Call:
+		if err = v.visit(e.Expr); err != nil {
+			v.list.Append(err)
+		}
 		for i := range e.Args {
 			if err = v.visit(e.Args[i]); err != nil {
 				v.list.Append(err)
@@ -211,6 +233,20 @@ func (v *importVisitor) visit(expr ast.Expression) error {
 		if err := v.visit(e.Right); err != nil {
 			v.list.Append(err)
 		}
+	case ast.Try:
+		if err = v.visit(e.Body); err != nil {
+			v.list.Append(err)
+		}
+		if err = v.visit(e.Catch); err != nil {
+			v.list.Append(err)
+		}
+		if err = v.visit(e.Finally); err != nil {
+			v.list.Append(err)
+		}
+	case ast.Raise:
+		if err := v.visit(e.Right); err != nil {
+			v.list.Append(err)
+		}
 	case ast.Break:
 		// PASS: to be removed later
 	case ast.Continue:
@@ -1,8 +1,6 @@
 package visitors
 
 import (
-	"fmt"
-
 	"github.com/midbel/buddy/ast"
 	"github.com/midbel/buddy/faults"
 	"github.com/midbel/buddy/token"
@@ -37,6 +35,16 @@ func (v *loopVisitor) visit(expr ast.Expression) error {
 	case ast.Double:
 	case ast.Integer:
 	case ast.Boolean:
+	case ast.Nil:
+	case ast.Template:
+		for i := range e.List {
+			v.reject(e.List[i])
+		}
+	case ast.Placeholder:
+		v.reject(e.Expr)
+	case ast.Chain:
+		v.reject(e.Left)
+		v.reject(e.Key)
 	case ast.Variable:
 	case ast.Array:
 		for i := range e.List {
@@ -58,6 +66,7 @@ func (v *loopVisitor) visit(expr ast.Expression) error {
 	case ast.Path:
 		v.reject(e.Right)
 	case ast.Call:
+		v.reject(e.Expr)
 		for i := range e.Args {
 			v.reject(e.Args[i])
 		}
@@ -110,6 +119,14 @@ func (v *loopVisitor) visit(expr ast.Expression) error {
 		v.reject(e.Body)
 	case ast.Return:
 		v.reject(e.Right)
+	case ast.Try:
+		for _, x := range []ast.Expression{e.Body, e.Catch, e.Finally} {
+			if err := v.visit(x); err != nil {
+				v.list.Append(err)
+			}
+		}
+	case ast.Raise:
+		v.reject(e.Right)
 	case ast.Break:
 		if !v.inLoop() {
 			return notInLoop(e.Literal, e.Position)
@@ -155,5 +172,9 @@ func (v *loopVisitor) inLoop() bool {
 }
 
 func notInLoop(kw string, pos token.Position) error {
-	return fmt.Errorf("[%s] %s is not in a loop", pos, kw)
+	return IdentError{
+		Position: pos,
+		Ident:    kw,
+		What:     "not in a loop",
+	}
 }
@@ -34,6 +34,24 @@ func (v valueVisitor) visit(expr ast.Expression, ctx types.Context) (ast.Express
 		// PASS: to be removed later
 	case ast.Boolean:
 		// PASS: to be removed later
+	case ast.Nil:
+		// PASS: to be removed later
+	case ast.Template:
+		for i := range e.List {
+			if e.List[i], err = v.visit(e.List[i], ctx); err != nil {
+				break
+			}
+		}
+		return e, err
+	case ast.Placeholder:
+		e.Expr, err = v.visit(e.Expr, ctx)
+		return e, err
+	case ast.Chain:
+		if e.Left, err = v.visit(e.Left, ctx); err != nil {
+			return nil, err
+		}
+		e.Key, err = v.visit(e.Key, ctx)
+		return e, err
 	case ast.Variable:
 		if res, err := ctx.Resolve(e.Ident); err == nil {
 			return ast.CreatePrimitive(e.Token, res.Raw())
@@ -75,9 +93,15 @@ func (v valueVisitor) visit(expr ast.Expression, ctx types.Context) (ast.Express
 		}
 		return e, nil
 	case ast.Path:
+		if _, ok := e.Right.(ast.Variable); ok {
+			return e, nil
+		}
 		e.Right, err = v.visit(e.Right, ctx)
 		return e, err
 	case ast.Call:
+		if e.Expr, err = v.visit(e.Expr, ctx); err != nil {
+			return nil, err
+		}
 		for i := range e.Args {
 			if e.Args[i], err = v.visit(e.Args[i], ctx); err != nil {
 				break
@@ -210,6 +234,18 @@ func (v valueVisitor) visit(expr ast.Expression, ctx types.Context) (ast.Express
 	case ast.Return:
 		e.Right, err = v.visit(e.Right, ctx)
 		return e, err
+	case ast.Try:
+		if e.Body, err = v.visit(e.Body, ctx); err != nil {
+			return nil, err
+		}
+		if e.Catch, err = v.visit(e.Catch, ctx); err != nil {
+			return nil, err
+		}
+		e.Finally, err = v.visit(e.Finally, ctx)
+		return e, err
+	case ast.Raise:
+		e.Right, err = v.visit(e.Right, ctx)
+		return e, err
 	case ast.Break:
 		// PASS: to be removed later
 	case ast.Continue:
@@ -231,6 +267,8 @@ func evalExpression(e ast.Expression) (types.Primitive, error) {
 		res = types.CreateInt(e.Value)
 	case ast.Boolean:
 		res = types.CreateBool(e.Value)
+	case ast.Nil:
+		res = types.CreateNil()
 	default:
 		return nil, fmt.Errorf("expression is not a value")
 	}
@@ -295,24 +333,25 @@ func evalBnot(u ast.Unary) ast.Expression {
 }
 
 var binaryActions = map[rune]func(ast.Binary) ast.Expression{
-	token.Add:    evalAdd,
-	token.Sub:    evalSub,
-	token.Mul:    evalMul,
-	token.Div:    evalDiv,
-	token.Pow:    evalPow,
-	token.Mod:    evalMod,
-	token.Lshift: evalLshift,
-	token.Rshift: evalRshift,
-	token.BinAnd: evalBand,
-	token.BinOr:  evalBor,
-	token.Eq:     evalEq,
-	token.Ne:     evalNe,
-	token.Lt:     evalLt,
-	token.Le:     evalLe,
-	token.Gt:     evalGt,
-	token.Ge:     evalGe,
-	token.And:    evalAnd,
-	token.Or:     evalOr,
+	token.Add:     evalAdd,
+	token.Sub:     evalSub,
+	token.Mul:     evalMul,
+	token.Div:     evalDiv,
+	token.Pow:     evalPow,
+	token.Mod:     evalMod,
+	token.Lshift:  evalLshift,
+	token.Rshift:  evalRshift,
+	token.BinAnd:  evalBand,
+	token.BinOr:   evalBor,
+	token.Eq:      evalEq,
+	token.Ne:      evalNe,
+	token.Lt:      evalLt,
+	token.Le:      evalLe,
+	token.Gt:      evalGt,
+	token.Ge:      evalGe,
+	token.And:     evalAnd,
+	token.Or:      evalOr,
+	token.Nullish: evalNullish,
 }
 
 func evalBinary(b ast.Binary, ctx types.Context) (ast.Expression, error) {
@@ -492,6 +531,13 @@ func evalAnd(b ast.Binary) ast.Expression {
 	return ast.CreateBoolean(b.Token, res)
 }
 
+func evalNullish(b ast.Binary) ast.Expression {
+	if _, ok := b.Left.(ast.Nil); ok {
+		return b.Right
+	}
+	return b.Left
+}
+
 func evalOr(b ast.Binary) ast.Expression {
 	res := ast.IsTrue(b.Left) || ast.IsTrue(b.Right)
 	return ast.CreateBoolean(b.Token, res)
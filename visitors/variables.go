@@ -2,6 +2,7 @@ package visitors
 
 import (
 	"github.com/midbel/buddy/ast"
+	"github.com/midbel/buddy/builtins"
 	"github.com/midbel/buddy/faults"
 	"github.com/midbel/buddy/token"
 )
@@ -41,6 +42,25 @@ func (v *variableVisitor) visit(expr ast.Expression) error {
 		// PASS: to be removed later
 	case ast.Boolean:
 		// PASS: to be removed later
+	case ast.Nil:
+		// PASS: to be removed later
+	case ast.Template:
+		for i := range e.List {
+			if err = v.visit(e.List[i]); err != nil {
+				v.list.Append(err)
+			}
+		}
+	case ast.Placeholder:
+		if err = v.visit(e.Expr); err != nil {
+			v.list.Append(err)
+		}
+	case ast.Chain:
+		if err = v.visit(e.Left); err != nil {
+			v.list.Append(err)
+		}
+		if err = v.visit(e.Key); err != nil {
+			v.list.Append(err)
+		}
 	case ast.Variable:
 		err = v.exists(e)
 		if err != nil {
@@ -82,6 +102,9 @@ func (v *variableVisitor) visit(expr ast.Expression) error {
 		}
 	case ast.Path:
 	case ast.Call:
+		if err = v.visit(e.Expr); err != nil {
+			v.list.Append(err)
+		}
 		for i := range e.Args {
 			if err = v.visit(e.Args[i]); err != nil {
 				v.list.Append(err)
@@ -95,6 +118,12 @@ func (v *variableVisitor) visit(expr ast.Expression) error {
 		if err = v.visit(e.Expr); err != nil {
 			v.list.Append(err)
 		}
+	case ast.Let:
+		if err = v.visit(e.Right); err != nil {
+			v.list.Append(err)
+		}
+		v.env.Incr(e.Ident)
+		v.variables[e.Ident] = e.Token
 	case ast.Assign:
 		err = v.visit(e.Right)
 		if err != nil {
@@ -211,6 +240,10 @@ func (v *variableVisitor) visit(expr ast.Expression) error {
 			}
 		}
 	case ast.Function:
+		if e.Ident != "" {
+			v.env.Incr(e.Ident)
+			v.variables[e.Ident] = e.Token
+		}
 		v.enter()
 		defer v.leave()
 		for i := range e.Params {
@@ -228,6 +261,30 @@ func (v *variableVisitor) visit(expr ast.Expression) error {
 		if err = v.visit(e.Right); err != nil {
 			v.list.Append(err)
 		}
+	case ast.Try:
+		v.enter()
+		if err = v.visit(e.Body); err != nil {
+			v.list.Append(err)
+		}
+		v.leave()
+		v.enter()
+		if e.Ident != "" {
+			v.env.Incr(e.Ident)
+			v.variables[e.Ident] = e.Token
+		}
+		if err = v.visit(e.Catch); err != nil {
+			v.list.Append(err)
+		}
+		v.leave()
+		v.enter()
+		if err = v.visit(e.Finally); err != nil {
+			v.list.Append(err)
+		}
+		v.leave()
+	case ast.Raise:
+		if err = v.visit(e.Right); err != nil {
+			v.list.Append(err)
+		}
 	case ast.Break:
 	case ast.Continue:
 	default:
@@ -250,6 +307,9 @@ func (v *variableVisitor) leave() {
 func (v variableVisitor) exists(i ast.Variable) error {
 	ok := v.env.Exists(i.Ident)
 	if !ok {
+		if _, err := builtins.LookupBuiltin(i.Ident); err == nil {
+			return nil
+		}
 		return undefinedVar(i.Ident, i.Position)
 	}
 	return nil